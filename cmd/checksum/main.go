@@ -14,8 +14,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ChaosHour/go-data-checksum/pkg/builder"
 	"github.com/ChaosHour/go-data-checksum/pkg/checksum"
 	"github.com/ChaosHour/go-data-checksum/pkg/resume"
+	"github.com/ChaosHour/go-data-checksum/pkg/throttle"
 	"github.com/ChaosHour/go-data-checksum/pkg/tracking"
 	"github.com/ChaosHour/go-data-checksum/pkg/types"
 )
@@ -28,6 +30,8 @@ type ChecksumJob struct {
 	wg              *sync.WaitGroup
 	// Tracker is nil unless --enable-tracking is set.
 	Tracker *tracking.JobTracker
+	// Throttler is nil unless --max-lag, --max-load or --critical-load is set.
+	Throttler *throttle.Throttler
 }
 
 func NewChecksumJob(threads int) *ChecksumJob {
@@ -178,16 +182,421 @@ func runDifferentialAnalysis(baseContext *types.BaseContext, checksumContext *ch
 		}
 	}
 	differ := &checksum.TableDiffer{Context: checksumContext}
+	if checksumContext.IsKeyless {
+		if diffErr := differ.AnalyzeAndReportBucketDifferences(); diffErr != nil {
+			baseContext.Log.Errorf("Failed to perform differential analysis: %v", diffErr)
+		}
+		return
+	}
 	if diffErr := differ.AnalyzeAndReportDifferences(); diffErr != nil {
 		baseContext.Log.Errorf("Failed to perform differential analysis: %v", diffErr)
 	}
 }
 
+// runTimeRangeDifferentialAnalysis runs the record-level difference analysis on the mismatched time chunks of a
+// table only. A keyless table has no key to merge its records on and is compared in hash buckets as a whole.
+func runTimeRangeDifferentialAnalysis(baseContext *types.BaseContext, checksumContext *checksum.ChecksumContext, mismatchedRanges []checksum.TimeRange) {
+	baseContext.Log.Infof("Running differential analysis of %d mismatched time chunk(s) for table pair: %s.%s => %s.%s", len(mismatchedRanges), checksumContext.PerTableContext.SourceDatabaseName, checksumContext.PerTableContext.SourceTableName, checksumContext.PerTableContext.TargetDatabaseName, checksumContext.PerTableContext.TargetTableName)
+	if checksumContext.UniqueKey == nil {
+		if err := checksumContext.GetUniqueKeys(); err != nil {
+			baseContext.Log.Errorf("Failed to perform differential analysis: %v", err)
+			return
+		}
+	}
+	differ := &checksum.TableDiffer{Context: checksumContext}
+	if checksumContext.IsKeyless {
+		if diffErr := differ.AnalyzeAndReportBucketDifferences(); diffErr != nil {
+			baseContext.Log.Errorf("Failed to perform differential analysis: %v", diffErr)
+		}
+		return
+	}
+	if diffErr := differ.AnalyzeAndReportTimeRangeDifferences(mismatchedRanges); diffErr != nil {
+		baseContext.Log.Errorf("Failed to perform differential analysis: %v", diffErr)
+	}
+}
+
+// runRangeDifferentialAnalysis runs the record-level difference analysis on the mismatched chunk ranges of a table only,
+// bisecting them first with --enable-chunk-bisection. It runs after the chunk loop, so check columns and the unique key are resolved.
+func runRangeDifferentialAnalysis(baseContext *types.BaseContext, checksumContext *checksum.ChecksumContext, mismatchedRanges []checksum.KeyRange) {
+	baseContext.Log.Infof("Running differential analysis of %d mismatched chunk(s) for table pair: %s.%s => %s.%s", len(mismatchedRanges), checksumContext.PerTableContext.SourceDatabaseName, checksumContext.PerTableContext.SourceTableName, checksumContext.PerTableContext.TargetDatabaseName, checksumContext.PerTableContext.TargetTableName)
+	ranges := mismatchedRanges
+	if baseContext.EnableChunkBisection {
+		ranges = nil
+		for _, mismatchedRange := range mismatchedRanges {
+			bisectedRanges, err := checksumContext.BisectKeyRange(mismatchedRange)
+			if err != nil {
+				baseContext.Log.Errorf("Failed to perform differential analysis: bisect range %s: %v", mismatchedRange, err)
+				return
+			}
+			ranges = append(ranges, bisectedRanges...)
+		}
+	}
+	differ := &checksum.TableDiffer{Context: checksumContext}
+	if diffErr := differ.AnalyzeAndReportRangeDifferences(ranges); diffErr != nil {
+		baseContext.Log.Errorf("Failed to perform differential analysis: %v", diffErr)
+	}
+}
+
+// checkChunks runs the chunk loop over the key range of the context. It returns false at the first
+// different chunk unless --continue-on-mismatch is set; it also returns, reporting equal, once stop
+// is closed because another key-range worker of the table found a difference or failed.
+func checkChunks(baseContext *types.BaseContext, ChecksumContext *checksum.ChecksumContext, stop <-chan struct{}) (isEqual bool, err error) {
+	// Probe the next chunk boundaries on the source while the current chunk is checked
+	ChecksumContext.StartBoundaryLookahead(baseContext.ChunkLookahead)
+	defer ChecksumContext.StopBoundaryLookahead()
+
+	var hasFurtherRange = true
+	for hasFurtherRange {
+		select {
+		case <-stop:
+			return true, nil
+		default:
+		}
+		hasFurtherRange, err = ChecksumContext.CalculateNextIterationRangeEndValues()
+		if err != nil {
+			return false, err
+		}
+		baseContext.Log.Debugf("CalculateNextIterationRangeEndValues of table pair: %s.%s => %s.%s .", ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName)
+
+		var isChunkChecksumEqual bool
+		var duration time.Duration
+		if hasFurtherRange {
+			// With --chunk-size-limit, a chunk holding too many target rows is split, skipped or aborts the table
+			var skip bool
+			if skip, err = ChecksumContext.GuardOversizedChunk(); err != nil {
+				ChecksumContext.TrackChunk(ChecksumContext.NextChunkNumber(), false, err, 0)
+				return false, err
+			}
+			if skip {
+				continue
+			}
+			// Refuse the table when a chunk query would not range over the chunk index
+			if err = ChecksumContext.GuardQueryPlan(); err != nil {
+				ChecksumContext.TrackChunk(ChecksumContext.NextChunkNumber(), false, err, 0)
+				return false, err
+			}
+			// Pause here while the replicas lag behind --max-lag or the servers reach --max-load; --critical-load aborts
+			if err = ChecksumContext.Throttle(); err != nil {
+				ChecksumContext.TrackChunk(ChecksumContext.NextChunkNumber(), false, err, 0)
+				return false, err
+			}
+			// Retry to ride out transient errors and replication lag; with --column-fingerprints
+			// a chunk still different after the retries has its columns fingerprinted once
+			isChunkChecksumEqual, duration, err = ChecksumContext.RetryIterationQueryChecksum()
+			chunkRange := ChecksumContext.CurrentKeyRange()
+			chunkNumber := ChecksumContext.NextChunkNumber()
+			ChecksumContext.TrackChunk(chunkNumber, isChunkChecksumEqual, err, duration)
+			if err == nil {
+				ChecksumContext.AdjustChunkSize(duration)
+			}
+			if err != nil {
+				return false, err
+			}
+			if !isChunkChecksumEqual && baseContext.ContinueOnMismatch {
+				baseContext.Log.Errorf("Critical: Iteration %d, record CRC32 checksum value is not equal in range %s of table pair: %s.%s => %s.%s", chunkNumber, chunkRange, ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName)
+				continue
+			}
+			if !isChunkChecksumEqual {
+				baseContext.Log.Debugf("Debug: Iteration %d, record CRC32 checksum value is not equal of table pair: %s.%s => %s.%s , Duration=%+v", chunkNumber, ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName, duration)
+				return false, nil
+			}
+			baseContext.Log.Debugf("Debug: Iteration %d, record CRC32 checksum value is equal of table pair: %s.%s => %s.%s , Duration=%+v", chunkNumber, ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName, duration)
+		}
+	}
+	return true, nil
+}
+
+// checkKeylessTable compares a table without a usable unique key in hash buckets, retrying the
+// comparison like a chunk, and analyzes its different buckets when differential reporting is enabled.
+func checkKeylessTable(baseContext *types.BaseContext, ChecksumContext *checksum.ChecksumContext, startTime time.Time) (isEqual bool, err error) {
+	var duration time.Duration
+	// Pause here while the replicas lag behind --max-lag or the servers reach --max-load; --critical-load aborts
+	if err = ChecksumContext.Throttle(); err != nil {
+		return false, err
+	}
+	for i := 0; i < int(ChecksumContext.Context.DefaultNumRetries); i++ {
+		if i != 0 {
+			time.Sleep(1 * time.Second)
+			// The same snapshots would return the same rows; retry in new ones
+			if err = ChecksumContext.RefreshSnapshot(); err != nil {
+				break
+			}
+			baseContext.Log.Debugf("IterationBucketChecksum retry times %d of table pair: %s.%s => %s.%s .", i, ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName)
+		}
+		isEqual, duration, err = ChecksumContext.IterationBucketChecksum()
+		if err == nil && isEqual {
+			break
+		}
+	}
+	tableCheckDuration := time.Since(startTime)
+	if err != nil {
+		baseContext.Log.Errorf("Critical: hash bucket checksums of table pair: %s.%s => %s.%s failed , tableCheckDuration=%+v", ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName, tableCheckDuration)
+		return false, err
+	}
+	ChecksumContext.TrackBuckets(duration)
+	if !isEqual {
+		baseContext.Log.Errorf("Critical: record checksum value is not equal in %d of %d hash bucket(s) of table pair: %s.%s => %s.%s , tableCheckDuration=%+v", len(ChecksumContext.DifferentBuckets()), baseContext.KeylessBuckets, ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName, tableCheckDuration)
+		if baseContext.EnableDifferentialReporting {
+			differ := &checksum.TableDiffer{Context: ChecksumContext}
+			if diffErr := differ.AnalyzeAndReportBucketDifferences(); diffErr != nil {
+				baseContext.Log.Errorf("Failed to perform differential analysis: %v", diffErr)
+			}
+		}
+		return false, nil
+	}
+	baseContext.Log.Infof("Info: record checksum value is equal of table pair: %s.%s => %s.%s , tableCheckDuration=%+v.", ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName, tableCheckDuration)
+	baseContext.Log.Infof("End check table pair: %s.%s => %s.%s .", ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName)
+	return true, nil
+}
+
+// checkKeyRangesInParallel checks each key range of the table on its own worker context and merges
+// their outcome into the table's context. The first different chunk (without --continue-on-mismatch)
+// or error stops the other workers.
+func checkKeyRangesInParallel(baseContext *types.BaseContext, ChecksumContext *checksum.ChecksumContext, keyRanges []checksum.KeyRange) (isEqual bool, err error) {
+	baseContext.Log.Infof("Checking table pair: %s.%s => %s.%s in %d parallel key ranges.", ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName, len(keyRanges))
+	workers := make([]*checksum.ChecksumContext, len(keyRanges))
+	results := make([]bool, len(keyRanges))
+	errs := make([]error, len(keyRanges))
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	var wg sync.WaitGroup
+	for i, keyRange := range keyRanges {
+		workers[i] = ChecksumContext.NewRangeContext(keyRange)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = checkChunks(baseContext, workers[i], stop)
+			if errs[i] != nil || !results[i] {
+				stopOnce.Do(func() { close(stop) })
+			}
+		}(i)
+	}
+	wg.Wait()
+	ChecksumContext.MergeRangeContexts(workers)
+
+	isEqual = true
+	for i := range keyRanges {
+		if errs[i] != nil {
+			return false, errs[i]
+		}
+		isEqual = isEqual && results[i]
+	}
+	return isEqual, nil
+}
+
+// checkedPair names the table pair the context checks, or its partition, in log messages
+func checkedPair(ChecksumContext *checksum.ChecksumContext) string {
+	pair := fmt.Sprintf("table pair: %s.%s => %s.%s", ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName)
+	if partition := ChecksumContext.Partition(); partition != "" {
+		return fmt.Sprintf("partition %s of %s", partition, pair)
+	}
+	return pair
+}
+
+// compareRowCounts compares the count(*) values of the context unless --ignore-row-count-check is set.
+// It reports the check decided when the counts differ, running the differential analysis when enabled,
+// unless --continue-on-mismatch goes on to locate the different chunks: then it reports the mismatch.
+func compareRowCounts(baseContext *types.BaseContext, ChecksumContext *checksum.ChecksumContext) (isDecided bool, rowCountMismatch bool, err error) {
+	if baseContext.IgnoreRowCountCheck {
+		baseContext.Log.Debugf("Ignore DataChecksumByCount of %s due to IgnoreRowCountCheck=true.", checkedPair(ChecksumContext))
+		return false, false, nil
+	}
+	baseContext.Log.Debugf("DataChecksumByCount of %s .", checkedPair(ChecksumContext))
+	_, isMoreCheckNeeded, sourceRowCount, targetRowCount, err := ChecksumContext.DataChecksumByCount()
+	if err != nil {
+		return false, false, err
+	}
+	ChecksumContext.SourceRowCount, ChecksumContext.TargetRowCount = sourceRowCount, targetRowCount
+	if !isMoreCheckNeeded && baseContext.ContinueOnMismatch && sourceRowCount > 0 {
+		// Row counts differ: the chunk checks still locate the different chunks
+		return false, true, nil
+	}
+	if !isMoreCheckNeeded {
+		// Row counts differ: still run record-level analysis when differential reporting is enabled
+		if baseContext.EnableDifferentialReporting {
+			runDifferentialAnalysis(baseContext, ChecksumContext)
+		}
+		return true, false, nil
+	}
+	return false, false, nil
+}
+
+// compareKeyRanges runs the chunk loop over the key ranges of the context, in parallel when there
+// are several, and reports the outcome: a different chunk, the chunks --continue-on-mismatch
+// collected or skipped oversized chunks leave the context not equal.
+func compareKeyRanges(baseContext *types.BaseContext, ChecksumContext *checksum.ChecksumContext, keyRanges []checksum.KeyRange, rowCountMismatch bool, startTime time.Time) (isEqual bool, err error) {
+	var tableCheckDuration time.Duration
+	pair := checkedPair(ChecksumContext)
+
+	// Compute chunk checksums
+	var isChecksumEqual bool
+	if len(keyRanges) == 1 {
+		isChecksumEqual, err = checkChunks(baseContext, ChecksumContext, nil)
+	} else {
+		isChecksumEqual, err = checkKeyRangesInParallel(baseContext, ChecksumContext, keyRanges)
+	}
+	if err != nil {
+		tableCheckDuration = time.Since(startTime)
+		baseContext.Log.Errorf("Critical: record CRC32 checksum value is not equal of %s , tableCheckDuration=%+v", pair, tableCheckDuration)
+		return false, err
+	}
+	if summary := ChecksumContext.SampleSummary(); summary != "" {
+		baseContext.Log.Infof("Sample of %s: %s.", pair, summary)
+	}
+	if !isChecksumEqual {
+		tableCheckDuration = time.Since(startTime)
+		baseContext.Log.Errorf("Critical: record CRC32 checksum value is not equal of %s , tableCheckDuration=%+v", pair, tableCheckDuration)
+
+		// If differential reporting is enabled, row-diff from the mismatched chunk the loop stopped
+		// at through the end of the table: the chunks after it were never checked
+		if baseContext.EnableDifferentialReporting {
+			if differentRanges := ChecksumContext.DifferentKeyRanges(); len(differentRanges) > 0 && len(keyRanges) == 1 {
+				last := len(differentRanges) - 1
+				ranges := append(append([]checksum.KeyRange{}, differentRanges[:last]...), ChecksumContext.KeyRangeThroughEnd(differentRanges[last]))
+				runRangeDifferentialAnalysis(baseContext, ChecksumContext, ranges)
+			} else if len(differentRanges) > 0 {
+				runRangeDifferentialAnalysis(baseContext, ChecksumContext, differentRanges)
+				baseContext.Log.Warnf("Only the mismatched chunk(s) of %s were row-diffed; the key ranges its parallel workers stopped before checking were not. Use --continue-on-mismatch to check and row-diff every chunk.", pair)
+			} else {
+				runDifferentialAnalysis(baseContext, ChecksumContext)
+			}
+		}
+
+		return false, nil
+	}
+	// With --continue-on-mismatch, TrackChunk has collected the different chunks
+	if differentRanges := ChecksumContext.DifferentKeyRanges(); len(differentRanges) > 0 || rowCountMismatch {
+		tableCheckDuration = time.Since(startTime)
+		baseContext.Log.Errorf("Critical: record CRC32 checksum value is not equal in %d chunk(s) of %s , tableCheckDuration=%+v", len(differentRanges), pair, tableCheckDuration)
+		if baseContext.EnableDifferentialReporting {
+			runRangeDifferentialAnalysis(baseContext, ChecksumContext, differentRanges)
+		}
+		return false, nil
+	}
+	// Skipped oversized chunks were not compared
+	if skipped := ChecksumContext.SkippedChunks(); skipped > 0 {
+		tableCheckDuration = time.Since(startTime)
+		baseContext.Log.Errorf("Critical: %d oversized chunk(s) were skipped, the %s is not fully checked , tableCheckDuration=%+v", skipped, pair, tableCheckDuration)
+		return false, nil
+	}
+	estimatedRows := int(ChecksumContext.EstimatedRowsChecked())
+	tableCheckDuration = time.Since(startTime)
+	elapsedSecond := int(tableCheckDuration / time.Second)
+	if elapsedSecond < 1 {
+		elapsedSecond = 1
+	}
+	CheckSpeed := estimatedRows / elapsedSecond
+	baseContext.Log.Infof("Info: record CRC32 checksum value is equal of %s , tableCheckDuration=%+v, tableCheckSpeed= %+v rows/second.", pair, tableCheckDuration, CheckSpeed)
+	return true, nil
+}
+
+// checkPartitions checks a partitioned table one partition at a time, skipping the partitions a
+// resumed run already decided. A difference in one partition does not stop the others; an error does.
+// A keyless table is compared as a whole in hash buckets.
+func checkPartitions(baseContext *types.BaseContext, ChecksumContext *checksum.ChecksumContext, partitions []string, startTime time.Time) (isEqual bool, err error) {
+	if ChecksumContext.CheckColumns == nil {
+		if err := ChecksumContext.GetCheckColumns(); err != nil {
+			return false, err
+		}
+	}
+	if err := ChecksumContext.GetUniqueKeys(); err != nil {
+		return false, err
+	}
+	if ChecksumContext.IsKeyless {
+		if baseContext.Partitions.Has(ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName) {
+			return false, fmt.Errorf("critical: table %s.%s has no usable unique key, --partitions cannot be used", ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName)
+		}
+		baseContext.Log.Infof("Table pair: %s.%s => %s.%s is checked as a whole: it has no usable unique key.", ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName)
+		isDecided, _, err := compareRowCounts(baseContext, ChecksumContext)
+		if err != nil || isDecided {
+			return false, err
+		}
+		return checkKeylessTable(baseContext, ChecksumContext, startTime)
+	}
+
+	baseContext.Log.Infof("Checking table pair: %s.%s => %s.%s in %d partition(s).", ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName, len(partitions))
+	checked := ChecksumContext.CheckedPartitions()
+	// Each partition is read in a snapshot of its own (--consistent-snapshot); the table's is not needed
+	ChecksumContext.CloseSnapshot()
+	var equalPartitions, differentPartitions []string
+	var sourceRowCount, targetRowCount int64
+	for _, partition := range partitions {
+		if status, ok := checked[partition]; ok {
+			baseContext.Log.Infof("Partition %s of table pair: %s.%s => %s.%s was found %s before; skipping it.", partition, ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName, status)
+			if status == tracking.StatusEqual {
+				equalPartitions = append(equalPartitions, partition)
+			} else {
+				differentPartitions = append(differentPartitions, partition)
+			}
+			continue
+		}
+		partitionStartTime := time.Now()
+		partitionContext := ChecksumContext.NewPartitionContext(partition)
+		isPartitionEqual, err := checkPartition(baseContext, partitionContext, partitionStartTime)
+		ChecksumContext.MergeRangeContexts([]*checksum.ChecksumContext{partitionContext})
+		partitionContext.TrackPartitionDone(isPartitionEqual, err, time.Since(partitionStartTime))
+		if err != nil {
+			return false, err
+		}
+		if partitionContext.SourceRowCount >= 0 {
+			sourceRowCount += partitionContext.SourceRowCount
+			targetRowCount += partitionContext.TargetRowCount
+		}
+		if isPartitionEqual {
+			equalPartitions = append(equalPartitions, partition)
+		} else {
+			differentPartitions = append(differentPartitions, partition)
+		}
+	}
+	if !baseContext.IgnoreRowCountCheck && len(checked) == 0 {
+		ChecksumContext.SourceRowCount, ChecksumContext.TargetRowCount = sourceRowCount, targetRowCount
+	}
+
+	tableCheckDuration := time.Since(startTime)
+	if len(differentPartitions) > 0 {
+		baseContext.Log.Errorf("Critical: record CRC32 checksum value is not equal in %d of %d partition(s) of table pair: %s.%s => %s.%s (%s), tableCheckDuration=%+v", len(differentPartitions), len(partitions), ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName, strings.Join(differentPartitions, ", "), tableCheckDuration)
+		return false, nil
+	}
+	baseContext.Log.Infof("Info: record CRC32 checksum value is equal in all %d partition(s) of table pair: %s.%s => %s.%s , tableCheckDuration=%+v.", len(equalPartitions), ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName, tableCheckDuration)
+	baseContext.Log.Infof("End check table pair: %s.%s => %s.%s .", ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName)
+	return true, nil
+}
+
+// checkPartition compares the row counts and then the chunk checksums of the partition the context checks
+func checkPartition(baseContext *types.BaseContext, ChecksumContext *checksum.ChecksumContext, startTime time.Time) (isEqual bool, err error) {
+	baseContext.Log.Infof("Starting check %s .", checkedPair(ChecksumContext))
+	if err := ChecksumContext.OpenSnapshot(); err != nil {
+		return false, err
+	}
+	defer ChecksumContext.CloseSnapshot()
+	isDecided, rowCountMismatch, err := compareRowCounts(baseContext, ChecksumContext)
+	if err != nil || isDecided {
+		return false, err
+	}
+	if err := ChecksumContext.ReadUniqueKeyRangeMinValues(); err != nil {
+		return false, err
+	}
+	if err := ChecksumContext.ReadUniqueKeyRangeMaxValues(); err != nil {
+		return false, err
+	}
+	if baseContext.ChunkPlanner == types.ScanChunkPlanner {
+		if err := ChecksumContext.PlanChunks(); err != nil {
+			return false, err
+		}
+	}
+	keyRanges := []checksum.KeyRange{{Min: ChecksumContext.UniqueKeyRangeMinValues, Max: ChecksumContext.UniqueKeyRangeMaxValues, IncludeMin: true}}
+	if baseContext.TableThreads > 1 && !baseContext.ConsistentSnapshot {
+		if keyRanges, err = ChecksumContext.SplitKeyRange(baseContext.TableThreads); err != nil {
+			return false, err
+		}
+	}
+	return compareKeyRanges(baseContext, ChecksumContext, keyRanges, rowCountMismatch, startTime)
+}
+
 // ChecksumPerTable first compares total row counts, then verifies chunk checksums one by one.
 // Returns whether the table pair is equal; each table returns exactly one result, which the caller writes to the result channels.
 func (job *ChecksumJob) ChecksumPerTable(baseContext *types.BaseContext, tableContext *types.TableContext) (isEqual bool, err error) {
 	startTime := time.Now()
-	var tableCheckDuration time.Duration
 	defer func() {
 		<-job.ChecksumJobChan
 		job.wg.Done()
@@ -195,28 +604,36 @@ func (job *ChecksumJob) ChecksumPerTable(baseContext *types.BaseContext, tableCo
 
 	ChecksumContext := checksum.NewChecksumContext(baseContext, tableContext)
 	ChecksumContext.JobTracker = job.Tracker
+	ChecksumContext.Throttler = job.Throttler
 	ChecksumContext.ComparisonID = tableContext.ComparisonID
 	ChecksumContext.TrackTableStart()
 	defer func() { ChecksumContext.TrackTableDone(isEqual, err) }()
+	defer func() {
+		if throttledTime := ChecksumContext.ThrottledTime(); throttledTime > 0 {
+			baseContext.Log.Infof("Table pair: %s.%s => %s.%s was throttled for %s.", ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName, throttledTime)
+		}
+	}()
 	baseContext.Log.Infof("Starting check table pair: %s.%s => %s.%s .", ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName)
 
+	// With --consistent-snapshot, every data query of the table runs in a pinned snapshot transaction per side
+	if err := ChecksumContext.OpenSnapshot(); err != nil {
+		return false, err
+	}
+	defer ChecksumContext.CloseSnapshot()
+
+	// A partitioned table is checked partition by partition when the target is partitioned alike
+	partitions, err := ChecksumContext.ReadPartitions()
+	if err != nil {
+		return false, err
+	}
+	if partitions != nil {
+		return checkPartitions(baseContext, ChecksumContext, partitions, startTime)
+	}
+
 	// First verify the full-table count(*) values match
-	if !baseContext.IgnoreRowCountCheck {
-		baseContext.Log.Debugf("DataChecksumByCount of table pair: %s.%s => %s.%s .", ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName)
-		_, isMoreCheckNeeded, sourceRowCount, targetRowCount, err := ChecksumContext.DataChecksumByCount()
-		if err != nil {
-			return false, err
-		}
-		ChecksumContext.SourceRowCount, ChecksumContext.TargetRowCount = sourceRowCount, targetRowCount
-		if !isMoreCheckNeeded {
-			// Row counts differ: still run record-level analysis when differential reporting is enabled
-			if baseContext.EnableDifferentialReporting {
-				runDifferentialAnalysis(baseContext, ChecksumContext)
-			}
-			return false, nil
-		}
-	} else {
-		baseContext.Log.Debugf("Ignore DataChecksumByCount of table pair: %s.%s => %s.%s due to IgnoreRowCountCheck=true.", ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName)
+	isDecided, rowCountMismatch, err := compareRowCounts(baseContext, ChecksumContext)
+	if err != nil || isDecided {
+		return false, err
 	}
 
 	// Use the user-requested check columns, defaulting to all columns of the table
@@ -232,6 +649,9 @@ func (job *ChecksumJob) ChecksumPerTable(baseContext *types.BaseContext, tableCo
 	if err := ChecksumContext.GetUniqueKeys(); err != nil {
 		return false, err
 	}
+	if ChecksumContext.IsKeyless {
+		return checkKeylessTable(baseContext, ChecksumContext, startTime)
+	}
 	baseContext.Log.Debugf("ReadUniqueKeyRangeMinValues of table pair: %s.%s => %s.%s .", ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName)
 	if err := ChecksumContext.ReadUniqueKeyRangeMinValues(); err != nil {
 		return false, err
@@ -240,63 +660,35 @@ func (job *ChecksumJob) ChecksumPerTable(baseContext *types.BaseContext, tableCo
 	if err := ChecksumContext.ReadUniqueKeyRangeMaxValues(); err != nil {
 		return false, err
 	}
-
-	// Compute chunk checksums
-	var hasFurtherRange = true
-	for hasFurtherRange {
-		hasFurtherRange, err = ChecksumContext.CalculateNextIterationRangeEndValues()
-		if err != nil {
+	// On resume, continue after the last chunk recorded for this table; otherwise
+	// split the key range across --table-threads workers. A sampled table draws a new sample.
+	keyRanges := []checksum.KeyRange{{Min: ChecksumContext.UniqueKeyRangeMinValues, Max: ChecksumContext.UniqueKeyRangeMaxValues, IncludeMin: true}}
+	isResumed := !baseContext.IsSampling() && ChecksumContext.ResumeFromCheckpoint()
+	// With --chunk-plan=scan, every chunk boundary is read up front in one pass over the chunk index
+	if baseContext.ChunkPlanner == types.ScanChunkPlanner {
+		if err := ChecksumContext.PlanChunks(); err != nil {
 			return false, err
 		}
-		baseContext.Log.Debugf("CalculateNextIterationRangeEndValues of table pair: %s.%s => %s.%s .", ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName)
-
-		var isChunkChecksumEqual bool
-		var duration time.Duration
-		if hasFurtherRange {
-			// Retry to ride out transient errors and replication lag
-			for i := 0; i < int(ChecksumContext.Context.DefaultNumRetries); i++ {
-				if i != 0 {
-					time.Sleep(1 * time.Second)
-					baseContext.Log.Debugf("IterationQueryChecksum [%s-%s] retry times %d of table pair: %s.%s => %s.%s .", ChecksumContext.ChecksumIterationRangeMinValues.AbstractValues(), ChecksumContext.ChecksumIterationRangeMaxValues.AbstractValues(), i, ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName)
-				} else {
-					baseContext.Log.Debugf("IterationQueryChecksum [%s-%s] of table pair: %s.%s => %s.%s .", ChecksumContext.ChecksumIterationRangeMinValues.AbstractValues(), ChecksumContext.ChecksumIterationRangeMaxValues.AbstractValues(), ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName)
-				}
-				isChunkChecksumEqual, duration, err = ChecksumContext.IterationQueryChecksum()
-				if err == nil && isChunkChecksumEqual {
-					break
-				}
-			}
-			chunkNumber := int(ChecksumContext.GetIteration())
-			ChecksumContext.AddIteration()
-			ChecksumContext.TrackChunk(chunkNumber, isChunkChecksumEqual, err, duration)
-			if err != nil {
-				tableCheckDuration = time.Since(startTime)
-				baseContext.Log.Errorf("Critical: record CRC32 checksum value is not equal of table pair: %s.%s => %s.%s , tableCheckDuration=%+v", ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName, tableCheckDuration)
+	}
+	if baseContext.IsSampling() {
+		// With --sample-percent or --sample-chunks, only random chunks of the table are checked, in a single key range
+		if err := ChecksumContext.PlanSample(); err != nil {
+			return false, err
+		}
+	} else if !isResumed {
+		if baseContext.TableThreads > 1 && baseContext.ConsistentSnapshot {
+			baseContext.Log.Infof("Table pair: %s.%s => %s.%s is checked in a single key range: --consistent-snapshot pins one connection per side.", ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName)
+		} else if baseContext.TableThreads > 1 {
+			if keyRanges, err = ChecksumContext.SplitKeyRange(baseContext.TableThreads); err != nil {
 				return false, err
 			}
-			if !isChunkChecksumEqual {
-				tableCheckDuration = time.Since(startTime)
-				baseContext.Log.Debugf("Debug: Iteration %d, record CRC32 checksum value is not equal of table pair: %s.%s => %s.%s , Duration=%+v", int(ChecksumContext.GetIteration()), ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName, duration)
-				baseContext.Log.Errorf("Critical: record CRC32 checksum value is not equal of table pair: %s.%s => %s.%s , tableCheckDuration=%+v", ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName, tableCheckDuration)
-
-				// If differential reporting is enabled and we found differences, run detailed analysis
-				if baseContext.EnableDifferentialReporting {
-					runDifferentialAnalysis(baseContext, ChecksumContext)
-				}
-
-				return false, nil
-			}
-			baseContext.Log.Debugf("Debug: Iteration %d, record CRC32 checksum value is equal of table pair: %s.%s => %s.%s , Duration=%+v", int(ChecksumContext.GetIteration()), ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName, duration)
 		}
+		ChecksumContext.TrackKeyRangeSplits(len(keyRanges))
 	}
-	estimatedRows := int(ChecksumContext.GetIteration() * ChecksumContext.GetChunkSize())
-	tableCheckDuration = time.Since(startTime)
-	elapsedSecond := int(tableCheckDuration / time.Second)
-	if elapsedSecond < 1 {
-		elapsedSecond = 1
+
+	if isEqual, err = compareKeyRanges(baseContext, ChecksumContext, keyRanges, rowCountMismatch, startTime); !isEqual || err != nil {
+		return false, err
 	}
-	CheckSpeed := estimatedRows / elapsedSecond
-	baseContext.Log.Infof("Info: record CRC32 checksum value is equal of table pair: %s.%s => %s.%s , tableCheckDuration=%+v, tableCheckSpeed= %+v rows/second.", ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName, tableCheckDuration, CheckSpeed)
 	baseContext.Log.Infof("End check table pair: %s.%s => %s.%s .", ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName)
 	return true, nil
 }
@@ -313,11 +705,23 @@ func (job *ChecksumJob) ChecksumPerTableViaTimeColumn(baseContext *types.BaseCon
 
 	ChecksumContext := checksum.NewChecksumContext(baseContext, tableContext)
 	ChecksumContext.JobTracker = job.Tracker
+	ChecksumContext.Throttler = job.Throttler
 	ChecksumContext.ComparisonID = tableContext.ComparisonID
 	ChecksumContext.TrackTableStart()
 	defer func() { ChecksumContext.TrackTableDone(isEqual, err) }()
+	defer func() {
+		if throttledTime := ChecksumContext.ThrottledTime(); throttledTime > 0 {
+			baseContext.Log.Infof("Table pair: %s.%s => %s.%s was throttled for %s.", ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName, throttledTime)
+		}
+	}()
 	baseContext.Log.Infof("Starting check table pair: %s.%s => %s.%s .", ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName)
 
+	// With --consistent-snapshot, every data query of the table runs in a pinned snapshot transaction per side
+	if err := ChecksumContext.OpenSnapshot(); err != nil {
+		return false, err
+	}
+	defer ChecksumContext.CloseSnapshot()
+
 	// Use the user-requested check columns, defaulting to all columns of the table
 	baseContext.Log.Debugf("Get user-request check columns of table pair: %s.%s => %s.%s .", ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName)
 	if ChecksumContext.CheckColumns == nil {
@@ -333,6 +737,9 @@ func (job *ChecksumJob) ChecksumPerTableViaTimeColumn(baseContext *types.BaseCon
 	}
 	baseContext.Log.Debugf("Time column values range [%s-%s] of table pair: %s.%s => %s.%s .", ChecksumContext.Context.SpecifiedDatetimeRangeBegin, ChecksumContext.Context.SpecifiedDatetimeRangeEnd, ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName)
 
+	// On resume, continue after the last time chunk recorded for this table
+	ChecksumContext.ResumeFromCheckpoint()
+
 	// Estimate the number of rows within the time range
 	estimatedRows, err := ChecksumContext.EstimateTableRowsViaExplain()
 	if err != nil {
@@ -351,10 +758,21 @@ func (job *ChecksumJob) ChecksumPerTableViaTimeColumn(baseContext *types.BaseCon
 		var isChunkChecksumEqual bool
 		var duration time.Duration
 		if hasFurtherRange {
+			// Pause here while the replicas lag behind --max-lag or the servers reach --max-load; --critical-load aborts
+			if err = ChecksumContext.Throttle(); err != nil {
+				chunkNumber := int(ChecksumContext.GetIteration())
+				ChecksumContext.AddIteration()
+				ChecksumContext.TrackChunk(chunkNumber, false, err, 0)
+				return false, err
+			}
 			// Retry to ride out transient errors and replication lag
 			for i := 0; i < int(ChecksumContext.Context.DefaultNumRetries); i++ {
 				if i != 0 {
 					time.Sleep(1 * time.Second)
+					// The same snapshots would return the same rows; retry in new ones
+					if err = ChecksumContext.RefreshSnapshot(); err != nil {
+						break
+					}
 					baseContext.Log.Debugf("IterationTimeRangeQueryChecksum [%s-%s] retry times %d of table pair: %s.%s => %s.%s .", ChecksumContext.TimeIterationRangeMinValue, ChecksumContext.TimeIterationRangeMaxValue, i, ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName)
 				} else {
 					baseContext.Log.Debugf("IterationTimeRangeQueryChecksum [%s-%s] of table pair: %s.%s => %s.%s .", ChecksumContext.TimeIterationRangeMinValue, ChecksumContext.TimeIterationRangeMaxValue, ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName)
@@ -367,6 +785,10 @@ func (job *ChecksumJob) ChecksumPerTableViaTimeColumn(baseContext *types.BaseCon
 			chunkNumber := int(ChecksumContext.GetIteration())
 			ChecksumContext.AddIteration()
 			ChecksumContext.TrackChunk(chunkNumber, isChunkChecksumEqual, err, duration)
+			if err == nil && !isChunkChecksumEqual && baseContext.ContinueOnMismatch {
+				baseContext.Log.Errorf("Critical: Iteration %d, record CRC32 checksum value is not equal in time range [%s-%s] of table pair: %s.%s => %s.%s", chunkNumber, ChecksumContext.TimeIterationRangeMinValue, ChecksumContext.TimeIterationRangeMaxValue, ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName)
+				continue
+			}
 			if err != nil {
 				tableCheckDuration = time.Since(startTime)
 				baseContext.Log.Errorf("Critical: record CRC32 checksum value is not equal of table pair: %s.%s => %s.%s , tableCheckDuration=%+v", ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName, tableCheckDuration)
@@ -377,10 +799,9 @@ func (job *ChecksumJob) ChecksumPerTableViaTimeColumn(baseContext *types.BaseCon
 				baseContext.Log.Debugf("Debug: Iteration %d, record CRC32 checksum value is not equal of table pair: %s.%s => %s.%s , Duration=%+v", int(ChecksumContext.GetIteration()), ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName, duration)
 				baseContext.Log.Errorf("Critical: record CRC32 checksum value is not equal of table pair: %s.%s => %s.%s , tableCheckDuration=%+v", ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName, tableCheckDuration)
 
-				// If differential reporting is enabled and we found differences, run detailed analysis.
-				// Note: the differential analysis walks the unique key over the whole table.
+				// If differential reporting is enabled and we found differences, row-diff the different time chunks
 				if baseContext.EnableDifferentialReporting {
-					runDifferentialAnalysis(baseContext, ChecksumContext)
+					runTimeRangeDifferentialAnalysis(baseContext, ChecksumContext, ChecksumContext.DifferentTimeRanges())
 				}
 
 				return false, nil
@@ -389,6 +810,15 @@ func (job *ChecksumJob) ChecksumPerTableViaTimeColumn(baseContext *types.BaseCon
 		}
 	}
 
+	if differentChunks := len(ChecksumContext.PerTableContext.DifferentChunkRanges); differentChunks > 0 {
+		tableCheckDuration = time.Since(startTime)
+		baseContext.Log.Errorf("Critical: record CRC32 checksum value is not equal in %d chunk(s) of table pair: %s.%s => %s.%s , tableCheckDuration=%+v", differentChunks, ChecksumContext.PerTableContext.SourceDatabaseName, ChecksumContext.PerTableContext.SourceTableName, ChecksumContext.PerTableContext.TargetDatabaseName, ChecksumContext.PerTableContext.TargetTableName, tableCheckDuration)
+		if baseContext.EnableDifferentialReporting {
+			runTimeRangeDifferentialAnalysis(baseContext, ChecksumContext, ChecksumContext.DifferentTimeRanges())
+		}
+		return false, nil
+	}
+
 	tableCheckDuration = time.Since(startTime)
 	elapsedSecond := int(tableCheckDuration / time.Second)
 	if elapsedSecond < 1 {
@@ -400,6 +830,99 @@ func (job *ChecksumJob) ChecksumPerTableViaTimeColumn(baseContext *types.BaseCon
 	return true, nil
 }
 
+// logDifferentChunks prints the different chunks of a table pair found with --continue-on-mismatch, at most MaxDisplayDifferences ranges
+func logDifferentChunks(baseContext *types.BaseContext, tableContext *types.TableContext) {
+	chunkRanges := tableContext.DifferentChunkRanges
+	if !baseContext.ContinueOnMismatch || len(chunkRanges) == 0 {
+		return
+	}
+	baseContext.Log.Errorf("Table pair %s.%s => %s.%s has %d different chunk(s):", tableContext.SourceDatabaseName, tableContext.SourceTableName, tableContext.TargetDatabaseName, tableContext.TargetTableName, len(chunkRanges))
+	for i, chunkRange := range chunkRanges {
+		if i == baseContext.MaxDisplayDifferences {
+			baseContext.Log.Errorf("  ... and %d more", len(chunkRanges)-i)
+			break
+		}
+		baseContext.Log.Errorf("  %s", chunkRange)
+	}
+}
+
+// logDifferentColumns prints the check columns whose fingerprints differed in the chunks of a table pair (--column-fingerprints)
+func logDifferentColumns(baseContext *types.BaseContext, tableContext *types.TableContext) {
+	if len(tableContext.DifferentColumns) == 0 {
+		return
+	}
+	baseContext.Log.Errorf("Table pair %s.%s => %s.%s differs in column(s): %s", tableContext.SourceDatabaseName, tableContext.SourceTableName, tableContext.TargetDatabaseName, tableContext.TargetTableName, checksum.FormatDifferentColumns(tableContext.DifferentColumns))
+}
+
+// validateKeyOverrides resolves the --chunk-index and --match-columns keys of the given source
+// tables before any table is checked, so a key that is not unique and NOT NULL on both sides
+// fails the run up front rather than when its table comes up
+func validateKeyOverrides(baseContext *types.BaseContext, keys []string) error {
+	for _, table := range append(baseContext.ChunkIndex.Tables(), baseContext.MatchColumns.Tables()...) {
+		if _, found := baseContext.PairOfSourceAndTargetTables[table]; !found {
+			baseContext.Log.Warnf("Table %s has a --chunk-index or --match-columns entry but is not checked.", table)
+		}
+	}
+	for _, key := range keys {
+		source, target := strings.Split(key, "."), strings.Split(baseContext.PairOfSourceAndTargetTables[key], ".")
+		if baseContext.ChunkIndex.For(source[0], source[1]) == "" && baseContext.MatchColumns.For(source[0], source[1]) == "" {
+			continue
+		}
+		checksumContext := checksum.NewChecksumContext(baseContext, types.NewTableContext(source[0], source[1], target[0], target[1]))
+		if err := checksumContext.GetUniqueKeys(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prefilterTables runs --prefilter on the tables, ParallelThreads at a time, and returns the verdict of
+// each. Decided tables are tracked here; a table whose prefilter fails needs a chunk check.
+func (job *ChecksumJob) prefilterTables(baseContext *types.BaseContext, tableContexts []*types.TableContext) []checksum.PrefilterVerdict {
+	verdicts := make([]checksum.PrefilterVerdict, len(tableContexts))
+	for i := range verdicts {
+		verdicts[i] = checksum.NeedsChunkCheck
+	}
+	if baseContext.Prefilter == types.NoPrefilter {
+		return verdicts
+	}
+	var wg sync.WaitGroup
+	for i, tableContext := range tableContexts {
+		i, tableContext := i, tableContext
+		job.ChecksumJobChan <- 1
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-job.ChecksumJobChan
+				wg.Done()
+			}()
+			ChecksumContext := checksum.NewChecksumContext(baseContext, tableContext)
+			ChecksumContext.JobTracker = job.Tracker
+			ChecksumContext.ComparisonID = tableContext.ComparisonID
+			verdict, reason, err := ChecksumContext.Prefilter()
+			if err != nil {
+				baseContext.Log.Warnf("Prefilter of table pair: %s.%s => %s.%s failed, %v; checking its chunks.", tableContext.SourceDatabaseName, tableContext.SourceTableName, tableContext.TargetDatabaseName, tableContext.TargetTableName, err)
+				return
+			}
+			baseContext.Log.Infof("Prefilter of table pair: %s.%s => %s.%s: %s (%s).", tableContext.SourceDatabaseName, tableContext.SourceTableName, tableContext.TargetDatabaseName, tableContext.TargetTableName, verdict, reason)
+			if verdict != checksum.NeedsChunkCheck {
+				ChecksumContext.TrackTableStart()
+				ChecksumContext.TrackPrefilter()
+				ChecksumContext.TrackTableDone(verdict == checksum.DefinitelyEqual, nil)
+			}
+			verdicts[i] = verdict
+		}()
+	}
+	wg.Wait()
+
+	counts := map[checksum.PrefilterVerdict]int{}
+	for _, verdict := range verdicts {
+		counts[verdict]++
+	}
+	baseContext.Log.Infof("Prefilter: %d tables definitely equal, %d definitely different, %d need a chunk check.", counts[checksum.DefinitelyEqual], counts[checksum.DefinitelyDifferent], counts[checksum.NeedsChunkCheck])
+	return verdicts
+}
+
 // checksum runs the check across all table pairs
 func (job *ChecksumJob) checksum(baseContext *types.BaseContext) {
 	// Build the source and target table pairs: from the tracking database on
@@ -438,7 +961,14 @@ func (job *ChecksumJob) checksum(baseContext *types.BaseContext) {
 		baseContext.Log.Infof("Table map: %s => %s .", key, baseContext.PairOfSourceAndTargetTables[key])
 	}
 
+	if err := validateKeyOverrides(baseContext, keys); err != nil {
+		baseContext.Log.Errorf("Validating --chunk-index and --match-columns failed, %s", err.Error())
+		baseContext.PanicAbort <- err
+		return
+	}
+
 	isDatetimeColumnSpecified := baseContext.IsDatetimeColumnSpecified()
+	var tableContexts []*types.TableContext
 	for _, key := range keys {
 		sourceFullTableName := key
 		targetFullTableName := baseContext.PairOfSourceAndTargetTables[key]
@@ -448,7 +978,18 @@ func (job *ChecksumJob) checksum(baseContext *types.BaseContext) {
 		targetTable := strings.Split(targetFullTableName, ".")[1]
 		tableContext := types.NewTableContext(sourceDatabase, sourceTable, targetDatabase, targetTable)
 		tableContext.ComparisonID = comparisonIDs[sourceFullTableName]
+		tableContexts = append(tableContexts, tableContext)
+	}
 
+	// With --prefilter, tables decided as a whole skip the chunk check
+	verdicts := job.prefilterTables(baseContext, tableContexts)
+	for i, tableContext := range tableContexts {
+		if verdicts[i] != checksum.NeedsChunkCheck {
+			baseContext.ChecksumResChan <- verdicts[i] == checksum.DefinitelyEqual
+			baseContext.ChecksumErrChan <- nil
+			continue
+		}
+		tableContext := tableContext
 		job.ChecksumJobChan <- 1
 		job.wg.Add(1)
 		// Check via primary key or time column. Each table writes exactly one (result, error) pair to the channels.
@@ -474,6 +1015,11 @@ func (job *ChecksumJob) checksum(baseContext *types.BaseContext) {
 			baseContext.Log.Errorf("%s", err.Error())
 		}
 	}
+	// Every table has sent its result, so its list of different chunks is complete
+	for _, tableContext := range tableContexts {
+		logDifferentChunks(baseContext, tableContext)
+		logDifferentColumns(baseContext, tableContext)
+	}
 	if tableResultEqualNum == tableNum {
 		baseContext.Log.Infof("All %d pairs of tables check result is equal.", tableNum)
 	} else {
@@ -517,20 +1063,51 @@ func main() {
 	flag.StringVar(&baseContext.TargetDBPass, "target-db-password", "", "MySQL password")
 	flag.IntVar(&baseContext.Timeout, "conn-db-timeout", 60, "connect db timeout")
 	flag.StringVar(&baseContext.RequestedColumnNames, "check-column-names", "", "Column names to check,eg: col1,col2,col3. By default, all columns are used.")
+	chunkIndex := flag.String("chunk-index", "", "Source index to chunk by instead of the picked unique key, for every table or per table as db.table=index;db.table=index. Without --match-columns the index's columns are matched on")
+	partitions := flag.String("partitions", "", "Partitions to check of partitioned tables, for every table or per table as db.table=p1,p2;db.table=p3. Partitioned tables are checked partition by partition when the target is partitioned alike; by default every partition is checked")
+	matchColumns := flag.String("match-columns", "", "Columns to match rows on instead of the picked unique key, for every table or per table as db.table=col1,col2;db.table=col1. They must be unique and NOT NULL on source and target")
 	flag.StringVar(&baseContext.SpecifiedDatetimeColumn, "specified-time-column", "", "Specified time column for range dataCheck.")
 	flag.DurationVar(&baseContext.SpecifiedTimeRangePerStep, "time-range-per-step", 5*time.Minute, "time range per step for specified time column check,default 5m,eg:1h/2m/3s/4ms")
 	specifiedDatetimeRangeBegin := flag.String("specified-time-begin", "", "Specified begin time of time column to check.")
 	specifiedDatetimeRangeEnd := flag.String("specified-time-end", "", "Specified end time of time column to check.")
 	chunkSize := flag.Int64("chunk-size", 1000, "amount of rows to handle in each iteration (allowed range: 10-100,000)")
+	flag.DurationVar(&baseContext.ChunkTime, "chunk-time", 0, "Adjust the chunk size per table so each chunk query takes about this long, eg: 500ms. --chunk-size is the starting size. Default 0: fixed chunk size.")
+	chunkSizeMin := flag.Int64("chunk-size-min", 10, "Lower bound of the adaptive chunk size used with --chunk-time")
+	chunkSizeMax := flag.Int64("chunk-size-max", 100000, "Upper bound of the adaptive chunk size used with --chunk-time")
+	flag.BoolVar(&baseContext.ConsistentSnapshot, "consistent-snapshot", false, "Check each table inside consistent-snapshot transactions: the source position is recorded and the target's applier runs up to exactly its GTID set before the target's snapshot is taken (needs gtid_mode=ON, and the privilege to stop and start the target's applier)")
+	flag.DurationVar(&baseContext.SnapshotWaitTimeout, "snapshot-wait-timeout", time.Minute, "How long the target may take to reach the source snapshot's GTID set with --consistent-snapshot")
+	flag.DurationVar(&baseContext.MaxLag, "max-lag", 0, "Pause between chunks while the replication lag of the target or a throttle control replica exceeds this duration, e.g. 10s (0 disables throttling)")
+	flag.StringVar(&baseContext.ThrottleControlReplicas, "throttle-control-replicas", "", "Comma-separated host[:port] list of extra replicas whose lag is checked against --max-lag, connected with the target credentials")
+	flag.StringVar(&baseContext.ReplicationLagQuery, "replication-lag-query", "", "Query returning the replication lag in seconds (e.g. from a heartbeat table), used instead of SHOW REPLICA STATUS with --max-lag")
+	flag.DurationVar(&baseContext.MaxThrottleTime, "max-throttle-time", time.Hour, "Fail a table once a single throttle pause lasts this long, e.g. when the lag cannot be read or replication stays stopped (0 waits indefinitely)")
+	maxLoad := flag.String("max-load", "", "Comma-delimited status=threshold list, e.g. 'Threads_running=50,Innodb_row_lock_current_waits=10'. Pause between chunks while any of them is reached on the source or target")
+	criticalLoad := flag.String("critical-load", "", "Comma-delimited status=threshold list, e.g. 'Threads_running=200'. Abort when any of them is reached on the source or target; a tracked job can then be resumed with --resume-job-id")
 	defaultRetries := flag.Int64("default-retries", 10, "Default number of retries for various operations before panicking")
 	flag.BoolVar(&baseContext.EnableDifferentialReporting, "enable-differential-reporting", false, "Enable detailed differential reporting showing which records differ by primary key")
+	flag.BoolVar(&baseContext.ContinueOnMismatch, "continue-on-mismatch", false, "Keep checking a table after a mismatched chunk and list every different chunk in the summary; differential reporting then examines only those chunks")
+	flag.BoolVar(&baseContext.SkipTargetSweep, "skip-target-sweep", false, "Keep the differential analysis to the analyzed key ranges: do not sweep the target for rows whose keys fall outside the source key range (reported as target-only otherwise)")
+	flag.BoolVar(&baseContext.EnableChunkBisection, "enable-chunk-bisection", false, "Bisect each mismatched chunk with aggregate checksums so only its different pieces are compared row by row (implies --continue-on-mismatch and --enable-differential-reporting)")
+	flag.Int64Var(&baseContext.BisectRowThreshold, "bisect-row-threshold", 100, "Stop bisecting a mismatched chunk once a piece holds at most this many source rows")
+	flag.BoolVar(&baseContext.ColumnFingerprints, "column-fingerprints", false, "Fingerprint every check column of a mismatched chunk on its own to report which columns differ, per chunk and per table (unique-key chunks only)")
+	flag.IntVar(&baseContext.DiffThreads, "diff-threads", 1, "Row-diff this many chunks of a table in parallel during the differential analysis (not with --consistent-snapshot); differences are still reported in key order")
 	flag.IntVar(&baseContext.MaxSampleDifferences, "max-sample-differences", 100, "Maximum number of sample differences to collect during analysis (default: 100)")
 	flag.IntVar(&baseContext.MaxDisplayDifferences, "max-display-differences", 10, "Maximum number of differences to display in output (default: 10)")
 	flag.BoolVar(&baseContext.GenerateSyncSQL, "generate-sync-sql", false, "Generate REPLACE INTO statements for synchronizing differences to a file")
 	flag.StringVar(&baseContext.SyncSQLFile, "sync-sql-file", "", "Output file for sync SQL statements (default: stdout if not specified)")
+	hashFunction := flag.String("hash-function", string(types.CRC32Hash), "Row hash of the checksum queries: crc32, md5, sha1, sha2-256, or split-md5 (md5 rows, chunks XOR both 64-bit halves of the digest like pt-table-checksum). Digest chunk checksums XOR the leading 64 bits of each row digest")
 	flag.BoolVar(&baseContext.IsSuperSetAsEqual, "is-superset-as-equal", false, "Shall we think that the records in target table is the superset of the source as equal? By default, we think the records are exactly equal as equal.")
+	flag.BoolVar(&baseContext.IgnoreQueryPlan, "ignore-query-plan", false, "Check a table even when EXPLAIN shows its chunk queries would not range over the chunk index on the source or target (by default such a table is refused)")
 	flag.BoolVar(&baseContext.IgnoreRowCountCheck, "ignore-row-count-check", false, "Shall we ignore check by counting rows? Default: false")
 	flag.IntVar(&baseContext.ParallelThreads, "threads", 1, "Parallel threads of table checksum.")
+	flag.IntVar(&baseContext.TableThreads, "table-threads", 1, "Split the unique-key range of each table into this many ranges checked in parallel (not with --specified-time-column or --consistent-snapshot); a table is never split below one chunk per range")
+	chunkPlanner := flag.String("chunk-plan", string(types.ProbeChunkPlanner), "How chunk boundaries are found: probe (a LIMIT/OFFSET query per chunk) or scan (one pass over the chunk index before the chunk loop, keeping every chunk-size-th key; the plan is tracked and reused on resume)")
+	flag.Float64Var(&baseContext.ChunkSizeLimit, "chunk-size-limit", 0, "Count each chunk's rows on the target before checking it; a chunk with more than this many times the chunk size rows is oversized and handled by --oversized-chunk-action. 0 turns the count off")
+	oversizedChunkAction := flag.String("oversized-chunk-action", string(types.SplitOversizedChunk), "What to do with an oversized chunk (see --chunk-size-limit): split (check it in chunk-size pieces of target rows), skip (leave it unchecked, record it as skipped and report the table as not equal) or abort (fail the table)")
+	flag.Float64Var(&baseContext.SamplePercent, "sample-percent", 0, "Check only this percentage of each table's chunks, picked at random across the unique-key range, and report the confidence that the table is consistent; with tracking, later runs sample other chunks. 0 checks every chunk")
+	flag.IntVar(&baseContext.SampleChunks, "sample-chunks", 0, "Check only this many random chunks of each table, like --sample-percent")
+	prefilter := flag.String("prefilter", string(types.NoPrefilter), "Decide tables as a whole before the chunk check: checksum-table (CHECKSUM TABLE where engines, row formats and column definitions match, metadata otherwise; a difference only decides a table between servers of the same version, and CHECKSUM TABLE holds a read lock on the table while it runs), metadata (information_schema.TABLES only: row counts of MyISAM/Aria/MEMORY and UPDATE_TIME against the last tracked equal result) or off. Only tables left undecided are chunk checked")
+	flag.IntVar(&baseContext.ChunkLookahead, "chunk-lookahead", 2, "Chunk boundaries probed on the source ahead of the chunk being checked, overlapping the probe with the checksum queries; 0 probes each boundary only after the previous chunk. Off with --consistent-snapshot")
+	flag.IntVar(&baseContext.KeylessBuckets, "keyless-buckets", 64, "Compare a table without a unique key free of NULLs in this many hash buckets of its rows, each by row count and checksum; 0 fails such tables")
 	flag.BoolVar(&baseContext.EnableTracking, "enable-tracking", false, "Persist job/table/chunk results to a tracking database (pt-table-checksum style).")
 	flag.StringVar(&baseContext.TrackingDBHost, "tracking-db-host", "", "Tracking MySQL hostname (default: target-db-host).")
 	flag.IntVar(&baseContext.TrackingDBPort, "tracking-db-port", 0, "Tracking MySQL port (default: target-db-port).")
@@ -557,9 +1134,64 @@ func main() {
 	if err := baseContext.SetSpecifiedDatetimeRange(*specifiedDatetimeRangeBegin, *specifiedDatetimeRangeEnd); err != nil {
 		baseContext.Log.Fatalf("Illegal time range for time column (%v), please check!", err)
 	}
+	parsedHashFunction, err := types.ParseHashFunction(*hashFunction)
+	if err != nil {
+		baseContext.Log.Fatalf("Illegal --hash-function (%v), please check!", err)
+	}
+	baseContext.HashFunction = parsedHashFunction
+	if baseContext.ChunkPlanner, err = types.ParseChunkPlanner(*chunkPlanner); err != nil {
+		baseContext.Log.Fatalf("Illegal --chunk-plan (%v), please check!", err)
+	}
+	if baseContext.Prefilter, err = types.ParsePrefilter(*prefilter); err != nil {
+		baseContext.Log.Fatalf("Illegal --prefilter (%v), please check!", err)
+	}
+	if baseContext.OversizedChunkAction, err = types.ParseOversizedChunkAction(*oversizedChunkAction); err != nil {
+		baseContext.Log.Fatalf("Illegal --oversized-chunk-action (%v), please check!", err)
+	}
+	if baseContext.ChunkSizeLimit < 0 {
+		baseContext.Log.Fatalf("Illegal --chunk-size-limit (%v), please check!", baseContext.ChunkSizeLimit)
+	}
+	if baseContext.SamplePercent < 0 || baseContext.SamplePercent > 100 || baseContext.SampleChunks < 0 {
+		baseContext.Log.Fatalf("Illegal --sample-percent (%v) or --sample-chunks (%d), please check!", baseContext.SamplePercent, baseContext.SampleChunks)
+	}
+	if baseContext.SamplePercent > 0 && baseContext.SampleChunks > 0 {
+		baseContext.Log.Fatalf("--sample-percent and --sample-chunks cannot be used together, please check!")
+	}
+	if baseContext.IsSampling() && baseContext.SpecifiedDatetimeColumn != "" {
+		baseContext.Log.Fatalf("--sample-percent and --sample-chunks do not work with --specified-time-column, please check!")
+	}
+	if err := baseContext.ReadMaxLoad(*maxLoad); err != nil {
+		baseContext.Log.Fatalf("Illegal --max-load (%v), please check!", err)
+	}
+	if err := baseContext.ReadCriticalLoad(*criticalLoad); err != nil {
+		baseContext.Log.Fatalf("Illegal --critical-load (%v), please check!", err)
+	}
+	if baseContext.ChunkIndex, err = types.ParseTableOptions(*chunkIndex); err != nil {
+		baseContext.Log.Fatalf("Illegal --chunk-index (%v), please check!", err)
+	}
+	if baseContext.MatchColumns, err = types.ParseTableOptions(*matchColumns); err != nil {
+		baseContext.Log.Fatalf("Illegal --match-columns (%v), please check!", err)
+	}
+	if baseContext.Partitions, err = types.ParseTableOptions(*partitions); err != nil {
+		baseContext.Log.Fatalf("Illegal --partitions (%v), please check!", err)
+	}
+	if len(baseContext.Partitions) > 0 && (baseContext.IsSampling() || baseContext.SpecifiedDatetimeColumn != "") {
+		baseContext.Log.Fatalf("--partitions does not work with --sample-percent, --sample-chunks or --specified-time-column, please check!")
+	}
+	if baseContext.ColumnFingerprints && baseContext.SpecifiedDatetimeColumn != "" {
+		baseContext.Log.Fatalf("--column-fingerprints does not work with --specified-time-column, please check!")
+	}
 	baseContext.SetChunkSize(*chunkSize)
+	baseContext.SetChunkSizeBounds(*chunkSizeMin, *chunkSizeMax)
 	baseContext.SetDefaultNumRetries(*defaultRetries)
 	baseContext.SetLogLevel(*debug, *logFile)
+	if baseContext.EnableChunkBisection {
+		baseContext.ContinueOnMismatch = true
+		baseContext.EnableDifferentialReporting = true
+	}
+	if baseContext.BisectRowThreshold < 1 {
+		baseContext.BisectRowThreshold = 1
+	}
 
 	// GenerateSyncSQL appends per table within a run; truncate any stale file
 	// from a previous run so the output only contains this run's statements.
@@ -603,14 +1235,35 @@ func main() {
 		} else {
 			ChecksumJob.Tracker, err = tracking.NewJobTracker(trackingDB,
 				fmt.Sprintf("%s:%d", baseContext.SourceDBHost, baseContext.SourceDBPort),
-				fmt.Sprintf("%s:%d", baseContext.TargetDBHost, baseContext.TargetDBPort))
+				fmt.Sprintf("%s:%d", baseContext.TargetDBHost, baseContext.TargetDBPort),
+				string(baseContext.HashFunction), builder.RowEncoding)
 		}
 		if err != nil {
 			baseContext.Log.Fatalf("Tracking job initiate failed: %v", err)
 		}
+		// A resumed job keeps hashing rows the way its recorded chunks were hashed
+		if hashFunction := types.HashFunction(ChecksumJob.Tracker.HashFunction); hashFunction != "" && hashFunction != baseContext.HashFunction {
+			baseContext.Log.Infof("Job %s was started with --hash-function=%s; using it instead of %s.", ChecksumJob.Tracker.JobID, hashFunction, baseContext.HashFunction)
+			baseContext.HashFunction = hashFunction
+		}
+		// Chunks hashed with another row encoding cannot be compared with the ones hashed now
+		if rowEncoding := ChecksumJob.Tracker.RowEncoding; rowEncoding != builder.RowEncoding {
+			baseContext.Log.Fatalf("Job %s recorded its chunks with the %s row encoding, this version hashes rows with %s; it cannot be resumed, please start a new job!", ChecksumJob.Tracker.JobID, rowEncoding, builder.RowEncoding)
+		}
 		baseContext.Log.Infof("Tracking enabled, job_id=%s (database %s).", ChecksumJob.Tracker.JobID, baseContext.TrackingDBName)
 	}
 
+	// Throttle the chunk loops on replication lag and server load
+	if baseContext.MaxLag > 0 || len(baseContext.GetMaxLoad()) > 0 || len(baseContext.GetCriticalLoad()) > 0 {
+		throttler, err := throttle.NewThrottler(baseContext)
+		if err != nil {
+			baseContext.Log.Fatalf("Throttler initiate failed: %v", err)
+		}
+		throttler.Start()
+		defer throttler.Stop()
+		ChecksumJob.Throttler = throttler
+	}
+
 	ChecksumJob.checksum(baseContext)
 
 }
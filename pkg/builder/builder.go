@@ -189,59 +189,137 @@ func BuildRangePreparedComparison(columns *types.ColumnList, args []interface{},
 	return BuildRangeComparison(columns.Names(), values, args, comparisonSign)
 }
 
-// BuildChunkChecksumSQL builds the SQL computing either the aggregated chunk CRC32 (BIT_XOR) or the per-row CRC32 values.
+// RowEncoding names the check-column encoding of BuildCheckColumnsListing. It is recorded on
+// tracked jobs; jobs recorded before NULL markers were added used "concat-ws-hex".
+const RowEncoding = "isnull-coalesce-hex"
+
+// BuildCheckColumnsListing encodes each check column as ISNULL(col) followed by its hex value,
+// or an empty string when NULL.
+// CONCAT_WS skips NULL arguments, so a bare hex(col) listing hashes (NULL, 'x') like ('x', NULL)
+// and NULL like an empty string; the marker keeps every column in place and tells them apart.
+func BuildCheckColumnsListing(columnNames []string) string {
+	encoded := make([]string, len(columnNames))
+	for i, name := range columnNames {
+		escapedName := EscapeName(name)
+		encoded[i] = fmt.Sprintf("ISNULL(%s), COALESCE(hex(%s), '')", escapedName, escapedName)
+	}
+	return strings.Join(encoded, ", ")
+}
+
+// BuildRowHashExpression returns the SQL hashing one row, as lower-case hex, over a
+// comma-separated listing of encoded columns
+func BuildRowHashExpression(hashFunction types.HashFunction, columnsListing string) string {
+	concat := fmt.Sprintf("CONCAT_WS('#', %s)", columnsListing)
+	switch hashFunction {
+	case types.MD5Hash, types.SplitMD5Hash:
+		return fmt.Sprintf("LOWER(MD5(%s))", concat)
+	case types.SHA1Hash:
+		return fmt.Sprintf("LOWER(SHA1(%s))", concat)
+	case types.SHA256Hash:
+		return fmt.Sprintf("LOWER(SHA2(%s, 256))", concat)
+	default:
+		return fmt.Sprintf("COALESCE(LOWER(CONV(cast(crc32(%s) as UNSIGNED), 10, 16)), 0)", concat)
+	}
+}
+
+// twoTo64 is the modulus of the additive chunk checksum. SUM over BIGINT UNSIGNED yields a
+// DECIMAL, so the sum cannot overflow before it is reduced.
+const twoTo64 = "18446744073709551616"
+
+// chunkHashSlices returns the unsigned integers a chunk aggregates for every row: the CRC32
+// value, the leading 64 bits of the digests, or, for split-md5, both 64-bit halves of MD5
+func chunkHashSlices(hashFunction types.HashFunction, columnsListing string) []string {
+	concat := fmt.Sprintf("CONCAT_WS('#', %s)", columnsListing)
+	digestSlice := func(digest string, start int) string {
+		return fmt.Sprintf("cast(CONV(SUBSTRING(%s, %d, 16), 16, 10) as UNSIGNED)", digest, start)
+	}
+	switch hashFunction {
+	case types.MD5Hash:
+		return []string{digestSlice(fmt.Sprintf("MD5(%s)", concat), 1)}
+	case types.SHA1Hash:
+		return []string{digestSlice(fmt.Sprintf("SHA1(%s)", concat), 1)}
+	case types.SHA256Hash:
+		return []string{digestSlice(fmt.Sprintf("SHA2(%s, 256)", concat), 1)}
+	case types.SplitMD5Hash:
+		digest := fmt.Sprintf("MD5(%s)", concat)
+		return []string{digestSlice(digest, 1), digestSlice(digest, 17)}
+	default:
+		return []string{fmt.Sprintf("cast(crc32(%s) as UNSIGNED)", concat)}
+	}
+}
+
+// aggregateChunkHash renders an aggregate of every hash slice as hex. Digest slices are padded
+// to 16 digits and concatenated; the crc32 aggregate keeps its unpadded form.
+func aggregateChunkHash(hashFunction types.HashFunction, slices []string, aggregate func(slice string) string) string {
+	switch hashFunction {
+	case types.MD5Hash, types.SHA1Hash, types.SHA256Hash, types.SplitMD5Hash:
+	default:
+		return fmt.Sprintf("COALESCE(LOWER(CONV(%s, 10, 16)), 0)", aggregate(slices[0]))
+	}
+	hexSlices := make([]string, len(slices))
+	for i, slice := range slices {
+		hexSlices[i] = fmt.Sprintf("LPAD(CONV(%s, 10, 16), 16, '0')", aggregate(slice))
+	}
+	if len(hexSlices) == 1 {
+		return fmt.Sprintf("LOWER(%s)", hexSlices[0])
+	}
+	return fmt.Sprintf("LOWER(CONCAT(%s))", strings.Join(hexSlices, ", "))
+}
+
+// buildChunkHashExpression returns the SQL XOR-aggregating the row hashes of a chunk
+func buildChunkHashExpression(hashFunction types.HashFunction, columnsListing string) string {
+	return aggregateChunkHash(hashFunction, chunkHashSlices(hashFunction, columnsListing), func(slice string) string {
+		return fmt.Sprintf("BIT_XOR(%s)", slice)
+	})
+}
+
+// buildChunkHashSumExpression returns the SQL summing the row hashes of a chunk modulo 2^64.
+// Unlike BIT_XOR, the sum does not cancel out pairs of identical rows.
+func buildChunkHashSumExpression(hashFunction types.HashFunction, columnsListing string) string {
+	return aggregateChunkHash(hashFunction, chunkHashSlices(hashFunction, columnsListing), func(slice string) string {
+		return fmt.Sprintf("MOD(COALESCE(SUM(%s), 0), %s)", slice, twoTo64)
+	})
+}
+
+// buildCheckClause returns the select expressions of a checksum query: the row count, chunk hash
+// and chunk hash sum (checkLevel 1), or the row hashes (checkLevel 2)
+func buildCheckClause(hashFunction types.HashFunction, columnsListing string, checkLevel int64) (string, error) {
+	switch checkLevel {
+	case 1:
+		return fmt.Sprintf("COUNT(*) as ROW_COUNT, %s as CRC32XOR, %s as HASH_SUM",
+			buildChunkHashExpression(hashFunction, columnsListing),
+			buildChunkHashSumExpression(hashFunction, columnsListing)), nil
+	case 2:
+		return BuildRowHashExpression(hashFunction, columnsListing) + " as CRC32", nil
+	}
+	return "", fmt.Errorf("wrong checkLevel %d", checkLevel)
+}
+
+// BuildChunkChecksumSQL builds the SQL computing either the aggregated chunk checksum (row count, BIT_XOR and SUM
+// of the row hashes) or the per-row hash values. The examples below use the default crc32 hash function.
 // The chunk range is (rangeMin, rangeMax]; the first chunk is [rangeMin, rangeMax].
 // The final SQL looks like: select /* dataChecksum */
 //
-//	                  COALESCE(LOWER(CONV(BIT_XOR(cast(crc32(CONCAT_WS('#',id, ftime, c1, c2)) as UNSIGNED)), 10, 16)), 0) as CRC32XOR
+//	                  COUNT(*) as ROW_COUNT,
+//	                  COALESCE(LOWER(CONV(BIT_XOR(cast(crc32(CONCAT_WS('#',id, ftime, c1, c2)) as UNSIGNED)), 10, 16)), 0) as CRC32XOR,
+//	                  COALESCE(LOWER(CONV(MOD(COALESCE(SUM(cast(crc32(CONCAT_WS('#',id, ftime, c1, c2)) as UNSIGNED)), 0), 18446744073709551616), 10, 16)), 0) as HASH_SUM
 //	               OR COALESCE(LOWER(CONV(cast(crc32(CONCAT_WS('#',id, ftime, c1, c2)) as UNSIGNED), 10, 16)), 0) as CRC32
 //	             from test.t_time
 //	            where (((col1 > ?) or ((col1 = ?) and (col2 > ?)) or (((col1 = ?) and (col2 = ?)) and (col3 > ?)))
 //		             and ((col1 < ?) or ((col1 = ?) and (col2 < ?)) or (((col1 = ?) and (col2 = ?)) and (col3 < ?)) or ((col1 = ?) and (col2 = ?) and (col3 = ?))))
-func BuildChunkChecksumSQL(databaseName, tableName string, checkColumns, uniqueKeyColumns *types.ColumnList, rangeStartValues, rangeEndValues []string, rangeStartArgs, rangeEndArgs []interface{}, includeRangeStartValues bool, checkLevel int64) (result string, explodedArgs []interface{}, err error) {
+func BuildChunkChecksumSQL(databaseName, tableName, partitionName string, checkColumns, uniqueKeyColumns *types.ColumnList, rangeStartValues, rangeEndValues []string, rangeStartArgs, rangeEndArgs []interface{}, includeRangeStartValues bool, checkLevel int64, hashFunction types.HashFunction, indexName string) (result string, explodedArgs []interface{}, err error) {
 	databaseName = EscapeName(databaseName)
 	tableName = EscapeName(tableName)
 
-	checkColumnNames := duplicateNames(checkColumns.Names())
-	for i := range checkColumnNames {
-		checkColumnNames[i] = EscapeName(checkColumnNames[i])
-	}
-	checkColumnNamesListing := strings.Join(checkColumnNames, "), hex(")
-	var builder strings.Builder
-	builder.WriteString("hex(")
-	builder.WriteString(checkColumnNamesListing)
-	builder.WriteString(")")
-	checkColumnNamesListing = builder.String()
+	checkColumnNamesListing := BuildCheckColumnsListing(checkColumns.Names())
 
-	// ">" normally; ">=" when the range start value is included (first chunk)
-	var minRangeComparisonSign ValueComparisonSign = GreaterThanComparisonSign
-	if includeRangeStartValues {
-		minRangeComparisonSign = GreaterThanOrEqualsComparisonSign
-	}
-
-	// Build the range-start WHERE condition over the unique key.
-	rangeStartComparison, rangeExplodedArgs, err := BuildRangeComparison(uniqueKeyColumns.Names(), rangeStartValues, rangeStartArgs, minRangeComparisonSign)
+	rangeStartComparison, rangeEndComparison, explodedArgs, err := buildChunkRangeComparisons(uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, includeRangeStartValues)
 	if err != nil {
 		return "", explodedArgs, err
 	}
-	explodedArgs = append(explodedArgs, rangeExplodedArgs...)
 
-	// Build the range-end WHERE condition over the unique key.
-	rangeEndComparison, rangeExplodedArgs, err := BuildRangeComparison(uniqueKeyColumns.Names(), rangeEndValues, rangeEndArgs, LessThanOrEqualsComparisonSign)
+	checkClause, err := buildCheckClause(hashFunction, checkColumnNamesListing, checkLevel)
 	if err != nil {
-		return "", explodedArgs, err
-	}
-	explodedArgs = append(explodedArgs, rangeExplodedArgs...)
-
-	var checkClause string
-	switch checkLevel {
-	case 1:
-		checkClause = fmt.Sprintf("COALESCE(LOWER(CONV(BIT_XOR(cast(crc32(CONCAT_WS('#', %s)) as UNSIGNED)), 10, 16)), 0) as CRC32XOR",
-			checkColumnNamesListing)
-	case 2:
-		checkClause = fmt.Sprintf("COALESCE(LOWER(CONV(cast(crc32(CONCAT_WS('#', %s)) as UNSIGNED), 10, 16)), 0) as CRC32",
-			checkColumnNamesListing)
-	default:
 		return "", nil, fmt.Errorf("critical: table %s.%s wrong checkLevelFlag input in BuildChunkChecksumSQL",
 			databaseName, tableName)
 	}
@@ -260,26 +338,156 @@ func BuildChunkChecksumSQL(databaseName, tableName string, checkColumns, uniqueK
 
 	result = fmt.Sprintf(`
       select /* dataChecksum %s.%s */ %s
-        from %s.%s 
+        from %s.%s%s%s
        where (%s and %s)
       order by %s
-    `, databaseName, tableName, checkClause, databaseName, tableName,
+    `, databaseName, tableName, checkClause, databaseName, tableName, PartitionClause(partitionName), indexHint(indexName),
 		rangeStartComparison, rangeEndComparison, strings.Join(uniqueKeyColumnAscending, ", "),
 	)
 	return result, explodedArgs, nil
 }
 
+// buildChunkRangeComparisons builds the WHERE conditions of a chunk over the unique key: after the
+// range start (">" normally; ">=" when the range start value is included, first chunk) and up to
+// and including the range end
+func buildChunkRangeComparisons(uniqueKeyColumns *types.ColumnList, rangeStartValues, rangeEndValues []string, rangeStartArgs, rangeEndArgs []interface{}, includeRangeStartValues bool) (rangeStartComparison, rangeEndComparison string, explodedArgs []interface{}, err error) {
+	var minRangeComparisonSign ValueComparisonSign = GreaterThanComparisonSign
+	if includeRangeStartValues {
+		minRangeComparisonSign = GreaterThanOrEqualsComparisonSign
+	}
+
+	rangeStartComparison, rangeExplodedArgs, err := BuildRangeComparison(uniqueKeyColumns.Names(), rangeStartValues, rangeStartArgs, minRangeComparisonSign)
+	if err != nil {
+		return "", "", explodedArgs, err
+	}
+	explodedArgs = append(explodedArgs, rangeExplodedArgs...)
+
+	rangeEndComparison, rangeExplodedArgs, err = BuildRangeComparison(uniqueKeyColumns.Names(), rangeEndValues, rangeEndArgs, LessThanOrEqualsComparisonSign)
+	if err != nil {
+		return "", "", explodedArgs, err
+	}
+	explodedArgs = append(explodedArgs, rangeExplodedArgs...)
+	return rangeStartComparison, rangeEndComparison, explodedArgs, nil
+}
+
 // BuildRangeChecksumPreparedQuery returns the prepared chunked CRC32 checksum SQL; the chunk range is (rangeMin, rangeMax], the first chunk [rangeMin, rangeMax]
-func BuildRangeChecksumPreparedQuery(databaseName, tableName string, checkColumns, uniqueKeyColumns *types.ColumnList, rangeStartArgs, rangeEndArgs []interface{}, includeRangeStartValues bool, checkLevel int64) (result string, explodedArgs []interface{}, err error) {
+func BuildRangeChecksumPreparedQuery(databaseName, tableName, partitionName string, checkColumns, uniqueKeyColumns *types.ColumnList, rangeStartArgs, rangeEndArgs []interface{}, includeRangeStartValues bool, checkLevel int64, hashFunction types.HashFunction, indexName string) (result string, explodedArgs []interface{}, err error) {
 	rangeStartValues := buildColumnsPreparedValues(uniqueKeyColumns)
 	rangeEndValues := buildColumnsPreparedValues(uniqueKeyColumns)
-	return BuildChunkChecksumSQL(databaseName, tableName, checkColumns, uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, includeRangeStartValues, checkLevel)
+	return BuildChunkChecksumSQL(databaseName, tableName, partitionName, checkColumns, uniqueKeyColumns, rangeStartValues, rangeEndValues, rangeStartArgs, rangeEndArgs, includeRangeStartValues, checkLevel, hashFunction, indexName)
 }
 
-// BuildTimeRangeChecksumSQL builds the chunked CRC32 SQL over a time column.
+// BuildRangeColumnFingerprintsPreparedQuery returns the prepared SQL fingerprinting every check column of
+// a chunk on its own, in check column order: the BIT_XOR of the CRC32 of the column's encoded value (see
+// BuildCheckColumnsListing), whatever --hash-function is. Comparing the fingerprints of a mismatched chunk
+// tells which columns differ. The chunk range is (rangeMin, rangeMax], the first chunk [rangeMin, rangeMax].
+// The final SQL looks like: select /* dataChecksum */
+//
+//	      BIT_XOR(crc32(CONCAT_WS('#', ISNULL(`c1`), COALESCE(hex(`c1`), '')))) as FINGERPRINT_1, ...
+//	 from test.t1
+//	where (... and ...)
+func BuildRangeColumnFingerprintsPreparedQuery(databaseName, tableName, partitionName string, checkColumns, uniqueKeyColumns *types.ColumnList, rangeStartArgs, rangeEndArgs []interface{}, includeRangeStartValues bool, indexName string) (result string, explodedArgs []interface{}, err error) {
+	databaseName = EscapeName(databaseName)
+	tableName = EscapeName(tableName)
+
+	rangeStartComparison, rangeEndComparison, explodedArgs, err := buildChunkRangeComparisons(uniqueKeyColumns,
+		buildColumnsPreparedValues(uniqueKeyColumns), buildColumnsPreparedValues(uniqueKeyColumns),
+		rangeStartArgs, rangeEndArgs, includeRangeStartValues)
+	if err != nil {
+		return "", explodedArgs, err
+	}
+
+	checkColumnNames := checkColumns.Names()
+	if len(checkColumnNames) == 0 {
+		return "", nil, fmt.Errorf("critical: table %s.%s has no check columns to fingerprint", databaseName, tableName)
+	}
+	fingerprints := make([]string, len(checkColumnNames))
+	for i, name := range checkColumnNames {
+		fingerprints[i] = fmt.Sprintf("BIT_XOR(crc32(CONCAT_WS('#', %s))) as FINGERPRINT_%d", BuildCheckColumnsListing([]string{name}), i+1)
+	}
+
+	result = fmt.Sprintf(`
+      select /* dataChecksum %s.%s */ %s
+        from %s.%s%s%s
+       where (%s and %s)
+    `, databaseName, tableName, strings.Join(fingerprints, ", "), databaseName, tableName, PartitionClause(partitionName), indexHint(indexName),
+		rangeStartComparison, rangeEndComparison,
+	)
+	return result, explodedArgs, nil
+}
+
+// BuildRangeCountPreparedQuery returns the prepared count(*) SQL over a unique-key range; the range is (rangeMin, rangeMax], or [rangeMin, rangeMax] when includeRangeStartValues
+func BuildRangeCountPreparedQuery(databaseName, tableName, partitionName string, uniqueKeyColumns *types.ColumnList, rangeStartArgs, rangeEndArgs []interface{}, includeRangeStartValues bool) (result string, explodedArgs []interface{}, err error) {
+	if uniqueKeyColumns.Len() == 0 {
+		return "", explodedArgs, fmt.Errorf("got 0 columns in BuildRangeCountPreparedQuery")
+	}
+	databaseName = EscapeName(databaseName)
+	tableName = EscapeName(tableName)
+
+	var startRangeComparisonSign ValueComparisonSign = GreaterThanComparisonSign
+	if includeRangeStartValues {
+		startRangeComparisonSign = GreaterThanOrEqualsComparisonSign
+	}
+	rangeStartComparison, rangeExplodedArgs, err := BuildRangePreparedComparison(uniqueKeyColumns, rangeStartArgs, startRangeComparisonSign)
+	if err != nil {
+		return "", explodedArgs, err
+	}
+	explodedArgs = append(explodedArgs, rangeExplodedArgs...)
+	rangeEndComparison, rangeExplodedArgs, err := BuildRangePreparedComparison(uniqueKeyColumns, rangeEndArgs, LessThanOrEqualsComparisonSign)
+	if err != nil {
+		return "", explodedArgs, err
+	}
+	explodedArgs = append(explodedArgs, rangeExplodedArgs...)
+
+	result = fmt.Sprintf(`
+      select /* dataChecksum %s.%s */ count(*)
+        from %s.%s%s
+       where (%s and %s)
+    `, databaseName, tableName, databaseName, tableName, PartitionClause(partitionName), rangeStartComparison, rangeEndComparison)
+	return result, explodedArgs, nil
+}
+
+// BuildBoundedRangeCountPreparedQuery builds the query counting the rows of a unique-key range up
+// to limit, so it reads at most limit rows however many the range holds
+// The final SQL looks like: select /* dataChecksum db.tab */ count(*) from (select 1 from db.tab where (...) limit {limit}) bounded_range
+func BuildBoundedRangeCountPreparedQuery(databaseName, tableName, partitionName string, uniqueKeyColumns *types.ColumnList, rangeStartArgs, rangeEndArgs []interface{}, includeRangeStartValues bool, limit int64) (result string, explodedArgs []interface{}, err error) {
+	if uniqueKeyColumns.Len() == 0 {
+		return "", explodedArgs, fmt.Errorf("got 0 columns in BuildBoundedRangeCountPreparedQuery")
+	}
+	databaseName = EscapeName(databaseName)
+	tableName = EscapeName(tableName)
+
+	var startRangeComparisonSign ValueComparisonSign = GreaterThanComparisonSign
+	if includeRangeStartValues {
+		startRangeComparisonSign = GreaterThanOrEqualsComparisonSign
+	}
+	rangeStartComparison, rangeExplodedArgs, err := BuildRangePreparedComparison(uniqueKeyColumns, rangeStartArgs, startRangeComparisonSign)
+	if err != nil {
+		return "", explodedArgs, err
+	}
+	explodedArgs = append(explodedArgs, rangeExplodedArgs...)
+	rangeEndComparison, rangeExplodedArgs, err := BuildRangePreparedComparison(uniqueKeyColumns, rangeEndArgs, LessThanOrEqualsComparisonSign)
+	if err != nil {
+		return "", explodedArgs, err
+	}
+	explodedArgs = append(explodedArgs, rangeExplodedArgs...)
+
+	result = fmt.Sprintf(`
+      select /* dataChecksum %s.%s */ count(*)
+        from (
+          select 1
+            from %s.%s%s
+           where (%s and %s)
+           limit %d
+        ) bounded_range
+    `, databaseName, tableName, databaseName, tableName, PartitionClause(partitionName), rangeStartComparison, rangeEndComparison, limit)
+	return result, explodedArgs, nil
+}
+
+// BuildTimeRangeChecksumSQL builds the chunked checksum SQL over a time column.
 // The chunk range is [rangeBegin, rangeEnd); the final chunk is [rangeBegin, rangeEnd] (includeRangeEnd=true).
-// checkLevel=1 returns the aggregated CRC32XOR (order independent); checkLevel=2 returns per-row CRC32 values.
-func BuildTimeRangeChecksumSQL(databaseName, tableName string, checkColumns *types.ColumnList, timeColumnName string, includeRangeEnd bool, checkLevel int64) (result string, err error) {
+// checkLevel=1 returns the aggregated chunk hash (order independent); checkLevel=2 returns per-row hash values.
+func BuildTimeRangeChecksumSQL(databaseName, tableName string, checkColumns *types.ColumnList, timeColumnName string, includeRangeEnd bool, checkLevel int64, hashFunction types.HashFunction) (result string, err error) {
 	if timeColumnName == "" {
 		return "", fmt.Errorf("empty time column in BuildTimeRangeChecksumSQL")
 	}
@@ -287,30 +495,14 @@ func BuildTimeRangeChecksumSQL(databaseName, tableName string, checkColumns *typ
 	tableName = EscapeName(tableName)
 	escapedTimeColumn := EscapeName(timeColumnName)
 
-	checkColumnNames := duplicateNames(checkColumns.Names())
-	for i := range checkColumnNames {
-		checkColumnNames[i] = EscapeName(checkColumnNames[i])
-	}
-	checkColumnNamesListing := fmt.Sprintf("hex(%s)", strings.Join(checkColumnNames, "), hex("))
+	checkColumnNamesListing := BuildCheckColumnsListing(checkColumns.Names())
 
-	var checkClause string
-	switch checkLevel {
-	case 1:
-		checkClause = fmt.Sprintf("COALESCE(LOWER(CONV(BIT_XOR(cast(crc32(CONCAT_WS('#', %s)) as UNSIGNED)), 10, 16)), 0) as CRC32XOR",
-			checkColumnNamesListing)
-	case 2:
-		checkClause = fmt.Sprintf("COALESCE(LOWER(CONV(cast(crc32(CONCAT_WS('#', %s)) as UNSIGNED), 10, 16)), 0) as CRC32",
-			checkColumnNamesListing)
-	default:
+	checkClause, err := buildCheckClause(hashFunction, checkColumnNamesListing, checkLevel)
+	if err != nil {
 		return "", fmt.Errorf("critical: table %s.%s wrong checkLevelFlag input in BuildTimeRangeChecksumSQL",
 			databaseName, tableName)
 	}
 
-	endComparisonSign := LessThanComparisonSign
-	if includeRangeEnd {
-		endComparisonSign = LessThanOrEqualsComparisonSign
-	}
-
 	var orderClause string
 	if checkLevel == 2 {
 		orderClause = fmt.Sprintf("order by %s asc", escapedTimeColumn)
@@ -319,10 +511,86 @@ func BuildTimeRangeChecksumSQL(databaseName, tableName string, checkColumns *typ
 	result = fmt.Sprintf(`
       select /* dataChecksum %s.%s */ %s
         from %s.%s
-       where (%s >= ? and %s %s ?)
+       where %s
       %s
     `, databaseName, tableName, checkClause, databaseName, tableName,
-		escapedTimeColumn, escapedTimeColumn, string(endComparisonSign), orderClause,
+		BuildTimeRangePreparedComparison(timeColumnName, includeRangeEnd), orderClause,
+	)
+	return result, nil
+}
+
+// BuildTimeRangePreparedComparison builds the condition of a time chunk, taking its begin and end as
+// arguments: [rangeBegin, rangeEnd), or [rangeBegin, rangeEnd] when includeRangeEnd is set
+func BuildTimeRangePreparedComparison(timeColumnName string, includeRangeEnd bool) string {
+	escapedTimeColumn := EscapeName(timeColumnName)
+	endComparisonSign := LessThanComparisonSign
+	if includeRangeEnd {
+		endComparisonSign = LessThanOrEqualsComparisonSign
+	}
+	return fmt.Sprintf("(%s >= ? and %s %s ?)", escapedTimeColumn, escapedTimeColumn, string(endComparisonSign))
+}
+
+// buildBucketExpression returns the SQL assigning a row to one of buckets hash buckets. It hashes
+// with CRC32 whatever --hash-function is, so source and target always agree on the bucket.
+func buildBucketExpression(columnsListing string, buckets int) string {
+	return fmt.Sprintf("MOD(crc32(CONCAT_WS('#', %s)), %d)", columnsListing, buckets)
+}
+
+// BuildBucketChecksumSQL builds the checksum SQL of a table without a usable unique key: the rows are
+// grouped into hash buckets, each returning its bucket number, row count, CRC32XOR and hash sum.
+// The final SQL looks like: select /* dataChecksum */
+//
+//	         MOD(crc32(CONCAT_WS('#', ...)), 64) as BUCKET, COUNT(*) as ROW_COUNT, ... as CRC32XOR, ... as HASH_SUM
+//	    from test.t_log
+//	group by BUCKET
+//	order by BUCKET
+func BuildBucketChecksumSQL(databaseName, tableName string, checkColumns *types.ColumnList, buckets int, hashFunction types.HashFunction) (result string, err error) {
+	if buckets < 1 {
+		return "", fmt.Errorf("got %d buckets in BuildBucketChecksumSQL", buckets)
+	}
+	databaseName = EscapeName(databaseName)
+	tableName = EscapeName(tableName)
+	checkColumnNamesListing := BuildCheckColumnsListing(checkColumns.Names())
+	checkClause, err := buildCheckClause(hashFunction, checkColumnNamesListing, 1)
+	if err != nil {
+		return "", err
+	}
+
+	result = fmt.Sprintf(`
+      select /* dataChecksum %s.%s */ %s as BUCKET, %s
+        from %s.%s
+      group by BUCKET
+      order by BUCKET
+    `, databaseName, tableName, buildBucketExpression(checkColumnNamesListing, buckets), checkClause, databaseName, tableName,
+	)
+	return result, nil
+}
+
+// BuildBucketRecordsPreparedQuery builds the SQL listing the distinct rows of one hash bucket, the
+// bucket number being its argument. The rows are grouped by their encoded check columns (see
+// BuildCheckColumnsListing), so every row of a group has the same values and MIN() of a column is
+// the rows' own value, whatever their hashes collide with. Each row comes with its encoding, its
+// hash, its number of copies and its column values, in bytewise order of the encoding.
+func BuildBucketRecordsPreparedQuery(databaseName, tableName string, checkColumns *types.ColumnList, buckets int, hashFunction types.HashFunction) (result string, err error) {
+	if buckets < 1 {
+		return "", fmt.Errorf("got %d buckets in BuildBucketRecordsPreparedQuery", buckets)
+	}
+	databaseName = EscapeName(databaseName)
+	tableName = EscapeName(tableName)
+	checkColumnNamesListing := BuildCheckColumnsListing(checkColumns.Names())
+	columnValues := make([]string, checkColumns.Len())
+	for i, name := range checkColumns.Names() {
+		columnValues[i] = fmt.Sprintf("MIN(%s)", EscapeName(name))
+	}
+
+	result = fmt.Sprintf(`
+      select /* dataChecksum %s.%s */ CAST(CONCAT_WS('#', %s) AS BINARY) as record_values, MIN(%s) as record_checksum, COUNT(*) as ROW_COUNT, %s
+        from %s.%s
+       where %s = ?
+      group by record_values
+      order by record_values
+    `, databaseName, tableName, checkColumnNamesListing, BuildRowHashExpression(hashFunction, checkColumnNamesListing), strings.Join(columnValues, ", "),
+		databaseName, tableName, buildBucketExpression(checkColumnNamesListing, buckets),
 	)
 	return result, nil
 }
@@ -337,6 +605,24 @@ func BuildTimeRangeEstimateQuery(databaseName, tableName, timeColumnName string)
 		EscapeName(timeColumnName), EscapeName(timeColumnName))
 }
 
+// PartitionClause returns the PARTITION clause reading only the given partition of a table, or
+// nothing to read the whole table. It goes right after the table name, before any index hint.
+func PartitionClause(partitionName string) string {
+	if partitionName == "" {
+		return ""
+	}
+	return fmt.Sprintf(" partition (%s)", EscapeName(partitionName))
+}
+
+// indexHint returns the FORCE INDEX hint of the chunk index, or nothing without one, e.g. when
+// probing the target, whose index names may differ from the source's
+func indexHint(indexName string) string {
+	if indexName == "" {
+		return ""
+	}
+	return fmt.Sprintf(" force index(%s)", EscapeName(indexName))
+}
+
 // BuildUniqueKeyRangeEndPreparedQueryViaOffset builds the query that finds the current chunk's upper boundary via LIMIT/OFFSET
 // The final SQL looks like: select /* dataChecksum db.tab iteration:5 */
 //
@@ -349,13 +635,12 @@ func BuildTimeRangeEstimateQuery(databaseName, tableName, timeColumnName string)
 //				col1 asc, col2 asc, col3 asc
 //		 limit 1
 //		 offset {chunkSize -1}
-func BuildUniqueKeyRangeEndPreparedQueryViaOffset(databaseName, tableName string, uniqueKeyColumns *types.ColumnList, rangeStartArgs, rangeEndArgs []interface{}, chunkSize int64, includeRangeStartValues bool, hint string, indexName string) (result string, explodedArgs []interface{}, err error) {
+func BuildUniqueKeyRangeEndPreparedQueryViaOffset(databaseName, tableName, partitionName string, uniqueKeyColumns *types.ColumnList, rangeStartArgs, rangeEndArgs []interface{}, chunkSize int64, includeRangeStartValues bool, hint string, indexName string) (result string, explodedArgs []interface{}, err error) {
 	if uniqueKeyColumns.Len() == 0 {
 		return "", explodedArgs, fmt.Errorf("got 0 columns in BuildUniqueKeyRangeEndPreparedQuery")
 	}
 	databaseName = EscapeName(databaseName)
 	tableName = EscapeName(tableName)
-	indexName = EscapeName(indexName)
 
 	// ">=" when the range start value is included; ">" otherwise
 	var startRangeComparisonSign ValueComparisonSign = GreaterThanComparisonSign
@@ -392,7 +677,7 @@ func BuildUniqueKeyRangeEndPreparedQueryViaOffset(databaseName, tableName string
 				select  /* dataChecksum %s.%s %s */
 						%s
 					from
-						%s.%s force index(%s)
+						%s.%s%s%s
 					where %s and %s
 					order by
 						%s
@@ -400,7 +685,7 @@ func BuildUniqueKeyRangeEndPreparedQueryViaOffset(databaseName, tableName string
 					offset %d
     `, databaseName, tableName, hint,
 		strings.Join(uniqueKeyColumnNames, ", "),
-		databaseName, tableName, indexName,
+		databaseName, tableName, PartitionClause(partitionName), indexHint(indexName),
 		rangeStartComparison, rangeEndComparison,
 		strings.Join(uniqueKeyColumnAscending, ", "),
 		(chunkSize - 1),
@@ -426,13 +711,12 @@ func BuildUniqueKeyRangeEndPreparedQueryViaOffset(databaseName, tableName string
 //				order by
 //					col1 desc, col2 desc, col3 desc
 //				limit 1
-func BuildUniqueKeyRangeEndPreparedQueryViaTemptable(databaseName, tableName string, uniqueKeyColumns *types.ColumnList, rangeStartArgs, rangeEndArgs []interface{}, chunkSize int64, includeRangeStartValues bool, hint string, indexName string) (result string, explodedArgs []interface{}, err error) {
+func BuildUniqueKeyRangeEndPreparedQueryViaTemptable(databaseName, tableName, partitionName string, uniqueKeyColumns *types.ColumnList, rangeStartArgs, rangeEndArgs []interface{}, chunkSize int64, includeRangeStartValues bool, hint string, indexName string) (result string, explodedArgs []interface{}, err error) {
 	if uniqueKeyColumns.Len() == 0 {
 		return "", explodedArgs, fmt.Errorf("got 0 columns in BuildUniqueKeyRangeEndPreparedQuery")
 	}
 	databaseName = EscapeName(databaseName)
 	tableName = EscapeName(tableName)
-	indexName = EscapeName(indexName)
 
 	// ">=" when the range start value is included; ">" otherwise
 	var startRangeComparisonSign ValueComparisonSign = GreaterThanComparisonSign
@@ -471,7 +755,7 @@ func BuildUniqueKeyRangeEndPreparedQueryViaTemptable(databaseName, tableName str
 					select
 							%s
 						from
-							%s.%s force index(%s)
+							%s.%s%s%s
 						where %s and %s
 						order by
 							%s
@@ -481,7 +765,7 @@ func BuildUniqueKeyRangeEndPreparedQueryViaTemptable(databaseName, tableName str
 				%s
 			limit 1
     `, databaseName, tableName, hint, strings.Join(uniqueKeyColumnNames, ", "),
-		strings.Join(uniqueKeyColumnNames, ", "), databaseName, tableName, indexName,
+		strings.Join(uniqueKeyColumnNames, ", "), databaseName, tableName, PartitionClause(partitionName), indexHint(indexName),
 		rangeStartComparison, rangeEndComparison,
 		strings.Join(uniqueKeyColumnAscending, ", "), chunkSize,
 		strings.Join(uniqueKeyColumnDescending, ", "),
@@ -489,18 +773,80 @@ func BuildUniqueKeyRangeEndPreparedQueryViaTemptable(databaseName, tableName str
 	return result, explodedArgs, nil
 }
 
+// BuildUniqueKeyScanPreparedQuery builds the single-pass query of the scan chunk planner, reading
+// every unique key in the range in index order
+// The final SQL looks like: select /* dataChecksum db.tab chunk-plan */
+//
+//				col1, col2, col3
+//			from
+//				db.tab
+//		   where ((col1 > ?) or ((col1 = ?) and (col2 > ?)) or (((col1 = ?) and (col2 = ?)) and (col3 > ?)))
+//	         and ((col1 < ?) or ((col1 = ?) and (col2 < ?)) or (((col1 = ?) and (col2 = ?)) and (col3 < ?)) or ((col1 = ?) and (col2 = ?) and (col3 = ?)))
+//		order by
+//				col1 asc, col2 asc, col3 asc
+func BuildUniqueKeyScanPreparedQuery(databaseName, tableName, partitionName string, uniqueKeyColumns *types.ColumnList, rangeStartArgs, rangeEndArgs []interface{}, includeRangeStartValues bool, hint string, indexName string) (result string, explodedArgs []interface{}, err error) {
+	if uniqueKeyColumns.Len() == 0 {
+		return "", explodedArgs, fmt.Errorf("got 0 columns in BuildUniqueKeyScanPreparedQuery")
+	}
+	databaseName = EscapeName(databaseName)
+	tableName = EscapeName(tableName)
+
+	// ">=" when the range start value is included; ">" otherwise
+	var startRangeComparisonSign ValueComparisonSign = GreaterThanComparisonSign
+	if includeRangeStartValues {
+		startRangeComparisonSign = GreaterThanOrEqualsComparisonSign
+	}
+
+	rangeStartComparison, rangeExplodedArgs, err := BuildRangePreparedComparison(uniqueKeyColumns, rangeStartArgs, startRangeComparisonSign)
+	if err != nil {
+		return "", explodedArgs, err
+	}
+	explodedArgs = append(explodedArgs, rangeExplodedArgs...)
+	rangeEndComparison, rangeExplodedArgs, err := BuildRangePreparedComparison(uniqueKeyColumns, rangeEndArgs, LessThanOrEqualsComparisonSign)
+	if err != nil {
+		return "", explodedArgs, err
+	}
+	explodedArgs = append(explodedArgs, rangeExplodedArgs...)
+
+	uniqueKeyColumnNames := duplicateNames(uniqueKeyColumns.Names())
+	uniqueKeyColumnAscending := make([]string, len(uniqueKeyColumnNames))
+	for i, column := range uniqueKeyColumns.Columns() {
+		uniqueKeyColumnNames[i] = EscapeName(uniqueKeyColumnNames[i])
+		if column.Type == types.EnumColumnType {
+			uniqueKeyColumnAscending[i] = fmt.Sprintf("concat(%s) asc", uniqueKeyColumnNames[i])
+		} else {
+			uniqueKeyColumnAscending[i] = fmt.Sprintf("%s asc", uniqueKeyColumnNames[i])
+		}
+	}
+	result = fmt.Sprintf(`
+				select  /* dataChecksum %s.%s %s */
+						%s
+					from
+						%s.%s%s%s
+					where %s and %s
+					order by
+						%s
+    `, databaseName, tableName, hint,
+		strings.Join(uniqueKeyColumnNames, ", "),
+		databaseName, tableName, PartitionClause(partitionName), indexHint(indexName),
+		rangeStartComparison, rangeEndComparison,
+		strings.Join(uniqueKeyColumnAscending, ", "),
+	)
+	return result, explodedArgs, nil
+}
+
 // BuildUniqueKeyMinValuesPreparedQuery builds the SQL fetching the unique key minimum values
-func BuildUniqueKeyMinValuesPreparedQuery(databaseName, tableName string, uniqueKeyColumns *types.ColumnList) (string, error) {
-	return buildUniqueKeyMinMaxValuesPreparedQuery(databaseName, tableName, uniqueKeyColumns, "asc")
+func BuildUniqueKeyMinValuesPreparedQuery(databaseName, tableName, partitionName string, uniqueKeyColumns *types.ColumnList) (string, error) {
+	return buildUniqueKeyMinMaxValuesPreparedQuery(databaseName, tableName, partitionName, uniqueKeyColumns, "asc")
 }
 
 // BuildUniqueKeyMaxValuesPreparedQuery builds the SQL fetching the unique key maximum values
-func BuildUniqueKeyMaxValuesPreparedQuery(databaseName, tableName string, uniqueKeyColumns *types.ColumnList) (string, error) {
-	return buildUniqueKeyMinMaxValuesPreparedQuery(databaseName, tableName, uniqueKeyColumns, "desc")
+func BuildUniqueKeyMaxValuesPreparedQuery(databaseName, tableName, partitionName string, uniqueKeyColumns *types.ColumnList) (string, error) {
+	return buildUniqueKeyMinMaxValuesPreparedQuery(databaseName, tableName, partitionName, uniqueKeyColumns, "desc")
 }
 
 // buildUniqueKeyMinMaxValuesPreparedQuery builds the shared query; asc/desc ordering selects the minimum or maximum values
-func buildUniqueKeyMinMaxValuesPreparedQuery(databaseName, tableName string, uniqueKeyColumns *types.ColumnList, order string) (string, error) {
+func buildUniqueKeyMinMaxValuesPreparedQuery(databaseName, tableName, partitionName string, uniqueKeyColumns *types.ColumnList, order string) (string, error) {
 	if uniqueKeyColumns.Len() == 0 {
 		return "", fmt.Errorf("got 0 columns in BuildUniqueKeyMinMaxValuesPreparedQuery")
 	}
@@ -520,12 +866,12 @@ func buildUniqueKeyMinMaxValuesPreparedQuery(databaseName, tableName string, uni
 	query := fmt.Sprintf(`
       select /* dataChecksum %s.%s */ %s
 				from
-					%s.%s
+					%s.%s%s
 				order by
 					%s
 				limit 1
     `, databaseName, tableName, strings.Join(uniqueKeyColumnNames, ", "),
-		databaseName, tableName,
+		databaseName, tableName, PartitionClause(partitionName),
 		strings.Join(uniqueKeyColumnOrder, ", "),
 	)
 	return query, nil
@@ -112,18 +112,32 @@ func TestBuildRangePreparedComparison(t *testing.T) {
 	}
 }
 
+func TestBuildCheckColumnsListing(t *testing.T) {
+	// A NULL marker per column: CONCAT_WS would otherwise skip NULLs and shift the remaining columns
+	got := BuildCheckColumnsListing([]string{"a", "b"})
+	want := "ISNULL(`a`), COALESCE(hex(`a`), ''), ISNULL(`b`), COALESCE(hex(`b`), '')"
+	if got != want {
+		t.Errorf("BuildCheckColumnsListing = %q, want %q", got, want)
+	}
+}
+
 func TestBuildRangeChecksumPreparedQuery_AggregateLevel(t *testing.T) {
 	checkColumns := types.NewColumnList([]string{"id", "name"})
 	uniqueKey := types.NewColumnList([]string{"id"})
 
 	query, args, err := BuildRangeChecksumPreparedQuery(
-		"db1", "tab1", checkColumns, uniqueKey,
+		"db1", "tab1", "", checkColumns, uniqueKey,
 		[]interface{}{1}, []interface{}{100},
-		true, 1)
+		true, 1, types.CRC32Hash, "PRIMARY")
 	if err != nil {
 		t.Fatalf("BuildRangeChecksumPreparedQuery failed: %v", err)
 	}
-	for _, want := range []string{"BIT_XOR", "crc32", "hex(`id`), hex(`name`)", "`db1`.`tab1`", "order by `id` asc"} {
+	for _, want := range []string{
+		"COUNT(*) as ROW_COUNT",
+		"BIT_XOR(cast(crc32(", "as CRC32XOR",
+		"MOD(COALESCE(SUM(cast(crc32(", "18446744073709551616", "as HASH_SUM",
+		"ISNULL(`id`), COALESCE(hex(`id`), ''), ISNULL(`name`), COALESCE(hex(`name`), '')", "from `db1`.`tab1` force index(`PRIMARY`)", "order by `id` asc",
+	} {
 		if !strings.Contains(query, want) {
 			t.Errorf("query missing %q:\n%s", want, query)
 		}
@@ -138,9 +152,9 @@ func TestBuildRangeChecksumPreparedQuery_RowLevel(t *testing.T) {
 	uniqueKey := types.NewColumnList([]string{"id"})
 
 	query, _, err := BuildRangeChecksumPreparedQuery(
-		"db1", "tab1", checkColumns, uniqueKey,
+		"db1", "tab1", "", checkColumns, uniqueKey,
 		[]interface{}{1}, []interface{}{100},
-		false, 2)
+		false, 2, types.CRC32Hash, "")
 	if err != nil {
 		t.Fatalf("BuildRangeChecksumPreparedQuery failed: %v", err)
 	}
@@ -156,21 +170,90 @@ func TestBuildRangeChecksumPreparedQuery_InvalidLevel(t *testing.T) {
 	checkColumns := types.NewColumnList([]string{"id"})
 	uniqueKey := types.NewColumnList([]string{"id"})
 	if _, _, err := BuildRangeChecksumPreparedQuery(
-		"db1", "tab1", checkColumns, uniqueKey,
-		[]interface{}{1}, []interface{}{100}, true, 3); err == nil {
+		"db1", "tab1", "", checkColumns, uniqueKey,
+		[]interface{}{1}, []interface{}{100}, true, 3, types.CRC32Hash, ""); err == nil {
 		t.Error("checkLevel=3 should be rejected")
 	}
 }
 
+func TestBuildRangeChecksumPreparedQuery_HashFunctions(t *testing.T) {
+	checkColumns := types.NewColumnList([]string{"id", "name"})
+	uniqueKey := types.NewColumnList([]string{"id"})
+
+	for _, tc := range []struct {
+		hashFunction types.HashFunction
+		aggregate    []string
+		row          string
+		digestSlices int
+	}{
+		{types.MD5Hash, []string{"MD5(CONCAT_WS('#', ISNULL(`id`), COALESCE(hex(`id`), ''), ISNULL(`name`), COALESCE(hex(`name`), '')))"}, "LOWER(MD5(", 1},
+		{types.SHA1Hash, []string{"SHA1(CONCAT_WS("}, "LOWER(SHA1(", 1},
+		{types.SHA256Hash, []string{"SHA2(CONCAT_WS('#', ISNULL(`id`), COALESCE(hex(`id`), ''), ISNULL(`name`), COALESCE(hex(`name`), '')), 256)"}, "LOWER(SHA2(", 1},
+		{types.SplitMD5Hash, []string{"SUBSTRING(MD5(", ", 17, 16)"}, "LOWER(MD5(", 2},
+	} {
+		query, _, err := BuildRangeChecksumPreparedQuery("db1", "tab1", "", checkColumns, uniqueKey, []interface{}{1}, []interface{}{100}, true, 1, tc.hashFunction, "")
+		if err != nil {
+			t.Fatalf("%s: %v", tc.hashFunction, err)
+		}
+		for _, want := range tc.aggregate {
+			if !strings.Contains(query, want) {
+				t.Errorf("%s aggregate query missing %q:\n%s", tc.hashFunction, want, query)
+			}
+		}
+		if strings.Contains(query, "crc32") {
+			t.Errorf("%s aggregate query still uses crc32:\n%s", tc.hashFunction, query)
+		}
+		if n := strings.Count(query, "BIT_XOR"); n != tc.digestSlices {
+			t.Errorf("%s aggregate query XORs %d digest slices, want %d", tc.hashFunction, n, tc.digestSlices)
+		}
+		if n := strings.Count(query, "SUM("); n != tc.digestSlices {
+			t.Errorf("%s aggregate query sums %d digest slices, want %d", tc.hashFunction, n, tc.digestSlices)
+		}
+
+		query, _, err = BuildRangeChecksumPreparedQuery("db1", "tab1", "", checkColumns, uniqueKey, []interface{}{1}, []interface{}{100}, true, 2, tc.hashFunction, "")
+		if err != nil {
+			t.Fatalf("%s: %v", tc.hashFunction, err)
+		}
+		if !strings.Contains(query, tc.row) || strings.Contains(query, "BIT_XOR") {
+			t.Errorf("%s row query should hash each row with %q:\n%s", tc.hashFunction, tc.row, query)
+		}
+	}
+}
+
+func TestBuildRangeCountPreparedQuery(t *testing.T) {
+	uniqueKey := types.NewColumnList([]string{"id"})
+
+	query, args, err := BuildRangeCountPreparedQuery("db1", "tab1", "", uniqueKey, []interface{}{1}, []interface{}{100}, false)
+	if err != nil {
+		t.Fatalf("BuildRangeCountPreparedQuery failed: %v", err)
+	}
+	for _, want := range []string{"count(*)", "`db1`.`tab1`", "((`id` > ?))", "((`id` < ?) or ((`id` = ?)))"} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query missing %q:\n%s", want, query)
+		}
+	}
+	if len(args) != 3 {
+		t.Errorf("args length = %d, want 3 (%v)", len(args), args)
+	}
+
+	query, _, err = BuildRangeCountPreparedQuery("db1", "tab1", "", uniqueKey, []interface{}{1}, []interface{}{100}, true)
+	if err != nil {
+		t.Fatalf("BuildRangeCountPreparedQuery failed: %v", err)
+	}
+	if !strings.Contains(query, "((`id` > ?) or ((`id` = ?)))") {
+		t.Errorf("inclusive start not rendered:\n%s", query)
+	}
+}
+
 func TestBuildTimeRangeChecksumSQL(t *testing.T) {
 	checkColumns := types.NewColumnList([]string{"id", "updated_at"})
 
 	// Non-final chunk: [begin, end) — end bound must be exclusive.
-	query, err := BuildTimeRangeChecksumSQL("db1", "tab1", checkColumns, "updated_at", false, 1)
+	query, err := BuildTimeRangeChecksumSQL("db1", "tab1", checkColumns, "updated_at", false, 1, types.CRC32Hash)
 	if err != nil {
 		t.Fatalf("BuildTimeRangeChecksumSQL failed: %v", err)
 	}
-	for _, want := range []string{"BIT_XOR", "`updated_at` >= ?", "`updated_at` < ?", "`db1`.`tab1`"} {
+	for _, want := range []string{"COUNT(*)", "BIT_XOR", "SUM(", "`updated_at` >= ?", "`updated_at` < ?", "`db1`.`tab1`"} {
 		if !strings.Contains(query, want) {
 			t.Errorf("query missing %q:\n%s", want, query)
 		}
@@ -183,7 +266,7 @@ func TestBuildTimeRangeChecksumSQL(t *testing.T) {
 	}
 
 	// Final chunk: [begin, end] — end bound inclusive.
-	query, err = BuildTimeRangeChecksumSQL("db1", "tab1", checkColumns, "updated_at", true, 2)
+	query, err = BuildTimeRangeChecksumSQL("db1", "tab1", checkColumns, "updated_at", true, 2, types.CRC32Hash)
 	if err != nil {
 		t.Fatalf("BuildTimeRangeChecksumSQL failed: %v", err)
 	}
@@ -194,14 +277,66 @@ func TestBuildTimeRangeChecksumSQL(t *testing.T) {
 		t.Errorf("row-level query (checkLevel=2) must be ordered:\n%s", query)
 	}
 
-	if _, err := BuildTimeRangeChecksumSQL("db1", "tab1", checkColumns, "", false, 1); err == nil {
+	if got := BuildTimeRangePreparedComparison("updated_at", false); got != "(`updated_at` >= ? and `updated_at` < ?)" {
+		t.Errorf("BuildTimeRangePreparedComparison = %s", got)
+	}
+
+	if _, err := BuildTimeRangeChecksumSQL("db1", "tab1", checkColumns, "", false, 1, types.CRC32Hash); err == nil {
 		t.Error("empty time column should be rejected")
 	}
-	if _, err := BuildTimeRangeChecksumSQL("db1", "tab1", checkColumns, "updated_at", false, 9); err == nil {
+	if _, err := BuildTimeRangeChecksumSQL("db1", "tab1", checkColumns, "updated_at", false, 9, types.CRC32Hash); err == nil {
 		t.Error("invalid checkLevel should be rejected")
 	}
 }
 
+func TestBuildBucketChecksumSQL(t *testing.T) {
+	checkColumns := types.NewColumnList([]string{"id", "msg"})
+
+	query, err := BuildBucketChecksumSQL("db1", "tab1", checkColumns, 64, types.CRC32Hash)
+	if err != nil {
+		t.Fatalf("BuildBucketChecksumSQL failed: %v", err)
+	}
+	for _, want := range []string{
+		"MOD(crc32(CONCAT_WS('#', ISNULL(`id`), COALESCE(hex(`id`), ''), ISNULL(`msg`), COALESCE(hex(`msg`), ''))), 64) as BUCKET",
+		"COUNT(*) as ROW_COUNT", "as CRC32XOR", "as HASH_SUM", "`db1`.`tab1`", "group by BUCKET", "order by BUCKET",
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query missing %q:\n%s", want, query)
+		}
+	}
+
+	// Buckets are assigned by CRC32 whatever the row hash is
+	query, err = BuildBucketChecksumSQL("db1", "tab1", checkColumns, 8, types.MD5Hash)
+	if err != nil {
+		t.Fatalf("BuildBucketChecksumSQL failed: %v", err)
+	}
+	if !strings.Contains(query, "MOD(crc32(") || !strings.Contains(query, "MD5(") {
+		t.Errorf("md5 bucket query should bucket by crc32 and hash by md5:\n%s", query)
+	}
+
+	if _, err := BuildBucketChecksumSQL("db1", "tab1", checkColumns, 0, types.CRC32Hash); err == nil {
+		t.Error("zero buckets should be rejected")
+	}
+}
+
+func TestBuildBucketRecordsPreparedQuery(t *testing.T) {
+	checkColumns := types.NewColumnList([]string{"id", "msg"})
+
+	query, err := BuildBucketRecordsPreparedQuery("db1", "tab1", checkColumns, 64, types.CRC32Hash)
+	if err != nil {
+		t.Fatalf("BuildBucketRecordsPreparedQuery failed: %v", err)
+	}
+	for _, want := range []string{
+		"CAST(CONCAT_WS('#', ISNULL(`id`), COALESCE(hex(`id`), ''), ISNULL(`msg`), COALESCE(hex(`msg`), '')) AS BINARY) as record_values",
+		"MIN(COALESCE(LOWER(CONV(cast(crc32(", "as record_checksum", "COUNT(*) as ROW_COUNT", "MIN(`id`), MIN(`msg`)",
+		"where MOD(crc32(", "), 64) = ?", "group by record_values", "order by record_values",
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query missing %q:\n%s", want, query)
+		}
+	}
+}
+
 func TestBuildTimeRangeEstimateQuery(t *testing.T) {
 	query := BuildTimeRangeEstimateQuery("db1", "tab1", "created_at")
 	for _, want := range []string{"EXPLAIN", "`db1`.`tab1`", "`created_at` >= ?", "`created_at` <= ?"} {
@@ -214,7 +349,7 @@ func TestBuildTimeRangeEstimateQuery(t *testing.T) {
 func TestBuildUniqueKeyMinMaxValuesPreparedQuery(t *testing.T) {
 	columns := types.NewColumnList([]string{"a", "b"})
 
-	minQuery, err := BuildUniqueKeyMinValuesPreparedQuery("db1", "tab1", columns)
+	minQuery, err := BuildUniqueKeyMinValuesPreparedQuery("db1", "tab1", "", columns)
 	if err != nil {
 		t.Fatalf("BuildUniqueKeyMinValuesPreparedQuery failed: %v", err)
 	}
@@ -222,7 +357,7 @@ func TestBuildUniqueKeyMinMaxValuesPreparedQuery(t *testing.T) {
 		t.Errorf("min query should order asc with limit 1:\n%s", minQuery)
 	}
 
-	maxQuery, err := BuildUniqueKeyMaxValuesPreparedQuery("db1", "tab1", columns)
+	maxQuery, err := BuildUniqueKeyMaxValuesPreparedQuery("db1", "tab1", "", columns)
 	if err != nil {
 		t.Fatalf("BuildUniqueKeyMaxValuesPreparedQuery failed: %v", err)
 	}
@@ -231,7 +366,7 @@ func TestBuildUniqueKeyMinMaxValuesPreparedQuery(t *testing.T) {
 	}
 
 	empty := types.NewColumnList([]string{})
-	if _, err := BuildUniqueKeyMinValuesPreparedQuery("db1", "tab1", empty); err == nil {
+	if _, err := BuildUniqueKeyMinValuesPreparedQuery("db1", "tab1", "", empty); err == nil {
 		t.Error("empty column list should be rejected")
 	}
 }
@@ -239,7 +374,7 @@ func TestBuildUniqueKeyMinMaxValuesPreparedQuery(t *testing.T) {
 func TestBuildUniqueKeyRangeEndPreparedQueryViaOffset(t *testing.T) {
 	columns := types.NewColumnList([]string{"id"})
 	query, args, err := BuildUniqueKeyRangeEndPreparedQueryViaOffset(
-		"db1", "tab1", columns,
+		"db1", "tab1", "", columns,
 		[]interface{}{1}, []interface{}{5000},
 		1000, true, "iteration:0", "PRIMARY")
 	if err != nil {
@@ -255,10 +390,62 @@ func TestBuildUniqueKeyRangeEndPreparedQueryViaOffset(t *testing.T) {
 	}
 }
 
+func TestBuildUniqueKeyRangeEndPreparedQueryWithoutIndexHint(t *testing.T) {
+	columns := types.NewColumnList([]string{"id"})
+	query, _, err := BuildUniqueKeyRangeEndPreparedQueryViaOffset(
+		"db1", "tab1", "", columns,
+		[]interface{}{1}, []interface{}{5000},
+		1000, true, "split", "")
+	if err != nil {
+		t.Fatalf("BuildUniqueKeyRangeEndPreparedQueryViaOffset failed: %v", err)
+	}
+	if strings.Contains(query, "force index") {
+		t.Errorf("query without a chunk index should have no index hint:\n%s", query)
+	}
+}
+
+func TestBuildBoundedRangeCountPreparedQuery(t *testing.T) {
+	columns := types.NewColumnList([]string{"id"})
+	query, args, err := BuildBoundedRangeCountPreparedQuery("db1", "tab1", "", columns, []interface{}{1}, []interface{}{5000}, false, 2001)
+	if err != nil {
+		t.Fatalf("BuildBoundedRangeCountPreparedQuery failed: %v", err)
+	}
+	for _, want := range []string{"count(*)", "limit 2001", "bounded_range", "`db1`.`tab1`"} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query missing %q:\n%s", want, query)
+		}
+	}
+	if len(args) == 0 {
+		t.Error("expected prepared args, got none")
+	}
+}
+
+func TestBuildUniqueKeyScanPreparedQuery(t *testing.T) {
+	columns := types.NewColumnList([]string{"id", "seq"})
+	query, args, err := BuildUniqueKeyScanPreparedQuery(
+		"db1", "tab1", "", columns,
+		[]interface{}{1, 1}, []interface{}{5000, 9},
+		true, "chunk-plan", "uk_id_seq")
+	if err != nil {
+		t.Fatalf("BuildUniqueKeyScanPreparedQuery failed: %v", err)
+	}
+	for _, want := range []string{"force index(`uk_id_seq`)", "`id` asc, `seq` asc", "chunk-plan"} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query missing %q:\n%s", want, query)
+		}
+	}
+	if strings.Contains(query, "limit") {
+		t.Errorf("the scan must read the whole range:\n%s", query)
+	}
+	if len(args) == 0 {
+		t.Error("expected prepared args, got none")
+	}
+}
+
 func TestBuildUniqueKeyRangeEndPreparedQueryViaTemptable(t *testing.T) {
 	columns := types.NewColumnList([]string{"id"})
 	query, _, err := BuildUniqueKeyRangeEndPreparedQueryViaTemptable(
-		"db1", "tab1", columns,
+		"db1", "tab1", "", columns,
 		[]interface{}{1}, []interface{}{5000},
 		1000, false, "iteration:5", "PRIMARY")
 	if err != nil {
@@ -270,3 +457,58 @@ func TestBuildUniqueKeyRangeEndPreparedQueryViaTemptable(t *testing.T) {
 		}
 	}
 }
+
+func TestPartitionClause(t *testing.T) {
+	if clause := PartitionClause(""); clause != "" {
+		t.Errorf("whole table: got %q, want no clause", clause)
+	}
+	checkColumns := types.NewColumnList([]string{"id", "name"})
+	uniqueKey := types.NewColumnList([]string{"id"})
+	query, _, err := BuildRangeChecksumPreparedQuery("db1", "tab1", "p202401", checkColumns, uniqueKey, []interface{}{1}, []interface{}{100}, true, 1, types.CRC32Hash, "")
+	if err != nil {
+		t.Fatalf("BuildRangeChecksumPreparedQuery failed: %v", err)
+	}
+	if !strings.Contains(query, "from `db1`.`tab1` partition (`p202401`)") {
+		t.Errorf("chunk query does not read the partition:\n%s", query)
+	}
+	// The PARTITION clause must precede the index hint
+	query, _, err = BuildUniqueKeyRangeEndPreparedQueryViaOffset("db1", "tab1", "p202401", uniqueKey, []interface{}{1}, []interface{}{100}, 10, true, "iteration:1", "PRIMARY")
+	if err != nil {
+		t.Fatalf("BuildUniqueKeyRangeEndPreparedQueryViaOffset failed: %v", err)
+	}
+	if !strings.Contains(query, "`db1`.`tab1` partition (`p202401`) force index(`PRIMARY`)") {
+		t.Errorf("probe query does not read the partition through the index:\n%s", query)
+	}
+}
+
+func TestBuildRangeColumnFingerprintsPreparedQuery(t *testing.T) {
+	checkColumns := types.NewColumnList([]string{"id", "name"})
+	uniqueKey := types.NewColumnList([]string{"id"})
+
+	query, args, err := BuildRangeColumnFingerprintsPreparedQuery(
+		"db1", "tab1", "p1", checkColumns, uniqueKey,
+		[]interface{}{1}, []interface{}{100}, false, "idx_id")
+	if err != nil {
+		t.Fatalf("BuildRangeColumnFingerprintsPreparedQuery failed: %v", err)
+	}
+	for _, want := range []string{
+		"BIT_XOR(crc32(CONCAT_WS('#', ISNULL(`id`), COALESCE(hex(`id`), '')))) as FINGERPRINT_1",
+		"BIT_XOR(crc32(CONCAT_WS('#', ISNULL(`name`), COALESCE(hex(`name`), '')))) as FINGERPRINT_2",
+		"from `db1`.`tab1` partition (`p1`) force index(`idx_id`)",
+		"`id` > ?",
+		"`id` < ?",
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query missing %q:\n%s", want, query)
+		}
+	}
+	if len(args) != 3 {
+		t.Errorf("args length = %d, want 3 (%v)", len(args), args)
+	}
+
+	if _, _, err := BuildRangeColumnFingerprintsPreparedQuery(
+		"db1", "tab1", "", types.NewColumnList(nil), uniqueKey,
+		[]interface{}{1}, []interface{}{100}, true, ""); err == nil {
+		t.Error("a query without check columns should be rejected")
+	}
+}
@@ -0,0 +1,114 @@
+package checksum
+
+import (
+	"reflect"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/builder"
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+// countSourceRows counts the source rows within a key range
+func (ctx *ChecksumContext) countSourceRows(keyRange KeyRange) (count int64, err error) {
+	query, explodedArgs, err := builder.BuildRangeCountPreparedQuery(
+		ctx.PerTableContext.SourceDatabaseName,
+		ctx.PerTableContext.SourceTableName,
+		ctx.partition,
+		ctx.UniqueKey,
+		keyRange.Min.AbstractValues(),
+		keyRange.Max.AbstractValues(),
+		keyRange.IncludeMin,
+	)
+	if err != nil {
+		return 0, err
+	}
+	err = ctx.sourceDB().QueryRow(query, explodedArgs...).Scan(&count)
+	return count, err
+}
+
+// compareKeyRange compares the aggregated checksum (row count, CRC32XOR and hash sum) of a key range on source and target
+func (ctx *ChecksumContext) compareKeyRange(keyRange KeyRange) (isEqual bool, err error) {
+	sourceIndexName, targetIndexName, err := ctx.chunkIndexNames()
+	if err != nil {
+		return false, err
+	}
+	sourceCh, targetCh := make(chan *crc32ResultStruct, 1), make(chan *crc32ResultStruct, 1)
+	go func() {
+		ret, err := ctx.queryRangeChecksum(ctx.sourceDB(), ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, sourceIndexName, ctx.UniqueKey, keyRange, 1)
+		sourceCh <- newCrc32ResultStruct(ret, err)
+	}()
+	go func() {
+		ret, err := ctx.queryRangeChecksum(ctx.targetDB(), ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, targetIndexName, ctx.UniqueKey, keyRange, 1)
+		targetCh <- newCrc32ResultStruct(ret, err)
+	}()
+	sourceResult, targetResult := <-sourceCh, <-targetCh
+	if sourceResult.err != nil {
+		return false, sourceResult.err
+	}
+	if targetResult.err != nil {
+		return false, targetResult.err
+	}
+	return reflect.DeepEqual(sourceResult.result, targetResult.result), nil
+}
+
+// BisectKeyRange narrows a mismatched key range down to the pieces that actually differ.
+// The range is split at its middle source row and the aggregated checksum of each half is
+// compared again: equal halves are dropped, different ones are split further until they hold
+// at most BisectRowThreshold source rows. The returned ranges are in key order and are small
+// enough for the row-by-row differential analysis.
+func (ctx *ChecksumContext) BisectKeyRange(keyRange KeyRange) (ranges []KeyRange, err error) {
+	queries := 0
+	if err = ctx.bisectKeyRange(keyRange, &ranges, &queries); err != nil {
+		return nil, err
+	}
+	ctx.Context.Log.Debugf("Debug: Bisected range %s of table %s.%s into %d different range(s) using %d checksum queries",
+		keyRange, ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, len(ranges), queries)
+	return ranges, nil
+}
+
+func (ctx *ChecksumContext) bisectKeyRange(keyRange KeyRange, ranges *[]KeyRange, queries *int) error {
+	rowCount, err := ctx.countSourceRows(keyRange)
+	if err != nil {
+		return err
+	}
+	if rowCount <= ctx.Context.BisectRowThreshold {
+		*ranges = append(*ranges, keyRange)
+		return nil
+	}
+
+	// The key of the middle row ends the lower half; the temptable fallback is not needed since the range holds more than rowCount/2 rows.
+	midValues, found, err := ctx.probeRangeEnd(false, keyRange.Min, keyRange.Max, keyRange.IncludeMin, rowCount/2, "bisect")
+	if err != nil {
+		return err
+	}
+	if !found {
+		*ranges = append(*ranges, keyRange)
+		return nil
+	}
+
+	for _, half := range bisectHalves(keyRange, midValues) {
+		if err := ctx.Throttle(); err != nil {
+			return err
+		}
+		*queries++
+		isEqual, err := ctx.compareKeyRange(half)
+		if err != nil {
+			return err
+		}
+		if isEqual {
+			continue
+		}
+		if err := ctx.bisectKeyRange(half, ranges, queries); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bisectHalves splits keyRange at mid: the lower half keeps the range's start and ends with mid,
+// the upper half starts after mid, so every key falls in exactly one half
+func bisectHalves(keyRange KeyRange, mid *types.ColumnValues) []KeyRange {
+	return []KeyRange{
+		{Min: keyRange.Min, Max: mid, IncludeMin: keyRange.IncludeMin},
+		{Min: mid, Max: keyRange.Max},
+	}
+}
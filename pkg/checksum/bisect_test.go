@@ -0,0 +1,26 @@
+package checksum
+
+import (
+	"testing"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+func TestBisectHalves(t *testing.T) {
+	key := func(v int64) *types.ColumnValues { return types.ToColumnValues([]interface{}{v}) }
+	tests := []struct {
+		keyRange KeyRange
+		mid      int64
+		want     [2]string
+	}{
+		// The first chunk of a table includes its minimum, so the lower half does too
+		{KeyRange{Min: key(1), Max: key(1000), IncludeMin: true}, 500, [2]string{"[1, 500]", "(500, 1000]"}},
+		{KeyRange{Min: key(1000), Max: key(2000)}, 1500, [2]string{"(1000, 1500]", "(1500, 2000]"}},
+	}
+	for _, tt := range tests {
+		halves := bisectHalves(tt.keyRange, key(tt.mid))
+		if len(halves) != 2 || halves[0].String() != tt.want[0] || halves[1].String() != tt.want[1] {
+			t.Errorf("bisectHalves(%s) = %v, want %v", tt.keyRange, halves, tt.want)
+		}
+	}
+}
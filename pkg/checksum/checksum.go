@@ -9,10 +9,12 @@ import (
 	gosql "database/sql"
 	"fmt"
 	"reflect"
+	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/ChaosHour/go-data-checksum/pkg/builder"
+	"github.com/ChaosHour/go-data-checksum/pkg/throttle"
 	"github.com/ChaosHour/go-data-checksum/pkg/tracking"
 	"github.com/ChaosHour/go-data-checksum/pkg/types"
 )
@@ -49,11 +51,71 @@ type ChecksumContext struct {
 	SourceRowCount int64
 	TargetRowCount int64
 
-	lastSourceChecksum string
-	lastTargetChecksum string
+	// Adaptive chunk sizing state (see chunksize.go); chunkSize is 0 until the
+	// table's first adjustment, meaning the global ChunkSize applies.
+	chunkSize          int64
+	chunkRate          float64
+	iterationChunkSize int64
+	rowsEstimated      int64
+
+	// Ranges of the chunks whose checksums differ (see recordDifferentChunk)
+	differentKeyRanges  []KeyRange
+	differentTimeRanges []TimeRange
+
+	// Pinned snapshot connections and the source position they were opened at (see snapshot.go)
+	sourceSnapshot         *gosql.Conn
+	targetSnapshot         *gosql.Conn
+	SnapshotBinlogPosition string
+	SnapshotGTIDSet        string
+
+	// Key-range worker state (see split.go): chunkCounter points at the table's shared
+	// chunk counter, rangeStartExclusive marks a range that starts after
+	// UniqueKeyRangeMinValues, and iterationIncludesMin whether the current chunk includes its minimum.
+	chunkCounter         *int64
+	rangeStartExclusive  bool
+	iterationIncludesMin bool
+
+	// Keyless state (see keyless.go): IsKeyless marks a table compared in hash buckets for lack
+	// of a usable unique key; buckets holds the last bucket comparison and bucket the one tracked.
+	IsKeyless        bool
+	buckets          []bucketComparison
+	bucket           int64
+	differentBuckets []int64
+
+	// lookahead is the running boundary lookahead of the chunk loop (see lookahead.go), nil when off;
+	// chunkPlan holds the precomputed chunks with --chunk-plan=scan (see chunkplan.go), and
+	// resumedRangeEnd the recorded range value of the checkpoint a resumed table continues after.
+	lookahead       *boundaryLookahead
+	chunkPlan       *chunkPlan
+	resumedRangeEnd string
+
+	// Oversized-chunk state (see oversized.go): pendingChunks are the pieces of a split chunk still to
+	// check, oversizedAction the action taken on the current chunk, recorded with it.
+	pendingChunks   []KeyRange
+	oversizedAction types.OversizedChunkAction
+
+	// sample holds the chunks picked with --sample-percent or --sample-chunks (see sample.go)
+	sample *chunkSample
+
+	// queryPlans holds the chunk query shapes already EXPLAINed (see queryplan.go)
+	queryPlans *queryPlanGuard
+
+	// partition is the partition of a partitioned table checked by the context, "" for the whole table (see partition.go)
+	partition string
+
+	// prefilterMethod is the --prefilter pass that decided the table without a chunk check (see prefilter.go)
+	prefilterMethod types.Prefilter
+
+	// Throttler nil means throttling is disabled; throttledTime sums the table's pauses.
+	Throttler     *throttle.Throttler
+	throttledTime time.Duration
+
+	lastSourceChecksum tracking.ChunkChecksum
+	lastTargetChecksum tracking.ChunkChecksum
 	chunksEqual        int
 	chunksDifferent    int
 	chunksError        int
+	chunksSkipped      int
 }
 
 // NewChecksumContext(context *types.BaseContext, perTableContext *types.TableContext) *ChecksumContext {
@@ -65,24 +127,61 @@ func NewChecksumContext(context *types.BaseContext, perTableContext *types.Table
 		TargetResultQueue: make(chan *crc32ResultStruct),
 		SourceRowCount:    -1,
 		TargetRowCount:    -1,
+		queryPlans:        newQueryPlanGuard(),
 	}
 }
 
+// iterationCounter returns the chunk counter of the table; key-range workers share their parent's
+func (ctx *ChecksumContext) iterationCounter() *int64 {
+	if ctx.chunkCounter != nil {
+		return ctx.chunkCounter
+	}
+	return &ctx.PerTableContext.Iteration
+}
+
 // GetIteration returns the current check iteration
 func (ctx *ChecksumContext) GetIteration() int64 {
-	return atomic.LoadInt64(&ctx.PerTableContext.Iteration)
+	return atomic.LoadInt64(ctx.iterationCounter())
 }
 
 // AddIteration increments the check iteration counter
 func (ctx *ChecksumContext) AddIteration() {
-	atomic.AddInt64(&ctx.PerTableContext.Iteration, 1)
+	atomic.AddInt64(ctx.iterationCounter(), 1)
 }
 
-// GetChunkSize returns the configured chunk size
+// NextChunkNumber claims the number of the chunk being checked and advances the counter,
+// atomically so the key-range workers of a table never share a chunk number. A sampled chunk
+// is numbered by its slot in the table's chunk grid instead (see sample.go).
+func (ctx *ChecksumContext) NextChunkNumber() int {
+	chunkNumber := atomic.AddInt64(ctx.iterationCounter(), 1) - 1
+	if ctx.sample != nil {
+		return int(ctx.sample.chunkNumber(chunkNumber))
+	}
+	return int(chunkNumber)
+}
+
+// GetChunkSize returns the chunk size of this table: the adaptive size once
+// --chunk-time has tuned it, the configured chunk size otherwise
 func (ctx *ChecksumContext) GetChunkSize() int64 {
+	if chunkSize := atomic.LoadInt64(&ctx.chunkSize); chunkSize > 0 {
+		return chunkSize
+	}
 	return atomic.LoadInt64(&ctx.Context.ChunkSize)
 }
 
+// Throttle waits while the replicas lag or the servers reach --max-load (see pkg/throttle) and adds the pause to the table's
+// throttled time; it fails when the pause lasts --max-throttle-time
+func (ctx *ChecksumContext) Throttle() error {
+	paused, err := ctx.Throttler.Throttle()
+	ctx.throttledTime += paused
+	return err
+}
+
+// ThrottledTime returns how long the checks of this table were paused by the throttler
+func (ctx *ChecksumContext) ThrottledTime() time.Duration {
+	return ctx.throttledTime
+}
+
 // GetCheckColumns investigates a table and returns the list of columns candidate for calculating checksum. default all columns.
 func (ctx *ChecksumContext) GetCheckColumns() (err error) {
 	if ctx.Context.RequestedColumnNames != "" {
@@ -156,6 +255,13 @@ func (ctx *ChecksumContext) GetAllColumns() (*types.ColumnList, error) {
 // GetUniqueKeys investigates a table and returns the list of unique keys
 // candidate for chunking
 func (ctx *ChecksumContext) GetUniqueKeys() (err error) {
+	// A key given with --chunk-index or --match-columns replaces the pick (see uniquekey.go)
+	chunkIndex := ctx.Context.ChunkIndex.For(ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName)
+	matchColumns := ctx.Context.MatchColumns.For(ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName)
+	if chunkIndex != "" || matchColumns != "" {
+		return ctx.selectUniqueKey(chunkIndex, matchColumns)
+	}
+
 	query := `
     SELECT
       UNIQUES.INDEX_NAME,
@@ -220,26 +326,59 @@ func (ctx *ChecksumContext) GetUniqueKeys() (err error) {
 	var characterSetName string
 	var hasNullable bool
 
-	if err := ctx.Context.SourceDB.QueryRow(query, ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName).Scan(&indexName, &firstColumnName, &columnNames, &countColumninIndex, &dataType, &characterSetName, &hasNullable); err != nil {
+	err = ctx.Context.SourceDB.QueryRow(query, ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName).Scan(&indexName, &firstColumnName, &columnNames, &countColumninIndex, &dataType, &characterSetName, &hasNullable)
+	// Without a unique key free of NULLs, the table is compared in hash buckets (see keyless.go)
+	if err == gosql.ErrNoRows {
+		return ctx.useHashBuckets("has no unique key")
+	}
+	if err != nil {
 		return fmt.Errorf("critical: table %s.%s get uniqueKey failed", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName)
 	}
 	// fmt.Printf("%s, %s, %s, %d, %s, %s, %t\n", indexName, firstColumnName, columnNames, countColumninIndex, dataType, characterSetName, hasNullable)
 	if hasNullable {
-		return fmt.Errorf("critical: table %s.%s got an uniqueKey with null values", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName)
+		return ctx.useHashBuckets("got an uniqueKey with null values")
 	}
 	ctx.Context.Log.Debugf("Debug: UniqueKeys of source table: %s.%s is %s", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, columnNames)
 	ctx.UniqueKey = types.ParseColumnList(columnNames)
 	ctx.UniqueIndexName = indexName
-	return nil
+	return ctx.readColumnTypes(ctx.UniqueKey)
+}
+
+// readColumnTypes fills in the type and signedness of the given source columns,
+// which is what turns range values read back from tracking into typed key values.
+func (ctx *ChecksumContext) readColumnTypes(columns *types.ColumnList) error {
+	query := `
+    select COLUMN_NAME, DATA_TYPE, COLUMN_TYPE
+      from information_schema.columns
+     where table_schema = ? and table_name = ?
+  `
+	rows, err := ctx.Context.SourceDB.Query(query, ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName)
+	if err != nil {
+		return fmt.Errorf("critical: table %s.%s get column types failed: %v", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var columnName, dataType, columnType string
+		if err := rows.Scan(&columnName, &dataType, &columnType); err != nil {
+			return err
+		}
+		column := columns.GetColumn(columnName)
+		if column == nil {
+			continue
+		}
+		column.Type = types.ColumnTypeFromDataType(dataType)
+		column.IsUnsigned = strings.Contains(strings.ToLower(columnType), "unsigned")
+	}
+	return rows.Err()
 }
 
 // ReadUniqueKeyRangeMinValues returns the minimum values to be iterated on checksum
 func (ctx *ChecksumContext) ReadUniqueKeyRangeMinValues() (err error) {
-	query, err := builder.BuildUniqueKeyMinValuesPreparedQuery(ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, ctx.UniqueKey)
+	query, err := builder.BuildUniqueKeyMinValuesPreparedQuery(ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, ctx.partition, ctx.UniqueKey)
 	if err != nil {
 		return err
 	}
-	rows, err := ctx.Context.SourceDB.Query(query)
+	rows, err := ctx.sourceDB().Query(query)
 	if err != nil {
 		return err
 	}
@@ -257,11 +396,11 @@ func (ctx *ChecksumContext) ReadUniqueKeyRangeMinValues() (err error) {
 
 // ReadUniqueKeyRangeMaxValues returns the maximum values to be iterated on checksum
 func (ctx *ChecksumContext) ReadUniqueKeyRangeMaxValues() (err error) {
-	query, err := builder.BuildUniqueKeyMaxValuesPreparedQuery(ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, ctx.UniqueKey)
+	query, err := builder.BuildUniqueKeyMaxValuesPreparedQuery(ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, ctx.partition, ctx.UniqueKey)
 	if err != nil {
 		return err
 	}
-	rows, err := ctx.Context.SourceDB.Query(query)
+	rows, err := ctx.sourceDB().Query(query)
 	if err != nil {
 		return err
 	}
@@ -277,64 +416,135 @@ func (ctx *ChecksumContext) ReadUniqueKeyRangeMaxValues() (err error) {
 	return rows.Err() // Properly check for errors after scanning
 }
 
-// CalculateNextIterationRangeEndValues computes the unique-key range for the next check iteration
+// CalculateNextIterationRangeEndValues computes the unique-key range for the next check iteration.
+// Each range starts where the previous one ended; the first starts at UniqueKeyRangeMinValues unless
+// ChecksumIterationRangeMinValues was seeded beforehand (resume from a checkpoint). Only that first
+// range includes its minimum, and only when the context's key range does. With sampled chunks (see
+// sample.go), a chunk plan (see chunkplan.go) or a boundary lookahead running (see lookahead.go) the
+// range is taken from them instead, and the pieces of a split oversized chunk (see oversized.go) come
+// before any of them.
 func (ctx *ChecksumContext) CalculateNextIterationRangeEndValues() (hasFurtherRange bool, err error) {
-	ctx.ChecksumIterationRangeMinValues = ctx.ChecksumIterationRangeMaxValues
-	if ctx.ChecksumIterationRangeMinValues == nil {
-		ctx.ChecksumIterationRangeMinValues = ctx.UniqueKeyRangeMinValues
+	ctx.oversizedAction = ""
+	if len(ctx.pendingChunks) > 0 {
+		return ctx.nextPendingChunk()
+	}
+	if ctx.sample != nil {
+		return ctx.nextSampledChunk()
+	}
+	if ctx.chunkPlan != nil {
+		return ctx.nextPlannedChunk()
 	}
+	if ctx.lookahead != nil {
+		return ctx.nextLookaheadBoundary()
+	}
+	ctx.iterationIncludesMin = ctx.nextChunkIncludesMin()
+	ctx.ChecksumIterationRangeMinValues = ctx.nextChunkMin()
 
-	// Normally BuildUniqueKeyRangeEndPreparedQueryViaOffset returns the chunk upper bound.
-	// On the final chunk it returns no rows, so the second pass queries the max values via BuildUniqueKeyRangeEndPreparedQueryViaTemptable.
-	for i := 0; i < 2; i++ {
-		buildFunc := builder.BuildUniqueKeyRangeEndPreparedQueryViaOffset
-		if i == 1 {
-			buildFunc = builder.BuildUniqueKeyRangeEndPreparedQueryViaTemptable
-		}
-		query, explodedArgs, err := buildFunc(
-			ctx.PerTableContext.SourceDatabaseName,
-			ctx.PerTableContext.SourceTableName,
-			ctx.UniqueKey,
-			ctx.ChecksumIterationRangeMinValues.AbstractValues(),
-			ctx.UniqueKeyRangeMaxValues.AbstractValues(),
-			atomic.LoadInt64(&ctx.Context.ChunkSize),
-			ctx.GetIteration() == 0,
-			fmt.Sprintf("iteration:%d", ctx.GetIteration()),
-			ctx.UniqueIndexName,
-		)
-		if err != nil {
-			return hasFurtherRange, err
-		}
-		rows, err := ctx.Context.SourceDB.Query(query, explodedArgs...)
-		if err != nil {
-			return hasFurtherRange, err
-		}
-		defer rows.Close() // Add this to prevent connection leaks
-
-		iterationRangeMaxValues := types.NewColumnValues(ctx.UniqueKey.Len())
-		// While the result set is open the underlying connection stays busy. Reading past the last row closes it automatically,
-		// but leaving the loop early would leak the connection, so the explicit Close matters (Close is safe to call more than once).
-		for rows.Next() {
-			if err = rows.Scan(iterationRangeMaxValues.ValuesPointers...); err != nil {
-				return hasFurtherRange, err
-			}
-			hasFurtherRange = true
-		}
-		if err = rows.Err(); err != nil {
-			return hasFurtherRange, err
-		}
-		// If there is a further chunk, store its upper bound in the context
-		if hasFurtherRange {
-			ctx.ChecksumIterationRangeMaxValues = iterationRangeMaxValues
-			return hasFurtherRange, nil
-		}
+	chunkSize := ctx.GetChunkSize()
+	ctx.iterationChunkSize = chunkSize
+
+	iterationRangeMaxValues, found, err := ctx.probeChunkEnd(ctx.ChecksumIterationRangeMinValues, ctx.iterationIncludesMin, chunkSize, fmt.Sprintf("iteration:%d", ctx.GetIteration()))
+	if err != nil {
+		return hasFurtherRange, err
+	}
+	// If there is a further chunk, store its upper bound in the context
+	if found {
+		ctx.ChecksumIterationRangeMaxValues = iterationRangeMaxValues
+		ctx.rowsEstimated += chunkSize
+		return true, nil
 	}
 	ctx.Context.Log.Debugf("Debug: Iteration complete: no further range to iterate of source table: %s.%s", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName)
 	return hasFurtherRange, nil
 }
 
-// IterationQueryChecksum issues a chunk-Checksum query on the table.
-// 1. Chunk-level check: XOR-aggregated CRC32 of the rows in the chunk: COALESCE(LOWER(CONV(BIT_XOR(cast(crc32(CONCAT_WS('#',C1,C2,C3,Cn)) as UNSIGNED)), 10, 16)), 0)
+// nextChunkIncludesMin reports whether the next chunk includes its minimum: only the first chunk of a
+// range starting at (not after) UniqueKeyRangeMinValues does; later chunks start at the previous
+// chunk's maximum, resumed ones at the checkpoint's.
+func (ctx *ChecksumContext) nextChunkIncludesMin() bool {
+	return ctx.ChecksumIterationRangeMinValues == nil && !ctx.rangeStartExclusive
+}
+
+// nextChunkMin returns the minimum of the next chunk: the previous chunk's maximum, the seeded
+// checkpoint, or else the start of the context's key range
+func (ctx *ChecksumContext) nextChunkMin() *types.ColumnValues {
+	if ctx.ChecksumIterationRangeMaxValues != nil {
+		return ctx.ChecksumIterationRangeMaxValues
+	} else if ctx.ChecksumIterationRangeMinValues != nil {
+		return ctx.ChecksumIterationRangeMinValues
+	}
+	return ctx.UniqueKeyRangeMinValues
+}
+
+// probeChunkEnd returns the upper bound of the chunk of chunkSize rows starting at start; found is
+// false when no row is left after start. Normally BuildUniqueKeyRangeEndPreparedQueryViaOffset returns
+// the chunk upper bound. On the final chunk it returns no rows, so the second pass queries the max
+// values via BuildUniqueKeyRangeEndPreparedQueryViaTemptable.
+func (ctx *ChecksumContext) probeChunkEnd(start *types.ColumnValues, includeStart bool, chunkSize int64, hint string) (values *types.ColumnValues, found bool, err error) {
+	return ctx.probeChunkEndWithin(start, ctx.UniqueKeyRangeMaxValues, includeStart, chunkSize, hint)
+}
+
+// probeChunkEndWithin is probeChunkEnd for a chunk that ends at end at the latest
+func (ctx *ChecksumContext) probeChunkEndWithin(start, end *types.ColumnValues, includeStart bool, chunkSize int64, hint string) (values *types.ColumnValues, found bool, err error) {
+	for _, viaTemptable := range []bool{false, true} {
+		if values, found, err = ctx.probeRangeEnd(viaTemptable, start, end, includeStart, chunkSize, hint); err != nil || found {
+			return values, found, err
+		}
+	}
+	return nil, false, nil
+}
+
+// probeRangeEnd runs a single chunk-end query on the source: the unique-key values chunkSize rows after start
+// (via OFFSET), or the last key up to end (viaTemptable). found is false when the query returned no row.
+func (ctx *ChecksumContext) probeRangeEnd(viaTemptable bool, start, end *types.ColumnValues, includeStart bool, chunkSize int64, hint string) (values *types.ColumnValues, found bool, err error) {
+	return ctx.probeTableRangeEnd(ctx.sourceDB(), ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, ctx.UniqueIndexName,
+		viaTemptable, start, end, includeStart, chunkSize, hint)
+}
+
+// probeTableRangeEnd is probeRangeEnd on the given table; an empty indexName leaves the index to the optimizer
+func (ctx *ChecksumContext) probeTableRangeEnd(db dbQuerier, databaseName, tableName, indexName string, viaTemptable bool, start, end *types.ColumnValues, includeStart bool, chunkSize int64, hint string) (values *types.ColumnValues, found bool, err error) {
+	buildFunc := builder.BuildUniqueKeyRangeEndPreparedQueryViaOffset
+	if viaTemptable {
+		buildFunc = builder.BuildUniqueKeyRangeEndPreparedQueryViaTemptable
+	}
+	query, explodedArgs, err := buildFunc(
+		databaseName,
+		tableName,
+		ctx.partition,
+		ctx.UniqueKey,
+		start.AbstractValues(),
+		end.AbstractValues(),
+		chunkSize,
+		includeStart,
+		hint,
+		indexName,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	rows, err := db.Query(query, explodedArgs...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close() // Add this to prevent connection leaks
+
+	values = types.NewColumnValues(ctx.UniqueKey.Len())
+	// While the result set is open the underlying connection stays busy. Reading past the last row closes it automatically,
+	// but leaving the loop early would leak the connection, so the explicit Close matters (Close is safe to call more than once).
+	for rows.Next() {
+		if err = rows.Scan(values.ValuesPointers...); err != nil {
+			return nil, false, err
+		}
+		found = true
+	}
+	if err = rows.Err(); err != nil {
+		return nil, false, err
+	}
+	return values, found, nil
+}
+
+// IterationQueryChecksum issues a chunk-Checksum query on the table. The queries below use the default
+// crc32 hash function; --hash-function swaps in a digest (see builder.BuildRowHashExpression).
+// 1. Chunk-level check: row count, XOR-aggregated and summed (modulo 2^64) CRC32 of the rows in the chunk: COUNT(*), COALESCE(LOWER(CONV(BIT_XOR(cast(crc32(CONCAT_WS('#',C1,C2,C3,Cn)) as UNSIGNED)), 10, 16)), 0), ...; identical rows cancel out of BIT_XOR but not out of COUNT and SUM
 // 2. Row-level check: per-row CRC32 values in the chunk, used to test whether the source rows are a subset of the target rows: COALESCE(LOWER(CONV(cast(crc32(CONCAT_WS('#',id, ftime, c1, c2)) as UNSIGNED), 10, 16)), 0)
 func (ctx *ChecksumContext) IterationQueryChecksum() (isChunkChecksumEqual bool, duration time.Duration, err error) {
 	startTime := time.Now()
@@ -342,7 +552,7 @@ func (ctx *ChecksumContext) IterationQueryChecksum() (isChunkChecksumEqual bool,
 		duration = time.Since(startTime)
 	}()
 
-	// checkLevel 1 = aggregated CRC32XOR, 2 = per-row CRC32 values
+	// checkLevel 1 = row count, CRC32XOR and hash sum, 2 = per-row CRC32 values
 	var checkLevel int64 = 1
 	if ctx.Context.IsSuperSetAsEqual {
 		checkLevel = 2
@@ -351,8 +561,12 @@ func (ctx *ChecksumContext) IterationQueryChecksum() (isChunkChecksumEqual bool,
 	var sourceResult []string
 	var targetResult []string
 
-	go ctx.QueryChecksumFunc(ctx.Context.SourceDB, ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, ctx.UniqueKey, checkLevel, ctx.SourceResultQueue)
-	go ctx.QueryChecksumFunc(ctx.Context.TargetDB, ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, ctx.UniqueKey, checkLevel, ctx.TargetResultQueue)
+	sourceIndexName, targetIndexName, err := ctx.chunkIndexNames()
+	if err != nil {
+		return false, duration, err
+	}
+	go ctx.QueryChecksumFunc(ctx.sourceDB(), ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, sourceIndexName, ctx.UniqueKey, checkLevel, ctx.SourceResultQueue)
+	go ctx.QueryChecksumFunc(ctx.targetDB(), ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, targetIndexName, ctx.UniqueKey, checkLevel, ctx.TargetResultQueue)
 	sourceResultStruct, targetResultStruct := <-ctx.SourceResultQueue, <-ctx.TargetResultQueue
 	if sourceResultStruct.err != nil {
 		return false, duration, sourceResultStruct.err
@@ -361,8 +575,8 @@ func (ctx *ChecksumContext) IterationQueryChecksum() (isChunkChecksumEqual bool,
 	} else {
 		sourceResult, targetResult = sourceResultStruct.result, targetResultStruct.result
 	}
-	ctx.lastSourceChecksum = checksumSummary(sourceResult)
-	ctx.lastTargetChecksum = checksumSummary(targetResult)
+	ctx.lastSourceChecksum = checksumSummary(sourceResult, checkLevel)
+	ctx.lastTargetChecksum = checksumSummary(targetResult, checkLevel)
 
 	// atomic.AddInt64(&this.PerTableContext.Iteration, 1)
 	if reflect.DeepEqual(sourceResult, targetResult) {
@@ -374,54 +588,105 @@ func (ctx *ChecksumContext) IterationQueryChecksum() (isChunkChecksumEqual bool,
 	return false, duration, nil
 }
 
-// QueryChecksumFunc fetches the chunk checksum result (aggregated CRC32XOR or per-row CRC32)
-func (ctx *ChecksumContext) QueryChecksumFunc(db *gosql.DB, databaseName, tableName string, uniqueColumn *types.ColumnList, checkLevel int64, ch chan *crc32ResultStruct) {
-	var ret []string
+// chunkRetryInterval is the pause before a chunk checksum is retried
+var chunkRetryInterval = time.Second
+
+// RetryIterationQueryChecksum checksums the current chunk up to DefaultNumRetries times, until it is
+// equal, to ride out transient errors and replication lag. Only a chunk still different after the
+// retries has its column fingerprints compared (--column-fingerprints).
+func (ctx *ChecksumContext) RetryIterationQueryChecksum() (isChunkChecksumEqual bool, duration time.Duration, err error) {
+	fingerprint := func() {}
+	if ctx.Context.ColumnFingerprints {
+		fingerprint = ctx.compareColumnFingerprints
+	}
+	return ctx.retryChunkChecksum(ctx.IterationQueryChecksum, fingerprint)
+}
+
+func (ctx *ChecksumContext) retryChunkChecksum(query func() (bool, time.Duration, error), onDifferent func()) (isChunkChecksumEqual bool, duration time.Duration, err error) {
+	for i := 0; i < int(ctx.Context.DefaultNumRetries); i++ {
+		if i != 0 {
+			time.Sleep(chunkRetryInterval)
+			// The same snapshots would return the same rows; retry in new ones
+			if err = ctx.RefreshSnapshot(); err != nil {
+				break
+			}
+			ctx.Context.Log.Debugf("IterationQueryChecksum [%s-%s] retry times %d of table pair: %s.%s => %s.%s .", ctx.ChecksumIterationRangeMinValues.AbstractValues(), ctx.ChecksumIterationRangeMaxValues.AbstractValues(), i, ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName)
+		} else {
+			ctx.Context.Log.Debugf("IterationQueryChecksum [%s-%s] of table pair: %s.%s => %s.%s .", ctx.ChecksumIterationRangeMinValues.AbstractValues(), ctx.ChecksumIterationRangeMaxValues.AbstractValues(), ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName)
+		}
+		isChunkChecksumEqual, duration, err = query()
+		if err == nil && isChunkChecksumEqual {
+			break
+		}
+	}
+	if err == nil && !isChunkChecksumEqual {
+		onDifferent()
+	}
+	return isChunkChecksumEqual, duration, err
+}
+
+// QueryChecksumFunc fetches the chunk checksum result (row count, CRC32XOR and hash sum, or per-row CRC32)
+func (ctx *ChecksumContext) QueryChecksumFunc(db dbQuerier, databaseName, tableName, indexName string, uniqueColumn *types.ColumnList, checkLevel int64, ch chan *crc32ResultStruct) {
+	ret, err := ctx.queryRangeChecksum(db, databaseName, tableName, indexName, uniqueColumn, ctx.CurrentKeyRange(), checkLevel)
+	ch <- newCrc32ResultStruct(ret, err)
+}
+
+// queryRangeChecksum runs the checksum query of the given check level over a unique-key range,
+// forcing the given index unless it is ""
+func (ctx *ChecksumContext) queryRangeChecksum(db dbQuerier, databaseName, tableName, indexName string, uniqueColumn *types.ColumnList, keyRange KeyRange, checkLevel int64) (ret []string, err error) {
 	query, explodedArgs, err := builder.BuildRangeChecksumPreparedQuery(
 		databaseName,
 		tableName,
+		ctx.partition,
 		ctx.CheckColumns,
 		uniqueColumn,
-		ctx.ChecksumIterationRangeMinValues.AbstractValues(),
-		ctx.ChecksumIterationRangeMaxValues.AbstractValues(),
-		ctx.GetIteration() == 0,
+		keyRange.Min.AbstractValues(),
+		keyRange.Max.AbstractValues(),
+		keyRange.IncludeMin,
 		checkLevel,
+		ctx.Context.HashFunction,
+		indexName,
 	)
 	if err != nil {
-		ch <- newCrc32ResultStruct(ret, err)
-		return
+		return ret, err
 	}
 
 	rows, err := db.Query(query, explodedArgs...)
 	if err != nil {
-		ch <- newCrc32ResultStruct(ret, err)
-		return
+		return ret, err
 	}
 	defer rows.Close()
+	return scanChecksumRows(rows)
+}
+
+// scanChecksumRows reads the columns of every row of a checksum query as text: the row count,
+// CRC32XOR and hash sum of the aggregate query, or one hash per row of the row-level query
+func scanChecksumRows(rows *gosql.Rows) (ret []string, err error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return ret, err
+	}
 	for rows.Next() {
-		rowValues := types.NewColumnValues(1)
+		rowValues := types.NewColumnValues(len(columns))
 		if err := rows.Scan(rowValues.ValuesPointers...); err != nil {
-			ch <- newCrc32ResultStruct(ret, err)
-			return
+			return ret, err
+		}
+		for i := range columns {
+			ret = append(ret, rowValues.StringColumn(i))
 		}
-		ret = append(ret, rowValues.StringColumn(0))
-	}
-	if err = rows.Err(); err != nil {
-		ch <- newCrc32ResultStruct(ret, err)
-		return
 	}
-	ch <- newCrc32ResultStruct(ret, nil)
+	return ret, rows.Err()
 }
 
 // DataChecksumByCount compares the total row counts of the source and target tables. With IsSuperSetAsEqual=false only equal counts pass; otherwise source <= target also passes. Returns whether the counts match and whether further checking is needed.
 func (ctx *ChecksumContext) DataChecksumByCount() (isTableCountEqual bool, isMoreCheckNeeded bool, sourceRowCount int64, targetRowCount int64, err error) {
-	SourceQueryTableCount := fmt.Sprintf("select /* dataChecksum */ count(*) from %s.%s", types.EscapeName(ctx.PerTableContext.SourceDatabaseName), types.EscapeName(ctx.PerTableContext.SourceTableName))
-	TargetQueryTableCount := fmt.Sprintf("select /* dataChecksum */ count(*) from %s.%s", types.EscapeName(ctx.PerTableContext.TargetDatabaseName), types.EscapeName(ctx.PerTableContext.TargetTableName))
+	SourceQueryTableCount := fmt.Sprintf("select /* dataChecksum */ count(*) from %s.%s%s", types.EscapeName(ctx.PerTableContext.SourceDatabaseName), types.EscapeName(ctx.PerTableContext.SourceTableName), builder.PartitionClause(ctx.partition))
+	TargetQueryTableCount := fmt.Sprintf("select /* dataChecksum */ count(*) from %s.%s%s", types.EscapeName(ctx.PerTableContext.TargetDatabaseName), types.EscapeName(ctx.PerTableContext.TargetTableName), builder.PartitionClause(ctx.partition))
 	sourceRowCount, targetRowCount = -1, -1
-	if err = ctx.Context.SourceDB.QueryRow(SourceQueryTableCount).Scan(&sourceRowCount); err != nil {
+	if err = ctx.sourceDB().QueryRow(SourceQueryTableCount).Scan(&sourceRowCount); err != nil {
 		return false, false, sourceRowCount, targetRowCount, fmt.Errorf("critical: Table %s.%s query sourceRowCount failed", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName)
 	}
-	if err = ctx.Context.TargetDB.QueryRow(TargetQueryTableCount).Scan(&targetRowCount); err != nil {
+	if err = ctx.targetDB().QueryRow(TargetQueryTableCount).Scan(&targetRowCount); err != nil {
 		return false, false, sourceRowCount, targetRowCount, fmt.Errorf("critical: Table %s.%s query TargetRowCount failed", ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName)
 	}
 
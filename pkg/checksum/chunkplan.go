@@ -0,0 +1,189 @@
+package checksum
+
+import (
+	"github.com/ChaosHour/go-data-checksum/pkg/builder"
+	"github.com/ChaosHour/go-data-checksum/pkg/resume"
+	"github.com/ChaosHour/go-data-checksum/pkg/tracking"
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+// Chunk plans (--chunk-plan=scan): instead of one LIMIT/OFFSET probe per chunk, the key range
+// is read once through the chunk index and every chunk-size-th key becomes a chunk boundary.
+// The chunk loop, the --table-threads split and resume then all work off the same list of
+// chunks: CalculateNextIterationRangeEndValues takes the next planned chunk, SplitKeyRange cuts
+// the plan into runs of whole chunks, and a tracked plan (chunk_plans) is picked up again after
+// the checkpoint's chunk on resume. --chunk-time does not resize planned chunks.
+
+// chunkPlan is the list of precomputed chunks of a key range; next is the chunk to check next
+type chunkPlan struct {
+	chunks    []KeyRange
+	chunkSize int64
+	next      int
+}
+
+// PlanChunks plans the chunks of the rest of the table's key range: those after the resumed
+// checkpoint when the stored plan has it, otherwise by scanning the source.
+func (ctx *ChecksumContext) PlanChunks() error {
+	if chunks := ctx.loadChunkPlan(); chunks != nil {
+		ctx.chunkPlan = &chunkPlan{chunks: chunks, chunkSize: ctx.GetChunkSize()}
+		ctx.Context.Log.Infof("Resuming the chunk plan of table %s.%s with %d chunk(s) left.", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, len(chunks))
+		return nil
+	}
+	chunkSize := ctx.GetChunkSize()
+	chunks, err := ctx.scanChunkPlan(ctx.nextChunkMin(), ctx.nextChunkIncludesMin(), chunkSize)
+	if err != nil {
+		return err
+	}
+	ctx.chunkPlan = &chunkPlan{chunks: chunks, chunkSize: chunkSize}
+	ctx.Context.Log.Debugf("Debug: Planned %d chunk(s) of %d rows of table %s.%s", len(chunks), chunkSize, ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName)
+	ctx.TrackChunkPlan()
+	return nil
+}
+
+// scanChunkPlan reads the keys from start to the end of the key range in one query and cuts them
+// into chunks of chunkSize rows; the last chunk ends at the last key and may be smaller
+func (ctx *ChecksumContext) scanChunkPlan(start *types.ColumnValues, includeStart bool, chunkSize int64) (chunks []KeyRange, err error) {
+	query, explodedArgs, err := builder.BuildUniqueKeyScanPreparedQuery(
+		ctx.PerTableContext.SourceDatabaseName,
+		ctx.PerTableContext.SourceTableName,
+		ctx.partition,
+		ctx.UniqueKey,
+		start.AbstractValues(),
+		ctx.UniqueKeyRangeMaxValues.AbstractValues(),
+		includeStart,
+		"chunk-plan",
+		ctx.UniqueIndexName,
+	)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := ctx.sourceDB().Query(query, explodedArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chunk := KeyRange{Min: start, IncludeMin: includeStart}
+	values := types.NewColumnValues(ctx.UniqueKey.Len())
+	var rowsInChunk int64
+	for rows.Next() {
+		if err = rows.Scan(values.ValuesPointers...); err != nil {
+			return nil, err
+		}
+		if rowsInChunk++; rowsInChunk == chunkSize {
+			chunk.Max = values
+			chunks = append(chunks, chunk)
+			chunk = KeyRange{Min: values}
+			values = types.NewColumnValues(ctx.UniqueKey.Len())
+			rowsInChunk = 0
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if rowsInChunk > 0 {
+		chunk.Max = values
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// loadChunkPlan returns the chunks of the stored plan after the resumed checkpoint, or nil when
+// the table is not resumed or its checkpoint is not a chunk of the stored plan
+func (ctx *ChecksumContext) loadChunkPlan() []KeyRange {
+	if ctx.JobTracker == nil || ctx.resumedRangeEnd == "" {
+		return nil
+	}
+	stored, err := ctx.JobTracker.GetChunkPlan(ctx.ComparisonID)
+	if err != nil {
+		ctx.Context.Log.Warnf("tracking: read chunk plan of table comparison %d failed: %v", ctx.ComparisonID, err)
+		return nil
+	}
+	for i, r := range stored {
+		if r.RangeEnd != ctx.resumedRangeEnd {
+			continue
+		}
+		chunks, err := ctx.decodeChunkPlan(stored[i+1:])
+		if err != nil {
+			ctx.Context.Log.Warnf("tracking: cannot resume the chunk plan of table comparison %d: %v", ctx.ComparisonID, err)
+			return nil
+		}
+		return chunks
+	}
+	return nil
+}
+
+// decodeChunkPlan decodes the key values of stored chunks; none of the chunks includes its minimum
+func (ctx *ChecksumContext) decodeChunkPlan(stored []tracking.ChunkRange) ([]KeyRange, error) {
+	chunks := make([]KeyRange, 0, len(stored))
+	for _, r := range stored {
+		rangeStart, err := resume.DecodeUniqueKeyValues(r.RangeStart, ctx.UniqueKey)
+		if err != nil {
+			return nil, err
+		}
+		rangeEnd, err := resume.DecodeUniqueKeyValues(r.RangeEnd, ctx.UniqueKey)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, KeyRange{Min: rangeStart, Max: rangeEnd})
+	}
+	return chunks, nil
+}
+
+// nextPlannedChunk makes the next planned chunk the current one, like CalculateNextIterationRangeEndValues
+func (ctx *ChecksumContext) nextPlannedChunk() (hasFurtherRange bool, err error) {
+	if ctx.chunkPlan.next >= len(ctx.chunkPlan.chunks) {
+		ctx.Context.Log.Debugf("Debug: Iteration complete: no further range to iterate of source table: %s.%s", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName)
+		return false, nil
+	}
+	chunk := ctx.chunkPlan.chunks[ctx.chunkPlan.next]
+	ctx.chunkPlan.next++
+	ctx.iterationIncludesMin = chunk.IncludeMin
+	ctx.ChecksumIterationRangeMinValues = chunk.Min
+	ctx.ChecksumIterationRangeMaxValues = chunk.Max
+	ctx.iterationChunkSize = ctx.chunkPlan.chunkSize
+	ctx.rowsEstimated += ctx.chunkPlan.chunkSize
+	return true, nil
+}
+
+// split cuts the remaining chunks into at most n consecutive key ranges of whole chunks
+func (plan *chunkPlan) split(n int) (ranges []KeyRange) {
+	chunks := plan.chunks[plan.next:]
+	if n > len(chunks) {
+		n = len(chunks)
+	}
+	for k, first := 0, 0; k < n; k++ {
+		last := (k+1)*len(chunks)/n - 1
+		ranges = append(ranges, KeyRange{Min: chunks[first].Min, Max: chunks[last].Max, IncludeMin: chunks[first].IncludeMin})
+		first = last + 1
+	}
+	return ranges
+}
+
+// within returns the plan of the chunks making up keyRange, which split returned, or nil when
+// keyRange does not start and end on chunk boundaries of the plan. Bounds compare by key values.
+func (plan *chunkPlan) within(keyRange KeyRange) *chunkPlan {
+	first := -1
+	for i, chunk := range plan.chunks {
+		if sameKeyValues(chunk.Min, keyRange.Min) && chunk.IncludeMin == keyRange.IncludeMin {
+			first = i
+		}
+		if first >= 0 && sameKeyValues(chunk.Max, keyRange.Max) {
+			return &chunkPlan{chunks: plan.chunks[first : i+1], chunkSize: plan.chunkSize}
+		}
+	}
+	return nil
+}
+
+// sameKeyValues reports whether two key bounds hold the same values, column by column as text
+func sameKeyValues(a, b *types.ColumnValues) bool {
+	if len(a.AbstractValues()) != len(b.AbstractValues()) {
+		return false
+	}
+	for i := range a.AbstractValues() {
+		if a.StringColumn(i) != b.StringColumn(i) {
+			return false
+		}
+	}
+	return true
+}
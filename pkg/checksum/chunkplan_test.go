@@ -0,0 +1,71 @@
+package checksum
+
+import (
+	"testing"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+func testChunkPlan(bounds ...int64) *chunkPlan {
+	key := func(v int64) *types.ColumnValues { return types.ToColumnValues([]interface{}{v}) }
+	plan := &chunkPlan{chunkSize: 100}
+	min := key(bounds[0])
+	for i, bound := range bounds[1:] {
+		max := key(bound)
+		plan.chunks = append(plan.chunks, KeyRange{Min: min, Max: max, IncludeMin: i == 0})
+		min = max
+	}
+	return plan
+}
+
+func TestChunkPlanSplitKeepsWholeChunks(t *testing.T) {
+	plan := testChunkPlan(1, 100, 200, 300, 400, 500)
+	ranges := plan.split(2)
+	if len(ranges) != 2 || ranges[0].String() != "[1, 200]" || ranges[1].String() != "(200, 500]" {
+		t.Fatalf("split(2) = %v, want [[1, 200] (200, 500]]", ranges)
+	}
+	for i, keyRange := range ranges {
+		worker := plan.within(keyRange)
+		if worker == nil {
+			t.Fatalf("range %s should be made of planned chunks", keyRange)
+		}
+		if first, last := worker.chunks[0], worker.chunks[len(worker.chunks)-1]; first.Min != keyRange.Min || last.Max != keyRange.Max {
+			t.Errorf("range %d: plan covers %s to %s, want %s", i, first, last, keyRange)
+		}
+	}
+	if got := len(plan.split(10)); got != 5 {
+		t.Errorf("split(10) of 5 chunks = %d ranges, want 5", got)
+	}
+	if plan.within(KeyRange{Min: types.ToColumnValues([]interface{}{int64(1)}), Max: plan.chunks[4].Max}) != nil {
+		t.Error("a range not starting on a planned chunk should have no plan")
+	}
+	// Bounds read again, e.g. from a checkpoint, are other values of the same keys
+	copied := KeyRange{Min: types.ToColumnValues([]interface{}{int64(200)}), Max: types.ToColumnValues([]interface{}{int64(400)})}
+	if worker := plan.within(copied); worker == nil || len(worker.chunks) != 2 {
+		t.Errorf("range %s of copied bounds should be made of 2 planned chunks, got %v", copied, worker)
+	}
+}
+
+func TestPlannedChunksBecomeChunks(t *testing.T) {
+	ctx := NewChecksumContext(types.NewBaseContext(), types.NewTableContext("srcdb", "t1", "tgtdb", "t1"))
+	ctx.chunkPlan = testChunkPlan(1, 100, 150)
+	ctx.StartBoundaryLookahead(2)
+	if ctx.lookahead != nil {
+		t.Fatal("planned chunks need no boundary lookahead")
+	}
+
+	var ranges []string
+	for {
+		hasFurtherRange, err := ctx.CalculateNextIterationRangeEndValues()
+		if err != nil {
+			t.Fatalf("CalculateNextIterationRangeEndValues: %v", err)
+		}
+		if !hasFurtherRange {
+			break
+		}
+		ranges = append(ranges, ctx.CurrentKeyRange().String())
+	}
+	if len(ranges) != 2 || ranges[0] != "[1, 100]" || ranges[1] != "(100, 150]" {
+		t.Errorf("chunks = %v, want [[1, 100] (100, 150]]", ranges)
+	}
+}
@@ -0,0 +1,46 @@
+package checksum
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// chunkRateWeight is how much of the previous rows/second estimate survives each
+// chunk, the same smoothing pt-table-checksum applies for --chunk-time, so one
+// slow chunk (a cold page, a lock wait) does not collapse the chunk size.
+const chunkRateWeight = 0.75
+
+// AdjustChunkSize retunes the table's chunk size after a chunk query took
+// duration, aiming the next chunk at Context.ChunkTime. It is a no-op unless
+// --chunk-time is set. The size is kept within ChunkSizeMin..ChunkSizeMax.
+func (ctx *ChecksumContext) AdjustChunkSize(duration time.Duration) {
+	if ctx.Context.ChunkTime <= 0 || duration <= 0 {
+		return
+	}
+	chunkSize := ctx.iterationChunkSize
+	if chunkSize <= 0 {
+		chunkSize = ctx.GetChunkSize()
+	}
+	rate := float64(chunkSize) / duration.Seconds()
+	if ctx.chunkRate > 0 {
+		rate = chunkRateWeight*ctx.chunkRate + (1-chunkRateWeight)*rate
+	}
+	ctx.chunkRate = rate
+
+	nextChunkSize := int64(rate * ctx.Context.ChunkTime.Seconds())
+	if nextChunkSize < ctx.Context.ChunkSizeMin {
+		nextChunkSize = ctx.Context.ChunkSizeMin
+	}
+	if nextChunkSize > ctx.Context.ChunkSizeMax {
+		nextChunkSize = ctx.Context.ChunkSizeMax
+	}
+	if previous := atomic.SwapInt64(&ctx.chunkSize, nextChunkSize); previous != nextChunkSize {
+		ctx.Context.Log.Debugf("Debug: chunk of %d rows took %v, chunk size of table %s.%s is now %d", chunkSize, duration, ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, nextChunkSize)
+	}
+}
+
+// EstimatedRowsChecked sums the chunk sizes of the key ranges iterated so far;
+// the final chunk is usually smaller, so this is an upper bound.
+func (ctx *ChecksumContext) EstimatedRowsChecked() int64 {
+	return ctx.rowsEstimated
+}
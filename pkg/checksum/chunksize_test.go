@@ -0,0 +1,58 @@
+package checksum
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+func newChunkSizeTestContext(chunkTime time.Duration) *ChecksumContext {
+	baseContext := types.NewBaseContext()
+	baseContext.ChunkTime = chunkTime
+	baseContext.SetChunkSizeBounds(100, 50000)
+	return NewChecksumContext(baseContext, types.NewTableContext("srcdb", "t1", "tgtdb", "t1"))
+}
+
+func TestAdjustChunkSizeDisabled(t *testing.T) {
+	ctx := newChunkSizeTestContext(0)
+	ctx.AdjustChunkSize(5 * time.Second)
+	if got := ctx.GetChunkSize(); got != 1000 {
+		t.Errorf("without --chunk-time the chunk size must stay fixed, got %d", got)
+	}
+}
+
+func TestAdjustChunkSizeTracksTimeBudget(t *testing.T) {
+	ctx := newChunkSizeTestContext(500 * time.Millisecond)
+
+	// 1000 rows in 100ms: 10000 rows/s, so a 500ms chunk holds 5000 rows
+	ctx.iterationChunkSize = 1000
+	ctx.AdjustChunkSize(100 * time.Millisecond)
+	if got := ctx.GetChunkSize(); got != 5000 {
+		t.Errorf("first adjustment: chunk size = %d, want 5000", got)
+	}
+
+	// A slow chunk only pulls the smoothed rate down partially
+	ctx.iterationChunkSize = 5000
+	ctx.AdjustChunkSize(5 * time.Second)
+	got := ctx.GetChunkSize()
+	if got >= 5000 || got <= 500 {
+		t.Errorf("slow chunk should shrink the size gradually, got %d", got)
+	}
+}
+
+func TestAdjustChunkSizeBounds(t *testing.T) {
+	ctx := newChunkSizeTestContext(time.Second)
+	ctx.iterationChunkSize = 1000
+	ctx.AdjustChunkSize(time.Microsecond)
+	if got := ctx.GetChunkSize(); got != 50000 {
+		t.Errorf("chunk size should cap at the maximum bound, got %d", got)
+	}
+
+	ctx = newChunkSizeTestContext(time.Millisecond)
+	ctx.iterationChunkSize = 1000
+	ctx.AdjustChunkSize(time.Minute)
+	if got := ctx.GetChunkSize(); got != 100 {
+		t.Errorf("chunk size should floor at the minimum bound, got %d", got)
+	}
+}
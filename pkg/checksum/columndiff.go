@@ -0,0 +1,228 @@
+package checksum
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+// Column-level differences: a modified record only tells that the row checksums differ. For the
+// sampled modified records, the full source and target rows are fetched by key once the analysis
+// is done, and the check columns whose values differ are reported with both values. Values are
+// shown as text: bytes that are not UTF-8 text free of NULs as hex, temporal values in MySQL format,
+// and anything longer than maxColumnValueLength characters is cut at a character boundary and
+// marked with its full size. The column differences are logged with the sample and tracked in
+// difference_details.sample_data.
+
+// maxColumnValueLength is the number of characters of a differing column value that are kept
+const maxColumnValueLength = 64
+
+// columnDifferenceBatchSize is the number of records whose rows are fetched in one query
+const columnDifferenceBatchSize = 1000
+
+// ColumnDifference is a check column whose value differs between the source and target row.
+// The values are nil for NULL, otherwise their text, truncated (see formatColumnValue).
+type ColumnDifference struct {
+	Column      string
+	SourceValue interface{}
+	TargetValue interface{}
+}
+
+// addColumnDifferences fetches the rows of the sampled modified records from both sides and
+// attaches the check columns that differ. Records whose rows are gone by now are left as they are.
+func (td *TableDiffer) addColumnDifferences(report *DifferenceReport) error {
+	ctx := td.Context
+
+	var modified []int
+	for i, diff := range report.SampleDifferences {
+		if diff.DifferenceType == "modified" {
+			modified = append(modified, i)
+		}
+	}
+	if len(modified) == 0 {
+		return nil
+	}
+
+	// Rows are matched to the records by key order, so keys that are equal in their collation
+	// but differ in bytes, e.g. in letter case, still find their row
+	keys, err := td.keyComparer()
+	if err != nil {
+		return err
+	}
+	keyColumns := ctx.UniqueKey.Names()
+	checkColumns := ctx.CheckColumns.Names()
+	columns := types.NewColumnList(append(append([]string{}, keyColumns...), checkColumns...))
+	if err := sortByKey(keys, modified, func(i int) []interface{} {
+		return keyValuesOf(report.SampleDifferences[i].PrimaryKeyValues, keyColumns)
+	}); err != nil {
+		return err
+	}
+
+	for start := 0; start < len(modified); start += columnDifferenceBatchSize {
+		end := start + columnDifferenceBatchSize
+		if end > len(modified) {
+			end = len(modified)
+		}
+		batch := modified[start:end]
+
+		pkBatch := make([]map[string]interface{}, len(batch))
+		batchKeys := make([][]interface{}, len(batch))
+		for j, i := range batch {
+			pkBatch[j] = report.SampleDifferences[i].PrimaryKeyValues
+			batchKeys[j] = keyValuesOf(pkBatch[j], keyColumns)
+		}
+		sourceRows, err := td.fetchRowDataBatch(ctx.sourceDB(), ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, pkBatch, columns)
+		if err != nil {
+			return fmt.Errorf("failed to get source rows: %v", err)
+		}
+		targetRows, err := td.fetchRowDataBatch(ctx.targetDB(), ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, pkBatch, columns)
+		if err != nil {
+			return fmt.Errorf("failed to get target rows: %v", err)
+		}
+
+		sourceMatches, err := matchRowsByKey(keys, batchKeys, sourceRows, keyColumns)
+		if err != nil {
+			return err
+		}
+		targetMatches, err := matchRowsByKey(keys, batchKeys, targetRows, keyColumns)
+		if err != nil {
+			return err
+		}
+		for j, i := range batch {
+			if sourceMatches[j] != nil && targetMatches[j] != nil {
+				report.SampleDifferences[i].ColumnDifferences = diffColumns(sourceMatches[j], targetMatches[j], checkColumns)
+			}
+		}
+	}
+	return nil
+}
+
+// keyValuesOf returns the values of the key columns of a row or key map, in key column order
+func keyValuesOf(values map[string]interface{}, keyColumns []string) []interface{} {
+	key := make([]interface{}, len(keyColumns))
+	for i, name := range keyColumns {
+		key[i] = values[name]
+	}
+	return key
+}
+
+// sortByKey sorts the indexes by the key each one has, in the order MySQL sorts the keys
+func sortByKey(keys *keyComparer, indexes []int, keyOf func(i int) []interface{}) (err error) {
+	sort.SliceStable(indexes, func(a, b int) bool {
+		if err != nil {
+			return false
+		}
+		var order int
+		order, err = keys.compare(keyOf(indexes[a]), keyOf(indexes[b]))
+		return order < 0
+	})
+	return err
+}
+
+// matchRowsByKey pairs each key with the row holding it, nil when there is none. Keys and rows
+// are both in key order and are walked together, comparing keys as MySQL does.
+func matchRowsByKey(keys *keyComparer, batchKeys [][]interface{}, rows []map[string]interface{}, keyColumns []string) ([]map[string]interface{}, error) {
+	matches := make([]map[string]interface{}, len(batchKeys))
+	row := 0
+	for i, key := range batchKeys {
+		for row < len(rows) {
+			order, err := keys.compare(keyValuesOf(rows[row], keyColumns), key)
+			if err != nil {
+				return nil, err
+			}
+			if order > 0 {
+				break
+			}
+			if order == 0 {
+				matches[i] = rows[row]
+				break
+			}
+			row++
+		}
+	}
+	return matches, nil
+}
+
+// diffColumns returns the columns whose values differ between the two rows, in the given order
+func diffColumns(sourceRow, targetRow map[string]interface{}, columns []string) (differences []ColumnDifference) {
+	for _, name := range columns {
+		if sameColumnValue(sourceRow[name], targetRow[name]) {
+			continue
+		}
+		differences = append(differences, ColumnDifference{
+			Column:      name,
+			SourceValue: formatColumnValue(sourceRow[name]),
+			TargetValue: formatColumnValue(targetRow[name]),
+		})
+	}
+	return differences
+}
+
+// sameColumnValue reports whether two scanned column values are the same
+func sameColumnValue(source, target interface{}) bool {
+	if source == nil || target == nil {
+		return source == nil && target == nil
+	}
+	switch s := source.(type) {
+	case []byte:
+		t, ok := target.([]byte)
+		return ok && bytes.Equal(s, t)
+	case time.Time:
+		t, ok := target.(time.Time)
+		return ok && s.Equal(t)
+	}
+	return fmt.Sprintf("%v", source) == fmt.Sprintf("%v", target)
+}
+
+// formatColumnValue renders a scanned column value for display and tracking: nil for NULL,
+// otherwise its text, cut to maxColumnValueLength characters
+func formatColumnValue(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	var text string
+	size := 0
+	switch v := value.(type) {
+	case []byte:
+		size = len(v)
+		if utf8.Valid(v) && bytes.IndexByte(v, 0) < 0 {
+			text = string(v)
+		} else {
+			text = "0x" + hex.EncodeToString(v)
+		}
+	case time.Time:
+		if v.IsZero() {
+			text = "0000-00-00 00:00:00"
+		} else {
+			text = v.Format("2006-01-02 15:04:05.999999")
+		}
+	default:
+		text = formatPrimaryKeyValue(v)
+	}
+	if size == 0 {
+		size = len(text)
+	}
+	if utf8.RuneCountInString(text) <= maxColumnValueLength {
+		return text
+	}
+	cut := 0
+	for i := 0; i < maxColumnValueLength; i++ {
+		_, width := utf8.DecodeRuneInString(text[cut:])
+		cut += width
+	}
+	return fmt.Sprintf("%s... (%d bytes)", text[:cut], size)
+}
+
+// displayColumnValue quotes a formatted column value for log messages, NULL for nil
+func displayColumnValue(value interface{}) string {
+	if value == nil {
+		return "NULL"
+	}
+	return strconv.Quote(fmt.Sprintf("%v", value))
+}
@@ -0,0 +1,98 @@
+package checksum
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffColumns(t *testing.T) {
+	stamp := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	source := map[string]interface{}{
+		"id":         []byte("7"),
+		"name":       []byte("alice"),
+		"price":      []byte("10.00"),
+		"note":       nil,
+		"updated_at": stamp,
+	}
+	target := map[string]interface{}{
+		"id":         []byte("7"),
+		"name":       []byte("alice"),
+		"price":      []byte("12.50"),
+		"note":       []byte("NULL"),
+		"updated_at": stamp.In(time.FixedZone("CEST", 2*3600)),
+	}
+	got := diffColumns(source, target, []string{"id", "name", "price", "note", "updated_at"})
+	want := []ColumnDifference{
+		{Column: "price", SourceValue: "10.00", TargetValue: "12.50"},
+		{Column: "note", SourceValue: nil, TargetValue: "NULL"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffColumns = %+v, want %+v", got, want)
+	}
+}
+
+// Rows are matched by key order, not by the bytes of their keys
+func TestMatchRowsByKey(t *testing.T) {
+	keys := &keyComparer{columns: []keyColumn{{name: "code", dataType: "decimal"}}}
+	row := func(code string) map[string]interface{} {
+		return map[string]interface{}{"code": []byte(code)}
+	}
+	rows := []map[string]interface{}{row("1.0"), row("2.50"), row("4")}
+	batchKeys := [][]interface{}{{int64(1)}, {[]byte("2.5")}, {[]byte("3")}, {[]byte("4.00")}}
+	matches, err := matchRowsByKey(keys, batchKeys, rows, []string{"code"})
+	if err != nil {
+		t.Fatalf("matchRowsByKey: %v", err)
+	}
+	want := []map[string]interface{}{rows[0], rows[1], nil, rows[2]}
+	if !reflect.DeepEqual(matches, want) {
+		t.Errorf("matches = %v, want %v", matches, want)
+	}
+
+	indexes := []int{0, 1, 2}
+	sampleKeys := [][]interface{}{{[]byte("10")}, {[]byte("9")}, {[]byte("-1")}}
+	if err := sortByKey(keys, indexes, func(i int) []interface{} { return sampleKeys[i] }); err != nil {
+		t.Fatalf("sortByKey: %v", err)
+	}
+	if !reflect.DeepEqual(indexes, []int{2, 1, 0}) {
+		t.Errorf("sorted indexes = %v, want [2 1 0]", indexes)
+	}
+}
+
+func TestFormatColumnValue(t *testing.T) {
+	long := strings.Repeat("é", maxColumnValueLength+10)
+	tests := []struct {
+		name  string
+		value interface{}
+		want  interface{}
+	}{
+		{"null", nil, nil},
+		{"text", []byte("hello"), "hello"},
+		{"binary", []byte{0xff, 0x00, 0x10}, "0xff0010"},
+		{"integer", int64(42), "42"},
+		{"datetime", time.Date(2024, 5, 1, 12, 30, 0, 500000000, time.UTC), "2024-05-01 12:30:00.5"},
+		{"zero datetime", time.Time{}, "0000-00-00 00:00:00"},
+		{"truncated at character boundary", []byte(long), strings.Repeat("é", maxColumnValueLength) + "... (148 bytes)"},
+		{"truncated binary", make([]byte, 40), "0x" + strings.Repeat("0", maxColumnValueLength-2) + "... (40 bytes)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatColumnValue(tt.value); got != tt.want {
+				t.Errorf("formatColumnValue = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDisplayColumnValue(t *testing.T) {
+	if got := displayColumnValue(nil); got != "NULL" {
+		t.Errorf("displayColumnValue(nil) = %s, want NULL", got)
+	}
+	if got := displayColumnValue("NULL"); got != `"NULL"` {
+		t.Errorf(`displayColumnValue("NULL") = %s, want "NULL" quoted`, got)
+	}
+	if got := displayColumnValue("a\nb"); got != `"a\nb"` {
+		t.Errorf("displayColumnValue escapes newlines: got %s", got)
+	}
+}
@@ -1,11 +1,10 @@
 package checksum
 
 import (
-	"database/sql"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
-	"sync/atomic"
 	"time"
 
 	"github.com/ChaosHour/go-data-checksum/pkg/builder"
@@ -15,6 +14,8 @@ import (
 // TableDiffer provides detailed differential analysis between source and target tables
 type TableDiffer struct {
 	Context *ChecksumContext
+	// keys compares the unique-key values of merged records (see mergejoin.go)
+	keys *keyComparer
 }
 
 // DifferenceReport contains the results of differential analysis
@@ -28,11 +29,16 @@ type DifferenceReport struct {
 
 // RecordDifference represents a specific record difference
 type RecordDifference struct {
-	PrimaryKeyValues map[string]interface{}
-	DifferenceType   string // "source_only", "target_only", "modified"
+	PrimaryKeyValues map[string]interface{} // the check column values on keyless tables
+	DifferenceType   string                 // "source_only", "target_only", "modified", "count_mismatch" (keyless)
 	SourceChecksum   string
 	TargetChecksum   string
 	FullRowData      map[string]interface{} // Full row data from source for REPLACE INTO
+	// The check columns that differ, modified records only (see columndiff.go)
+	ColumnDifferences []ColumnDifference
+	// Copies of the row on each side, keyless tables only
+	SourceCount int64
+	TargetCount int64
 }
 
 // AnalyzeAndReportDifferences performs comprehensive differential analysis.
@@ -45,13 +51,6 @@ func (td *TableDiffer) AnalyzeAndReportDifferences() error {
 		ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName,
 		ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName)
 
-	// Reset any iteration state left over from the checksum loop so the
-	// analysis covers the whole table, not just the range after the first
-	// mismatched chunk.
-	atomic.StoreInt64(&ctx.PerTableContext.Iteration, 0)
-	ctx.ChecksumIterationRangeMinValues = nil
-	ctx.ChecksumIterationRangeMaxValues = nil
-
 	// Get min/max values for iteration
 	if err := ctx.ReadUniqueKeyRangeMinValues(); err != nil {
 		return err
@@ -60,40 +59,94 @@ func (td *TableDiffer) AnalyzeAndReportDifferences() error {
 		return err
 	}
 
-	report := &DifferenceReport{
-		SampleDifferences: make([]RecordDifference, 0),
-	}
-	maxSamples := ctx.Context.MaxSampleDifferences
-
 	sourceIsEmpty := len(ctx.UniqueKeyRangeMinValues.AbstractValues()) == 0 ||
 		ctx.UniqueKeyRangeMinValues.AbstractValues()[0] == nil
 
+	var ranges []KeyRange
 	if !sourceIsEmpty {
-		// Process data in chunks for differential analysis
-		var hasFurtherRange = true
-		for hasFurtherRange {
-			var err error
-			hasFurtherRange, err = ctx.CalculateNextIterationRangeEndValues()
-			if err != nil {
-				return err
-			}
+		ranges = append(ranges, KeyRange{Min: ctx.UniqueKeyRangeMinValues, Max: ctx.UniqueKeyRangeMaxValues, IncludeMin: true})
+	}
+	return td.analyzeAndReport(ranges, sourceIsEmpty)
+}
 
-			if hasFurtherRange {
-				chunkReport, err := td.analyzeChunkDifferences()
-				if err != nil {
-					return err
-				}
-				td.mergeChunkReport(report, chunkReport, maxSamples)
-				ctx.AddIteration()
-			}
+// AnalyzeAndReportRangeDifferences performs the differential analysis on the given
+// key ranges only: the mismatched chunks of the checksum loop, including those a
+// resumed table carried over from chunk_comparisons, or their bisected pieces.
+// Unless --skip-target-sweep is set, target rows outside the source key range are
+// still swept. It relies on the unique-key min/max values read by the checksum loop.
+func (td *TableDiffer) AnalyzeAndReportRangeDifferences(ranges []KeyRange) error {
+	ctx := td.Context
+
+	ctx.Context.Log.Infof("Starting differential analysis of %d key range(s) for table pair: %s.%s => %s.%s",
+		len(ranges),
+		ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName,
+		ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName)
+
+	return td.analyzeAndReport(ranges, false)
+}
+
+// AnalyzeAndReportTimeRangeDifferences performs the differential analysis on the given time
+// ranges only: the mismatched chunks of the time-column loop, including those a resumed table
+// carried over from chunk_comparisons. The records of each range are merge-joined by unique key;
+// a row whose time column differs between the sides falls outside the range on one side and is
+// reported as source-only or target-only. The target is not swept outside the ranges.
+func (td *TableDiffer) AnalyzeAndReportTimeRangeDifferences(ranges []TimeRange) error {
+	ctx := td.Context
+
+	ctx.Context.Log.Infof("Starting differential analysis of %d time range(s) for table pair: %s.%s => %s.%s",
+		len(ranges),
+		ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName,
+		ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName)
+
+	report := &DifferenceReport{
+		SampleDifferences: make([]RecordDifference, 0),
+	}
+	for _, timeRange := range ranges {
+		if err := ctx.Throttle(); err != nil {
+			return err
+		}
+		whereClause := builder.BuildTimeRangePreparedComparison(ctx.Context.SpecifiedDatetimeColumn, timeRange.IncludeMax)
+		rangeReport, err := td.analyzeRecordDifferences(whereClause, []interface{}{timeRange.Min, timeRange.Max})
+		if err != nil {
+			return err
 		}
+		td.mergeChunkReport(report, rangeReport, ctx.Context.MaxSampleDifferences)
+	}
+	return td.finishReport(report)
+}
+
+func (td *TableDiffer) analyzeAndReport(ranges []KeyRange, sourceIsEmpty bool) error {
+	ctx := td.Context
+
+	report := &DifferenceReport{
+		SampleDifferences: make([]RecordDifference, 0),
+	}
+	maxSamples := ctx.Context.MaxSampleDifferences
+
+	// Process data in chunks for differential analysis, --diff-threads at a time
+	if err := td.analyzeKeyRanges(ranges, report, maxSamples); err != nil {
+		return err
 	}
 
 	// Chunk boundaries are driven from the source table, so target rows with
 	// keys outside the source key range (or every target row, when the source
 	// table is empty) have not been seen yet. Sweep them as target-only.
-	if err := td.collectOutOfRangeTargetRecords(report, sourceIsEmpty, maxSamples); err != nil {
-		return err
+	if !ctx.Context.SkipTargetSweep {
+		if err := td.collectOutOfRangeTargetRecords(report, sourceIsEmpty, maxSamples); err != nil {
+			return err
+		}
+	}
+	return td.finishReport(report)
+}
+
+// finishReport completes the analyzed differences with their columns, then reports, tracks and
+// optionally turns them into sync SQL
+func (td *TableDiffer) finishReport(report *DifferenceReport) error {
+	ctx := td.Context
+
+	// Find the columns that differ in the sampled modified records
+	if err := td.addColumnDifferences(report); err != nil {
+		ctx.Context.Log.Warnf("Failed to compare the columns of modified records: %v", err)
 	}
 
 	// Report final results
@@ -113,6 +166,37 @@ func (td *TableDiffer) AnalyzeAndReportDifferences() error {
 	return nil
 }
 
+// walkKeyRange walks a key range in chunks of the table's chunk size, visiting each
+// chunk in key order. The last chunk always extends to the end of the range, so target
+// rows past the last source key in the range are examined too.
+func (td *TableDiffer) walkKeyRange(keyRange KeyRange, visit func(chunk KeyRange) error) error {
+	ctx := td.Context
+
+	chunk := KeyRange{Min: keyRange.Min, IncludeMin: keyRange.IncludeMin}
+	for {
+		if err := ctx.Throttle(); err != nil {
+			return err
+		}
+		chunkMax, found, err := ctx.probeRangeEnd(false, chunk.Min, keyRange.Max, chunk.IncludeMin, ctx.GetChunkSize(), "differential")
+		if err != nil {
+			return err
+		}
+		chunk.Max = keyRange.Max
+		if found {
+			chunk.Max = chunkMax
+		}
+
+		if err := visit(chunk); err != nil {
+			return err
+		}
+
+		if !found {
+			return nil
+		}
+		chunk = KeyRange{Min: chunk.Max}
+	}
+}
+
 // mergeChunkReport aggregates a chunk report into the total report, keeping
 // the sample list capped at maxSamples.
 func (td *TableDiffer) mergeChunkReport(report, chunkReport *DifferenceReport, maxSamples int) {
@@ -129,22 +213,26 @@ func (td *TableDiffer) mergeChunkReport(report, chunkReport *DifferenceReport, m
 	}
 }
 
-// analyzeChunkDifferences analyzes differences in the current chunk
-func (td *TableDiffer) analyzeChunkDifferences() (*DifferenceReport, error) {
+// analyzeChunkDifferences analyzes differences in a chunk
+func (td *TableDiffer) analyzeChunkDifferences(chunk KeyRange) (*DifferenceReport, error) {
 	ctx := td.Context
 
 	// Build the range condition shared by the source and target chunk queries
+	startComparisonSign := builder.GreaterThanComparisonSign
+	if chunk.IncludeMin {
+		startComparisonSign = builder.GreaterThanOrEqualsComparisonSign
+	}
 	rangeStartComparison, rangeStartArgs, err := builder.BuildRangePreparedComparison(
 		ctx.UniqueKey,
-		ctx.ChecksumIterationRangeMinValues.AbstractValues(),
-		builder.GreaterThanOrEqualsComparisonSign,
+		chunk.Min.AbstractValues(),
+		startComparisonSign,
 	)
 	if err != nil {
 		return nil, err
 	}
 	rangeEndComparison, rangeEndArgs, err := builder.BuildRangePreparedComparison(
 		ctx.UniqueKey,
-		ctx.ChecksumIterationRangeMaxValues.AbstractValues(),
+		chunk.Max.AbstractValues(),
 		builder.LessThanOrEqualsComparisonSign,
 	)
 	if err != nil {
@@ -152,9 +240,19 @@ func (td *TableDiffer) analyzeChunkDifferences() (*DifferenceReport, error) {
 	}
 	whereClause := fmt.Sprintf("%s AND %s", rangeStartComparison, rangeEndComparison)
 	args := append(rangeStartArgs, rangeEndArgs...)
+	return td.analyzeRecordDifferences(whereClause, args)
+}
+
+// analyzeRecordDifferences merge-joins the source and target records matching the where clause
+func (td *TableDiffer) analyzeRecordDifferences(whereClause string, args []interface{}) (*DifferenceReport, error) {
+	ctx := td.Context
 
-	sourceRecords, err := td.getChunkRecords(
-		ctx.Context.SourceDB,
+	keys, err := td.keyComparer()
+	if err != nil {
+		return nil, err
+	}
+	sourceRecords, err := td.queryRecords(
+		ctx.sourceDB(),
 		ctx.PerTableContext.SourceDatabaseName,
 		ctx.PerTableContext.SourceTableName,
 		whereClause, args,
@@ -162,9 +260,10 @@ func (td *TableDiffer) analyzeChunkDifferences() (*DifferenceReport, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get source records: %v", err)
 	}
+	defer sourceRecords.close()
 
-	targetRecords, err := td.getChunkRecords(
-		ctx.Context.TargetDB,
+	targetRecords, err := td.queryRecords(
+		ctx.targetDB(),
 		ctx.PerTableContext.TargetDatabaseName,
 		ctx.PerTableContext.TargetTableName,
 		whereClause, args,
@@ -172,9 +271,10 @@ func (td *TableDiffer) analyzeChunkDifferences() (*DifferenceReport, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get target records: %v", err)
 	}
+	defer targetRecords.close()
 
-	// Compare the records
-	return td.compareRecordSets(sourceRecords, targetRecords), nil
+	// Merge-join the records as they stream in
+	return td.mergeRecords(sourceRecords, targetRecords, keys)
 }
 
 // collectOutOfRangeTargetRecords finds target rows whose keys fall outside the
@@ -214,86 +314,72 @@ func (td *TableDiffer) collectOutOfRangeTargetRecords(report *DifferenceReport,
 	}
 
 	for _, s := range sweeps {
-		targetRecords, err := td.getChunkRecords(
-			ctx.Context.TargetDB,
-			ctx.PerTableContext.TargetDatabaseName,
-			ctx.PerTableContext.TargetTableName,
-			s.whereClause, s.args,
-		)
-		if err != nil {
+		if err := td.sweepTargetRecords(report, s.whereClause, s.args, maxSamples); err != nil {
 			return fmt.Errorf("failed to get out-of-range target records: %v", err)
 		}
-		for _, targetRecord := range targetRecords {
-			report.TargetOnlyRecords++
-			if len(report.SampleDifferences) < maxSamples {
-				report.SampleDifferences = append(report.SampleDifferences, RecordDifference{
-					PrimaryKeyValues: targetRecord.PrimaryKeyValues,
-					DifferenceType:   "target_only",
-					SourceChecksum:   "",
-					TargetChecksum:   targetRecord.Checksum,
-				})
-			}
-		}
 	}
 	return nil
 }
 
-// getChunkRecords retrieves records with checksums matching the given where clause
-func (td *TableDiffer) getChunkRecords(db *sql.DB, databaseName, tableName, whereClause string, args []interface{}) (map[string]RecordData, error) {
+// sweepTargetRecords reports every target record matching the where clause as target-only
+func (td *TableDiffer) sweepTargetRecords(report *DifferenceReport, whereClause string, args []interface{}, maxSamples int) error {
 	ctx := td.Context
 
-	query, err := td.buildRecordQuery(databaseName, tableName, whereClause)
+	targetRecords, err := td.queryRecords(
+		ctx.targetDB(),
+		ctx.PerTableContext.TargetDatabaseName,
+		ctx.PerTableContext.TargetTableName,
+		whereClause, args,
+	)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer targetRecords.close()
+	for {
+		targetRecord, ok, err := targetRecords.next()
+		if err != nil || !ok {
+			return err
+		}
+		report.TargetOnlyRecords++
+		if len(report.SampleDifferences) < maxSamples {
+			report.SampleDifferences = append(report.SampleDifferences, RecordDifference{
+				PrimaryKeyValues: targetRecord.PrimaryKeyValues,
+				DifferenceType:   "target_only",
+				SourceChecksum:   "",
+				TargetChecksum:   targetRecord.Checksum,
+			})
+		}
+	}
+}
 
-	rows, err := db.Query(query, args...)
+// queryRecords streams the records with checksums matching the given where clause, in unique-key order
+func (td *TableDiffer) queryRecords(db dbQuerier, databaseName, tableName, whereClause string, args []interface{}) (*rowsRecordSource, error) {
+	keys, err := td.keyComparer()
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	records := make(map[string]RecordData)
-
-	// Prepare scan destinations
-	pkColumns := ctx.UniqueKey.Len()
-	scanDest := make([]interface{}, pkColumns+1) // PK columns + checksum
-	scanPtrs := make([]interface{}, pkColumns+1)
-	for i := range scanDest {
-		scanPtrs[i] = &scanDest[i]
+	query, err := td.buildRecordQuery(databaseName, tableName, whereClause, keys)
+	if err != nil {
+		return nil, err
 	}
-
-	for rows.Next() {
-		if err := rows.Scan(scanPtrs...); err != nil {
-			return nil, err
-		}
-
-		// Build primary key string for comparison
-		pkValues := make([]string, pkColumns)
-		pkMap := make(map[string]interface{})
-		for i := 0; i < pkColumns; i++ {
-			pkValues[i] = formatPrimaryKeyValue(scanDest[i])
-			pkMap[ctx.UniqueKey.Columns()[i].Name] = scanDest[i]
-		}
-		pkKey := strings.Join(pkValues, "|")
-
-		records[pkKey] = RecordData{
-			PrimaryKeyValues: pkMap,
-			Checksum:         formatPrimaryKeyValue(scanDest[pkColumns]),
-		}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
 	}
-
-	return records, rows.Err()
+	return newRowsRecordSource(rows, td.Context.UniqueKey.Names(), keys.weightedColumns()), nil
 }
 
 // RecordData represents a single record's data
 type RecordData struct {
 	PrimaryKeyValues map[string]interface{}
+	KeyValues        []interface{} // the unique-key values in key column order
+	KeyWeights       [][]byte      // the collation weights of the non-binary string key columns, nil for the others
 	Checksum         string
 }
 
-// buildRecordQuery builds a query to get primary key values and record checksums
-func (td *TableDiffer) buildRecordQuery(databaseName, tableName, whereClause string) (string, error) {
+// buildRecordQuery builds a query to get primary key values and record checksums, followed by the
+// collation weights of the non-binary string key columns of keys (none when keys is nil)
+func (td *TableDiffer) buildRecordQuery(databaseName, tableName, whereClause string, keys *keyComparer) (string, error) {
 	ctx := td.Context
 
 	// Build column list for primary key + checksum
@@ -303,26 +389,27 @@ func (td *TableDiffer) buildRecordQuery(databaseName, tableName, whereClause str
 		escapedPKColumns[i] = types.EscapeName(col)
 	}
 
-	// Build checksum column list
-	checkColumnNames := ctx.CheckColumns.Names()
-	escapedCheckColumns := make([]string, len(checkColumnNames))
-	for i, col := range checkColumnNames {
-		escapedCheckColumns[i] = fmt.Sprintf("hex(%s)", types.EscapeName(col))
-	}
-
+	// Hash the check columns exactly like the chunk queries do
 	selectColumns := append(escapedPKColumns,
-		fmt.Sprintf("COALESCE(LOWER(CONV(cast(crc32(CONCAT_WS('#', %s)) as UNSIGNED), 10, 16)), 0) as record_checksum",
-			strings.Join(escapedCheckColumns, ", ")))
+		builder.BuildRowHashExpression(ctx.Context.HashFunction, builder.BuildCheckColumnsListing(ctx.CheckColumns.Names()))+" as record_checksum")
+	if keys != nil {
+		for i, weighted := range keys.weightedColumns() {
+			if weighted {
+				selectColumns = append(selectColumns, fmt.Sprintf("WEIGHT_STRING(%s) as key_weight_%d", escapedPKColumns[i], i))
+			}
+		}
+	}
 
 	query := fmt.Sprintf(`
 		SELECT %s
-		FROM %s.%s
+		FROM %s.%s%s
 		WHERE %s
 		ORDER BY %s
 	`,
 		strings.Join(selectColumns, ", "),
 		types.EscapeName(databaseName),
 		types.EscapeName(tableName),
+		builder.PartitionClause(ctx.partition),
 		whereClause,
 		strings.Join(escapedPKColumns, ", "),
 	)
@@ -330,57 +417,6 @@ func (td *TableDiffer) buildRecordQuery(databaseName, tableName, whereClause str
 	return query, nil
 }
 
-// compareRecordSets compares two sets of records and returns differences
-func (td *TableDiffer) compareRecordSets(sourceRecords, targetRecords map[string]RecordData) *DifferenceReport {
-	report := &DifferenceReport{
-		SampleDifferences: make([]RecordDifference, 0),
-	}
-
-	// Find records only in source
-	for pkKey, sourceRecord := range sourceRecords {
-		if targetRecord, exists := targetRecords[pkKey]; !exists {
-			report.SourceOnlyRecords++
-			if len(report.SampleDifferences) < td.Context.Context.MaxSampleDifferences {
-				report.SampleDifferences = append(report.SampleDifferences, RecordDifference{
-					PrimaryKeyValues: sourceRecord.PrimaryKeyValues,
-					DifferenceType:   "source_only",
-					SourceChecksum:   sourceRecord.Checksum,
-					TargetChecksum:   "",
-				})
-			}
-		} else if sourceRecord.Checksum != targetRecord.Checksum {
-			report.ModifiedRecords++
-			if len(report.SampleDifferences) < td.Context.Context.MaxSampleDifferences {
-				report.SampleDifferences = append(report.SampleDifferences, RecordDifference{
-					PrimaryKeyValues: sourceRecord.PrimaryKeyValues,
-					DifferenceType:   "modified",
-					SourceChecksum:   sourceRecord.Checksum,
-					TargetChecksum:   targetRecord.Checksum,
-				})
-			}
-		} else {
-			report.IdenticalRecords++
-		}
-	}
-
-	// Find records only in target
-	for pkKey, targetRecord := range targetRecords {
-		if _, exists := sourceRecords[pkKey]; !exists {
-			report.TargetOnlyRecords++
-			if len(report.SampleDifferences) < td.Context.Context.MaxSampleDifferences {
-				report.SampleDifferences = append(report.SampleDifferences, RecordDifference{
-					PrimaryKeyValues: targetRecord.PrimaryKeyValues,
-					DifferenceType:   "target_only",
-					SourceChecksum:   "",
-					TargetChecksum:   targetRecord.Checksum,
-				})
-			}
-		}
-	}
-
-	return report
-}
-
 // reportResults outputs the final difference report
 func (td *TableDiffer) reportResults(report *DifferenceReport) {
 	ctx := td.Context
@@ -419,22 +455,22 @@ func (td *TableDiffer) reportResults(report *DifferenceReport) {
 		for i := 0; i < maxDisplay; i++ {
 			diff := report.SampleDifferences[i]
 
-			pkStr := ""
-			for key, value := range diff.PrimaryKeyValues {
-				if pkStr != "" {
-					pkStr += ", "
-				}
-				pkStr += fmt.Sprintf("%s=%s", key, formatPrimaryKeyValue(value))
-			}
+			pkStr := formatPrimaryKeyMap(diff.PrimaryKeyValues)
 
 			switch diff.DifferenceType {
 			case "source_only":
 				ctx.Context.Log.Errorf("- Record (%s) exists only in source", pkStr)
 			case "target_only":
 				ctx.Context.Log.Errorf("+ Record (%s) exists only in target", pkStr)
+			case "count_mismatch":
+				ctx.Context.Log.Errorf("# Record (%s) appears %dx on source, %dx on target", pkStr, diff.SourceCount, diff.TargetCount)
 			case "modified":
 				ctx.Context.Log.Errorf("~ Record (%s) modified: source_checksum=%s, target_checksum=%s",
 					pkStr, diff.SourceChecksum, diff.TargetChecksum)
+				for _, column := range diff.ColumnDifferences {
+					ctx.Context.Log.Errorf("    %s: source=%s, target=%s",
+						column.Column, displayColumnValue(column.SourceValue), displayColumnValue(column.TargetValue))
+				}
 			}
 		}
 
@@ -559,17 +595,29 @@ func (td *TableDiffer) generateSyncSQL(report *DifferenceReport) error {
 	return nil
 }
 
-// formatPrimaryKeyMap renders a primary key map for log messages
+// formatPrimaryKeyMap renders a primary key map for log messages, columns in name order
 func formatPrimaryKeyMap(pkValues map[string]interface{}) string {
+	keys := make([]string, 0, len(pkValues))
+	for key := range pkValues {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
 	parts := make([]string, 0, len(pkValues))
-	for key, value := range pkValues {
-		parts = append(parts, fmt.Sprintf("%s=%s", key, formatPrimaryKeyValue(value)))
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%s", key, formatPrimaryKeyValue(pkValues[key])))
 	}
 	return strings.Join(parts, ", ")
 }
 
 // fetchFullRowDataBatch retrieves complete row data for a batch of primary keys in a single query
 func (td *TableDiffer) fetchFullRowDataBatch(pkBatch []map[string]interface{}, columns *types.ColumnList) ([]map[string]interface{}, error) {
+	ctx := td.Context
+	return td.fetchRowDataBatch(ctx.sourceDB(), ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, pkBatch, columns)
+}
+
+// fetchRowDataBatch retrieves the given columns of a batch of primary keys from a table in a single query,
+// in key order
+func (td *TableDiffer) fetchRowDataBatch(db dbQuerier, databaseName, tableName string, pkBatch []map[string]interface{}, columns *types.ColumnList) ([]map[string]interface{}, error) {
 	ctx := td.Context
 	if len(pkBatch) == 0 {
 		return nil, nil
@@ -613,13 +661,20 @@ func (td *TableDiffer) fetchFullRowDataBatch(pkBatch []map[string]interface{}, c
 		whereClause = fmt.Sprintf("(%s) IN (%s)", strings.Join(escapedPkColNames, ", "), strings.Join(rowPlaceholders, ", "))
 	}
 
-	query := fmt.Sprintf("SELECT %s FROM %s.%s WHERE %s",
+	escapedKeyColumns := make([]string, numCols)
+	for i, col := range pkCols {
+		escapedKeyColumns[i] = types.EscapeName(col.Name)
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s.%s%s WHERE %s ORDER BY %s",
 		strings.Join(escapedColumns, ", "),
-		types.EscapeName(ctx.PerTableContext.SourceDatabaseName),
-		types.EscapeName(ctx.PerTableContext.SourceTableName),
-		whereClause)
+		types.EscapeName(databaseName),
+		types.EscapeName(tableName),
+		builder.PartitionClause(ctx.partition),
+		whereClause,
+		strings.Join(escapedKeyColumns, ", "))
 
-	rows, err := ctx.Context.SourceDB.Query(query, args...)
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
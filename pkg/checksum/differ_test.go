@@ -8,8 +8,23 @@ import (
 	"github.com/ChaosHour/go-data-checksum/pkg/types"
 )
 
-// TestCompareRecordSets tests the core comparison logic
-func TestCompareRecordSets(t *testing.T) {
+// recordList is a recordSource over records given in key order
+type recordList []RecordData
+
+func (records *recordList) next() (RecordData, bool, error) {
+	if len(*records) == 0 {
+		return RecordData{}, false, nil
+	}
+	record := (*records)[0]
+	*records = (*records)[1:]
+	return record, true, nil
+}
+
+// intKeys compares single-column integer keys
+var intKeys = &keyComparer{columns: []keyColumn{{name: "id", dataType: "int"}}}
+
+// TestMergeRecords tests the core comparison logic
+func TestMergeRecords(t *testing.T) {
 	// Create a proper context for testing
 	baseCtx := types.NewBaseContext()
 	td := &TableDiffer{
@@ -20,8 +35,8 @@ func TestCompareRecordSets(t *testing.T) {
 
 	tests := []struct {
 		name             string
-		sourceRecords    map[string]RecordData
-		targetRecords    map[string]RecordData
+		sourceRecords    recordList
+		targetRecords    recordList
 		expectSourceOnly int64
 		expectTargetOnly int64
 		expectModified   int64
@@ -29,23 +44,27 @@ func TestCompareRecordSets(t *testing.T) {
 	}{
 		{
 			name: "identical records",
-			sourceRecords: map[string]RecordData{
-				"1": {
+			sourceRecords: recordList{
+				{
 					PrimaryKeyValues: map[string]interface{}{"id": 1},
+					KeyValues:        []interface{}{int64(1)},
 					Checksum:         "abc123",
 				},
-				"2": {
+				{
 					PrimaryKeyValues: map[string]interface{}{"id": 2},
+					KeyValues:        []interface{}{int64(2)},
 					Checksum:         "def456",
 				},
 			},
-			targetRecords: map[string]RecordData{
-				"1": {
+			targetRecords: recordList{
+				{
 					PrimaryKeyValues: map[string]interface{}{"id": 1},
+					KeyValues:        []interface{}{int64(1)},
 					Checksum:         "abc123",
 				},
-				"2": {
+				{
 					PrimaryKeyValues: map[string]interface{}{"id": 2},
+					KeyValues:        []interface{}{int64(2)},
 					Checksum:         "def456",
 				},
 			},
@@ -56,19 +75,22 @@ func TestCompareRecordSets(t *testing.T) {
 		},
 		{
 			name: "source only records",
-			sourceRecords: map[string]RecordData{
-				"1": {
+			sourceRecords: recordList{
+				{
 					PrimaryKeyValues: map[string]interface{}{"id": 1},
+					KeyValues:        []interface{}{int64(1)},
 					Checksum:         "abc123",
 				},
-				"2": {
+				{
 					PrimaryKeyValues: map[string]interface{}{"id": 2},
+					KeyValues:        []interface{}{int64(2)},
 					Checksum:         "def456",
 				},
 			},
-			targetRecords: map[string]RecordData{
-				"1": {
+			targetRecords: recordList{
+				{
 					PrimaryKeyValues: map[string]interface{}{"id": 1},
+					KeyValues:        []interface{}{int64(1)},
 					Checksum:         "abc123",
 				},
 			},
@@ -79,19 +101,22 @@ func TestCompareRecordSets(t *testing.T) {
 		},
 		{
 			name: "target only records",
-			sourceRecords: map[string]RecordData{
-				"1": {
+			sourceRecords: recordList{
+				{
 					PrimaryKeyValues: map[string]interface{}{"id": 1},
+					KeyValues:        []interface{}{int64(1)},
 					Checksum:         "abc123",
 				},
 			},
-			targetRecords: map[string]RecordData{
-				"1": {
+			targetRecords: recordList{
+				{
 					PrimaryKeyValues: map[string]interface{}{"id": 1},
+					KeyValues:        []interface{}{int64(1)},
 					Checksum:         "abc123",
 				},
-				"2": {
+				{
 					PrimaryKeyValues: map[string]interface{}{"id": 2},
+					KeyValues:        []interface{}{int64(2)},
 					Checksum:         "def456",
 				},
 			},
@@ -102,23 +127,27 @@ func TestCompareRecordSets(t *testing.T) {
 		},
 		{
 			name: "modified records",
-			sourceRecords: map[string]RecordData{
-				"1": {
+			sourceRecords: recordList{
+				{
 					PrimaryKeyValues: map[string]interface{}{"id": 1},
+					KeyValues:        []interface{}{int64(1)},
 					Checksum:         "abc123",
 				},
-				"2": {
+				{
 					PrimaryKeyValues: map[string]interface{}{"id": 2},
+					KeyValues:        []interface{}{int64(2)},
 					Checksum:         "def456",
 				},
 			},
-			targetRecords: map[string]RecordData{
-				"1": {
+			targetRecords: recordList{
+				{
 					PrimaryKeyValues: map[string]interface{}{"id": 1},
+					KeyValues:        []interface{}{int64(1)},
 					Checksum:         "xyz789",
 				},
-				"2": {
+				{
 					PrimaryKeyValues: map[string]interface{}{"id": 2},
+					KeyValues:        []interface{}{int64(2)},
 					Checksum:         "def456",
 				},
 			},
@@ -129,31 +158,37 @@ func TestCompareRecordSets(t *testing.T) {
 		},
 		{
 			name: "mixed differences",
-			sourceRecords: map[string]RecordData{
-				"1": {
+			sourceRecords: recordList{
+				{
 					PrimaryKeyValues: map[string]interface{}{"id": 1},
+					KeyValues:        []interface{}{int64(1)},
 					Checksum:         "abc123",
 				},
-				"2": {
+				{
 					PrimaryKeyValues: map[string]interface{}{"id": 2},
+					KeyValues:        []interface{}{int64(2)},
 					Checksum:         "def456",
 				},
-				"3": {
+				{
 					PrimaryKeyValues: map[string]interface{}{"id": 3},
+					KeyValues:        []interface{}{int64(3)},
 					Checksum:         "ghi789",
 				},
 			},
-			targetRecords: map[string]RecordData{
-				"1": {
+			targetRecords: recordList{
+				{
 					PrimaryKeyValues: map[string]interface{}{"id": 1},
+					KeyValues:        []interface{}{int64(1)},
 					Checksum:         "abc123",
 				},
-				"2": {
+				{
 					PrimaryKeyValues: map[string]interface{}{"id": 2},
+					KeyValues:        []interface{}{int64(2)},
 					Checksum:         "modified",
 				},
-				"4": {
+				{
 					PrimaryKeyValues: map[string]interface{}{"id": 4},
+					KeyValues:        []interface{}{int64(4)},
 					Checksum:         "jkl012",
 				},
 			},
@@ -164,8 +199,8 @@ func TestCompareRecordSets(t *testing.T) {
 		},
 		{
 			name:             "empty tables",
-			sourceRecords:    map[string]RecordData{},
-			targetRecords:    map[string]RecordData{},
+			sourceRecords:    recordList{},
+			targetRecords:    recordList{},
 			expectSourceOnly: 0,
 			expectTargetOnly: 0,
 			expectModified:   0,
@@ -175,7 +210,10 @@ func TestCompareRecordSets(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			report := td.compareRecordSets(tt.sourceRecords, tt.targetRecords)
+			report, err := td.mergeRecords(&tt.sourceRecords, &tt.targetRecords, intKeys)
+			if err != nil {
+				t.Fatalf("mergeRecords: %v", err)
+			}
 
 			if report.SourceOnlyRecords != tt.expectSourceOnly {
 				t.Errorf("SourceOnlyRecords = %d, want %d", report.SourceOnlyRecords, tt.expectSourceOnly)
@@ -193,8 +231,8 @@ func TestCompareRecordSets(t *testing.T) {
 	}
 }
 
-// TestCompareRecordSets_SampleLimit tests that sample differences are limited
-func TestCompareRecordSets_SampleLimit(t *testing.T) {
+// TestMergeRecords_SampleLimit tests that sample differences are limited
+func TestMergeRecords_SampleLimit(t *testing.T) {
 	baseCtx := types.NewBaseContext()
 	baseCtx.MaxSampleDifferences = 10 // Set a low limit for testing
 
@@ -205,27 +243,35 @@ func TestCompareRecordSets_SampleLimit(t *testing.T) {
 	}
 
 	// Create 20 source-only records
-	sourceRecords := make(map[string]RecordData)
+	var sourceRecords recordList
 	for i := 1; i <= 20; i++ {
-		key := string(rune(i))
-		sourceRecords[key] = RecordData{
+		sourceRecords = append(sourceRecords, RecordData{
 			PrimaryKeyValues: map[string]interface{}{"id": i},
+			KeyValues:        []interface{}{int64(i)},
 			Checksum:         "checksum",
-		}
+		})
 	}
 
-	targetRecords := map[string]RecordData{}
+	targetRecords := recordList{}
 
-	report := td.compareRecordSets(sourceRecords, targetRecords)
+	report, err := td.mergeRecords(&sourceRecords, &targetRecords, intKeys)
+	if err != nil {
+		t.Fatalf("mergeRecords: %v", err)
+	}
 
 	if report.SourceOnlyRecords != 20 {
 		t.Errorf("SourceOnlyRecords = %d, want 20", report.SourceOnlyRecords)
 	}
 
-	// Should only capture first 10 samples
+	// Should only capture first 10 samples, in key order
 	if len(report.SampleDifferences) != 10 {
 		t.Errorf("SampleDifferences length = %d, want 10", len(report.SampleDifferences))
 	}
+	for i, diff := range report.SampleDifferences {
+		if diff.PrimaryKeyValues["id"] != i+1 {
+			t.Errorf("sample %d has id %v, want %d", i, diff.PrimaryKeyValues["id"], i+1)
+		}
+	}
 }
 
 // TestRecordDifference_PrimaryKeyTypes tests handling of different primary key types
@@ -342,13 +388,14 @@ func TestDifferenceReport_Aggregation(t *testing.T) {
 // TestBuildRecordQuery_ColumnEscaping tests SQL column name escaping
 func TestBuildRecordQuery_ColumnEscaping(t *testing.T) {
 	ctx := &ChecksumContext{
+		Context:      types.NewBaseContext(),
 		UniqueKey:    types.NewColumnList([]string{"id"}),
 		CheckColumns: types.NewColumnList([]string{"name", "email"}),
 	}
 
 	td := &TableDiffer{Context: ctx}
 
-	query, err := td.buildRecordQuery("test_db", "test_table", "(`id` >= ?) AND (`id` <= ?)")
+	query, err := td.buildRecordQuery("test_db", "test_table", "(`id` >= ?) AND (`id` <= ?)", nil)
 
 	if err != nil {
 		t.Fatalf("buildRecordQuery failed: %v", err)
@@ -371,6 +418,11 @@ func TestBuildRecordQuery_ColumnEscaping(t *testing.T) {
 		t.Error("Query should contain escaped table name `test_table`")
 	}
 
+	// Record hashes must encode the check columns like the chunk queries
+	if !contains(query, "ISNULL(`name`), COALESCE(hex(`name`), '')") {
+		t.Error("Query should encode check columns with a NULL marker")
+	}
+
 	// Verify query structure
 	if !contains(query, "SELECT") {
 		t.Error("Query should contain SELECT")
@@ -389,6 +441,24 @@ func TestBuildRecordQuery_ColumnEscaping(t *testing.T) {
 	}
 }
 
+// TestBuildRecordQuery_HashFunction tests that record checksums follow --hash-function
+func TestBuildRecordQuery_HashFunction(t *testing.T) {
+	ctx := &ChecksumContext{
+		Context:      types.NewBaseContext(),
+		UniqueKey:    types.NewColumnList([]string{"id"}),
+		CheckColumns: types.NewColumnList([]string{"name"}),
+	}
+	ctx.Context.HashFunction = types.SHA256Hash
+
+	query, err := (&TableDiffer{Context: ctx}).buildRecordQuery("test_db", "test_table", "1=1", nil)
+	if err != nil {
+		t.Fatalf("buildRecordQuery failed: %v", err)
+	}
+	if !contains(query, "SHA2(") || contains(query, "crc32") {
+		t.Errorf("record checksum should use sha2-256:\n%s", query)
+	}
+}
+
 // Helper function
 func contains(s, substr string) bool {
 	return len(s) > 0 && len(substr) > 0 &&
@@ -573,3 +643,14 @@ func TestBuildReplaceIntoStatement_NullValues(t *testing.T) {
 		t.Errorf("Statement should contain NULL without quotes, got: %s", result)
 	}
 }
+
+// --skip-target-sweep keeps the analysis to its key ranges: the context has no target database,
+// so a sweep of the target outside the source key range would fail
+func TestAnalyzeAndReportSkipTargetSweep(t *testing.T) {
+	baseCtx := types.NewBaseContext()
+	baseCtx.SkipTargetSweep = true
+	td := &TableDiffer{Context: NewChecksumContext(baseCtx, types.NewTableContext("srcdb", "t1", "tgtdb", "t1"))}
+	if err := td.analyzeAndReport(nil, true); err != nil {
+		t.Fatalf("analyzeAndReport: %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package checksum
+
+import (
+	"errors"
+	"sync"
+)
+
+// Parallel differential analysis (--diff-threads): the chunk boundaries of the analyzed key
+// ranges are still probed one after another on the source, but the chunks themselves are
+// row-diffed by a pool of workers. Every chunk is numbered in key order and its report is
+// merged into the table's report strictly in that order, so counts, the sample list and its
+// --max-sample-differences cap come out exactly as in a sequential analysis. At most twice
+// as many chunks as workers are in flight or waiting to be merged.
+
+// errDiffStopped stops the chunk walk once a worker has failed
+var errDiffStopped = errors.New("differential analysis stopped")
+
+// diffChunk is a chunk of the differential analysis and its position in key order
+type diffChunk struct {
+	seq   int
+	chunk KeyRange
+}
+
+// diffChunkResult is the report of a diffChunk
+type diffChunkResult struct {
+	seq    int
+	report *DifferenceReport
+	err    error
+}
+
+// diffThreads returns how many chunks the differential analysis compares in parallel. A
+// consistent snapshot pins a single connection per side, which cannot run queries in parallel.
+func (td *TableDiffer) diffThreads() int {
+	if td.Context.Context.DiffThreads < 2 || td.Context.Context.ConsistentSnapshot {
+		return 1
+	}
+	return td.Context.Context.DiffThreads
+}
+
+// analyzeKeyRanges row-diffs the key ranges chunk by chunk and merges the chunk reports into
+// report in key order
+func (td *TableDiffer) analyzeKeyRanges(ranges []KeyRange, report *DifferenceReport, maxSamples int) error {
+	threads := td.diffThreads()
+	if threads < 2 {
+		for _, keyRange := range ranges {
+			err := td.walkKeyRange(keyRange, func(chunk KeyRange) error {
+				chunkReport, err := td.analyzeChunkDifferences(chunk)
+				if err != nil {
+					return err
+				}
+				td.mergeChunkReport(report, chunkReport, maxSamples)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// The workers share the key comparer; read the key column types before they start
+	if _, err := td.keyComparer(); err != nil {
+		return err
+	}
+	chunks := make(chan diffChunk)
+	results := make(chan diffChunkResult)
+	window := make(chan struct{}, 2*threads)
+	stop := make(chan struct{})
+	walkErr := make(chan error, 1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range chunks {
+				chunkReport, err := td.analyzeChunkDifferences(c.chunk)
+				results <- diffChunkResult{seq: c.seq, report: chunkReport, err: err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Probe the chunk boundaries and hand the chunks to the workers
+	go func() {
+		defer close(chunks)
+		seq := 0
+		for _, keyRange := range ranges {
+			err := td.walkKeyRange(keyRange, func(chunk KeyRange) error {
+				select {
+				case window <- struct{}{}:
+				case <-stop:
+					return errDiffStopped
+				}
+				select {
+				case chunks <- diffChunk{seq: seq, chunk: chunk}:
+					seq++
+					return nil
+				case <-stop:
+					return errDiffStopped
+				}
+			})
+			if err != nil {
+				walkErr <- err
+				return
+			}
+		}
+		walkErr <- nil
+	}()
+
+	// Merge the chunk reports in key order
+	merger := td.newOrderedMerger(report, maxSamples)
+	var firstErr error
+	for result := range results {
+		if firstErr != nil {
+			continue
+		}
+		if result.err != nil {
+			firstErr = result.err
+			close(stop)
+			continue
+		}
+		for merged := merger.add(result.seq, result.report); merged > 0; merged-- {
+			<-window
+		}
+	}
+	if err := <-walkErr; err != nil && err != errDiffStopped && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
+
+// orderedMerger merges chunk reports into a report in key order, whatever order they arrive in
+type orderedMerger struct {
+	td         *TableDiffer
+	report     *DifferenceReport
+	maxSamples int
+	pending    map[int]*DifferenceReport
+	next       int
+}
+
+func (td *TableDiffer) newOrderedMerger(report *DifferenceReport, maxSamples int) *orderedMerger {
+	return &orderedMerger{td: td, report: report, maxSamples: maxSamples, pending: map[int]*DifferenceReport{}}
+}
+
+// add takes the report of chunk seq and merges every report now next in key order, returning how many
+func (merger *orderedMerger) add(seq int, chunkReport *DifferenceReport) (merged int) {
+	merger.pending[seq] = chunkReport
+	for chunkReport, ok := merger.pending[merger.next]; ok; chunkReport, ok = merger.pending[merger.next] {
+		merger.td.mergeChunkReport(merger.report, chunkReport, merger.maxSamples)
+		delete(merger.pending, merger.next)
+		merger.next++
+		merged++
+	}
+	return merged
+}
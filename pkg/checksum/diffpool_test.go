@@ -0,0 +1,56 @@
+package checksum
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+// Chunk reports arriving out of order are merged as if they came in key order
+func TestOrderedMergerKeepsKeyOrder(t *testing.T) {
+	td := &TableDiffer{Context: &ChecksumContext{Context: types.NewBaseContext()}}
+	chunkReport := func(ids ...int) *DifferenceReport {
+		report := &DifferenceReport{IdenticalRecords: 1}
+		for _, id := range ids {
+			report.SourceOnlyRecords++
+			report.SampleDifferences = append(report.SampleDifferences, RecordDifference{PrimaryKeyValues: map[string]interface{}{"id": id}, DifferenceType: "source_only"})
+		}
+		return report
+	}
+	reports := []*DifferenceReport{chunkReport(1, 2), chunkReport(), chunkReport(5, 6, 7), chunkReport(9)}
+
+	report := &DifferenceReport{}
+	merger := td.newOrderedMerger(report, 4)
+	for _, step := range []struct{ seq, merged int }{{2, 0}, {3, 0}, {0, 1}, {1, 3}} {
+		if merged := merger.add(step.seq, reports[step.seq]); merged != step.merged {
+			t.Errorf("add(%d) merged %d report(s), want %d", step.seq, merged, step.merged)
+		}
+	}
+
+	var ids []interface{}
+	for _, diff := range report.SampleDifferences {
+		ids = append(ids, diff.PrimaryKeyValues["id"])
+	}
+	if want := []interface{}{1, 2, 5, 6}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("samples = %v, want %v", ids, want)
+	}
+	if report.SourceOnlyRecords != 6 || report.IdenticalRecords != 4 {
+		t.Errorf("counts = %d source-only, %d identical; want 6, 4", report.SourceOnlyRecords, report.IdenticalRecords)
+	}
+}
+
+func TestDiffThreads(t *testing.T) {
+	td := &TableDiffer{Context: &ChecksumContext{Context: types.NewBaseContext()}}
+	if got := td.diffThreads(); got != 1 {
+		t.Errorf("default diffThreads = %d, want 1", got)
+	}
+	td.Context.Context.DiffThreads = 4
+	if got := td.diffThreads(); got != 4 {
+		t.Errorf("diffThreads = %d, want 4", got)
+	}
+	td.Context.Context.ConsistentSnapshot = true
+	if got := td.diffThreads(); got != 1 {
+		t.Errorf("--consistent-snapshot: diffThreads = %d, want 1", got)
+	}
+}
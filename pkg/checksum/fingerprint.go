@@ -0,0 +1,110 @@
+package checksum
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/builder"
+)
+
+// Column fingerprints (--column-fingerprints): a chunk checksum hashes the check columns together,
+// so a mismatched chunk does not tell which columns drifted. With the flag, every unique-key chunk
+// still mismatched after its retries is queried again on both sides, once, for one fingerprint per
+// check column (see builder.BuildRangeColumnFingerprintsPreparedQuery). The columns whose
+// fingerprints differ are logged with the chunk and counted per table, for the end-of-run summary
+// and for table_comparisons.different_columns. Rows that differ only in pairs cancelling out under
+// BIT_XOR leave the fingerprints alike; the chunk itself is still reported different.
+
+// queryColumnFingerprints returns the fingerprint of every check column over a unique-key range
+func (ctx *ChecksumContext) queryColumnFingerprints(db dbQuerier, databaseName, tableName, indexName string, keyRange KeyRange) ([]string, error) {
+	query, explodedArgs, err := builder.BuildRangeColumnFingerprintsPreparedQuery(
+		databaseName,
+		tableName,
+		ctx.partition,
+		ctx.CheckColumns,
+		ctx.UniqueKey,
+		keyRange.Min.AbstractValues(),
+		keyRange.Max.AbstractValues(),
+		keyRange.IncludeMin,
+		indexName,
+	)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(query, explodedArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanChecksumRows(rows)
+}
+
+// differentFingerprintColumns returns the columns whose source and target fingerprints differ, in
+// column order
+func differentFingerprintColumns(columns, sourceFingerprints, targetFingerprints []string) (different []string) {
+	for i, column := range columns {
+		if i >= len(sourceFingerprints) || i >= len(targetFingerprints) || sourceFingerprints[i] != targetFingerprints[i] {
+			different = append(different, column)
+		}
+	}
+	return different
+}
+
+// compareColumnFingerprints finds the check columns that differ in the mismatched chunk just
+// checked, logs them and counts them into the table's different columns. It only informs, so a
+// failing query is logged rather than failing the chunk.
+func (ctx *ChecksumContext) compareColumnFingerprints() {
+	keyRange := ctx.CurrentKeyRange()
+	sourceIndexName, targetIndexName, err := ctx.chunkIndexNames()
+	if err != nil {
+		ctx.Context.Log.Warnf("Column fingerprints of chunk %s of table %s.%s failed: %v", keyRange, ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, err)
+		return
+	}
+	sourceFingerprints, err := ctx.queryColumnFingerprints(ctx.sourceDB(), ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, sourceIndexName, keyRange)
+	if err != nil {
+		ctx.Context.Log.Warnf("Column fingerprints of chunk %s of table %s.%s failed: %v", keyRange, ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, err)
+		return
+	}
+	targetFingerprints, err := ctx.queryColumnFingerprints(ctx.targetDB(), ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, targetIndexName, keyRange)
+	if err != nil {
+		ctx.Context.Log.Warnf("Column fingerprints of chunk %s of table %s.%s failed: %v", keyRange, ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, err)
+		return
+	}
+	columns := differentFingerprintColumns(ctx.CheckColumns.Names(), sourceFingerprints, targetFingerprints)
+	if len(columns) == 0 {
+		ctx.Context.Log.Infof("Chunk %s of table pair %s.%s => %s.%s differs, but no column fingerprint does.", keyRange, ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName)
+		return
+	}
+	ctx.Context.Log.Errorf("Chunk %s of table pair %s.%s => %s.%s differs in column(s): %s", keyRange, ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, strings.Join(columns, ", "))
+	if ctx.PerTableContext.DifferentColumns == nil {
+		ctx.PerTableContext.DifferentColumns = map[string]int{}
+	}
+	for _, column := range columns {
+		ctx.PerTableContext.DifferentColumns[column]++
+	}
+}
+
+// FormatDifferentColumns renders the per-column counts of different chunks, the most frequent
+// column first: "price (3 chunks), note (1 chunk)"
+func FormatDifferentColumns(differentColumns map[string]int) string {
+	columns := make([]string, 0, len(differentColumns))
+	for column := range differentColumns {
+		columns = append(columns, column)
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		if differentColumns[columns[i]] != differentColumns[columns[j]] {
+			return differentColumns[columns[i]] > differentColumns[columns[j]]
+		}
+		return columns[i] < columns[j]
+	})
+	parts := make([]string, len(columns))
+	for i, column := range columns {
+		unit := "chunks"
+		if differentColumns[column] == 1 {
+			unit = "chunk"
+		}
+		parts[i] = fmt.Sprintf("%s (%d %s)", column, differentColumns[column], unit)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,93 @@
+package checksum
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+func TestDifferentFingerprintColumns(t *testing.T) {
+	columns := []string{"id", "name", "price"}
+	if got := differentFingerprintColumns(columns, []string{"1", "2", "3"}, []string{"1", "2", "3"}); got != nil {
+		t.Errorf("equal fingerprints: got %v", got)
+	}
+	if got, want := differentFingerprintColumns(columns, []string{"1", "2", "3"}, []string{"1", "9", "8"}), []string{"name", "price"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// A short result reports the columns it lacks as different
+	if got, want := differentFingerprintColumns(columns, []string{"1", "2", "3"}, []string{"1"}), []string{"name", "price"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFormatDifferentColumns(t *testing.T) {
+	got := FormatDifferentColumns(map[string]int{"note": 1, "price": 3, "amount": 3})
+	if want := "amount (3 chunks), price (3 chunks), note (1 chunk)"; got != want {
+		t.Errorf("FormatDifferentColumns = %q, want %q", got, want)
+	}
+	if got := FormatDifferentColumns(nil); got != "" {
+		t.Errorf("FormatDifferentColumns(nil) = %q, want empty", got)
+	}
+}
+
+func TestMergeRangeContextsDifferentColumns(t *testing.T) {
+	ctx := NewChecksumContext(types.NewBaseContext(), types.NewTableContext("srcdb", "t1", "tgtdb", "t1"))
+	keyRange := KeyRange{
+		Min:        types.ToColumnValues([]interface{}{int64(1)}),
+		Max:        types.ToColumnValues([]interface{}{int64(1000)}),
+		IncludeMin: true,
+	}
+	workers := []*ChecksumContext{ctx.NewRangeContext(keyRange), ctx.NewRangeContext(keyRange)}
+	workers[0].PerTableContext.DifferentColumns = map[string]int{"price": 2}
+	workers[1].PerTableContext.DifferentColumns = map[string]int{"price": 1, "note": 1}
+	if ctx.PerTableContext.DifferentColumns != nil {
+		t.Fatal("workers must not share the table's different columns")
+	}
+
+	ctx.MergeRangeContexts(workers)
+	if want := map[string]int{"price": 3, "note": 1}; !reflect.DeepEqual(ctx.PerTableContext.DifferentColumns, want) {
+		t.Errorf("DifferentColumns = %v, want %v", ctx.PerTableContext.DifferentColumns, want)
+	}
+}
+
+func TestRetryChunkChecksumFingerprintsOnlyFinalMismatch(t *testing.T) {
+	chunkRetryInterval = 0
+	defer func() { chunkRetryInterval = time.Second }()
+	ctx := NewChecksumContext(types.NewBaseContext(), types.NewTableContext("srcdb", "t1", "tgtdb", "t1"))
+	ctx.ChecksumIterationRangeMinValues = types.ToColumnValues([]interface{}{int64(1)})
+	ctx.ChecksumIterationRangeMaxValues = types.ToColumnValues([]interface{}{int64(100)})
+	fingerprint := func() {
+		if ctx.PerTableContext.DifferentColumns == nil {
+			ctx.PerTableContext.DifferentColumns = map[string]int{}
+		}
+		ctx.PerTableContext.DifferentColumns["price"]++
+	}
+
+	// Different once, e.g. through replication lag, then equal: no columns are recorded
+	queries := 0
+	isEqual, _, err := ctx.retryChunkChecksum(func() (bool, time.Duration, error) {
+		queries++
+		return queries > 1, 0, nil
+	}, fingerprint)
+	if !isEqual || err != nil || queries != 2 {
+		t.Fatalf("got (%v, %v) after %d queries, want equal after 2", isEqual, err, queries)
+	}
+	if ctx.PerTableContext.DifferentColumns != nil {
+		t.Errorf("DifferentColumns = %v, want none", ctx.PerTableContext.DifferentColumns)
+	}
+
+	// Different on every retry: the chunk is fingerprinted once
+	queries = 0
+	isEqual, _, err = ctx.retryChunkChecksum(func() (bool, time.Duration, error) {
+		queries++
+		return false, 0, nil
+	}, fingerprint)
+	if isEqual || err != nil || queries != int(ctx.Context.DefaultNumRetries) {
+		t.Fatalf("got (%v, %v) after %d queries, want different after %d", isEqual, err, queries, ctx.Context.DefaultNumRetries)
+	}
+	if want := map[string]int{"price": 1}; !reflect.DeepEqual(ctx.PerTableContext.DifferentColumns, want) {
+		t.Errorf("DifferentColumns = %v, want %v", ctx.PerTableContext.DifferentColumns, want)
+	}
+}
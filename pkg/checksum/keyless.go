@@ -0,0 +1,407 @@
+package checksum
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/builder"
+	"github.com/ChaosHour/go-data-checksum/pkg/tracking"
+)
+
+// Keyless tables: a table without a unique key free of NULLs cannot be chunked by key.
+// Its rows are spread over --keyless-buckets hash buckets of crc32(all check columns)
+// instead, and the row count, CRC32XOR and hash sum of every bucket are compared. The
+// differential analysis then compares each different bucket as a multiset of rows: the
+// distinct rows of both sides stream in the order of their encoded values and are
+// merge-joined, so a duplicated row shows up as a difference in its number of copies.
+
+// bucketComparison is the checksum of one hash bucket on both sides
+type bucketComparison struct {
+	bucket  int64
+	source  []string // row count, CRC32XOR and hash sum; nil when the side has no rows in the bucket
+	target  []string
+	isEqual bool
+}
+
+// bucketRecord is one distinct row of a hash bucket and its number of copies. Key is the encoding
+// of its check columns, which the rows of a bucket are grouped and ordered by.
+type bucketRecord struct {
+	Key      string
+	Checksum string
+	Values   map[string]interface{}
+	Count    int64
+}
+
+// bucketRecordSource yields the distinct rows of a hash bucket in bytewise Key order
+type bucketRecordSource interface {
+	next() (record bucketRecord, ok bool, err error)
+}
+
+// rowsBucketRecordSource streams the rows of a bucket records query (see builder.BuildBucketRecordsPreparedQuery)
+type rowsBucketRecordSource struct {
+	rows        *gosql.Rows
+	columnNames []string
+	scanDest    []interface{}
+	scanPtrs    []interface{}
+}
+
+func newRowsBucketRecordSource(rows *gosql.Rows, columnNames []string) *rowsBucketRecordSource {
+	source := &rowsBucketRecordSource{
+		rows:        rows,
+		columnNames: columnNames,
+		scanDest:    make([]interface{}, len(columnNames)+3), // record_values, record_checksum, ROW_COUNT + check columns
+		scanPtrs:    make([]interface{}, len(columnNames)+3),
+	}
+	for i := range source.scanDest {
+		source.scanPtrs[i] = &source.scanDest[i]
+	}
+	return source
+}
+
+func (source *rowsBucketRecordSource) next() (record bucketRecord, ok bool, err error) {
+	if !source.rows.Next() {
+		return bucketRecord{}, false, source.rows.Err()
+	}
+	if err := source.rows.Scan(source.scanPtrs...); err != nil {
+		return bucketRecord{}, false, err
+	}
+	if record.Count, err = strconv.ParseInt(formatPrimaryKeyValue(source.scanDest[2]), 10, 64); err != nil {
+		return bucketRecord{}, false, err
+	}
+	record.Key = formatPrimaryKeyValue(source.scanDest[0])
+	record.Checksum = formatPrimaryKeyValue(source.scanDest[1])
+	record.Values = make(map[string]interface{}, len(source.columnNames))
+	for i, name := range source.columnNames {
+		record.Values[name] = source.scanDest[i+3]
+	}
+	return record, true, nil
+}
+
+func (source *rowsBucketRecordSource) close() error {
+	return source.rows.Close()
+}
+
+// useHashBuckets switches the table to keyless mode, or reports why it cannot be chunked
+// when --keyless-buckets is 0
+func (ctx *ChecksumContext) useHashBuckets(reason string) error {
+	if ctx.Context.KeylessBuckets < 1 {
+		return fmt.Errorf("critical: table %s.%s %s", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, reason)
+	}
+	if !ctx.IsKeyless {
+		ctx.Context.Log.Infof("Table %s.%s %s; comparing it in %d hash buckets.", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, reason, ctx.Context.KeylessBuckets)
+	}
+	ctx.IsKeyless = true
+	return nil
+}
+
+// DifferentBuckets returns the hash buckets found different by the last bucket comparison, in bucket order
+func (ctx *ChecksumContext) DifferentBuckets() []int64 {
+	return ctx.differentBuckets
+}
+
+// bucketString renders a hash bucket for the chunk summary and tracking
+func (ctx *ChecksumContext) bucketString() string {
+	return fmt.Sprintf("bucket %d of %d", ctx.bucket, ctx.Context.KeylessBuckets)
+}
+
+// IterationBucketChecksum compares the bucket checksums of a keyless table pair, mirroring
+// IterationQueryChecksum for the table as a whole. With IsSuperSetAsEqual a different bucket
+// still passes when every source row has at least as many copies on the target.
+func (ctx *ChecksumContext) IterationBucketChecksum() (isEqual bool, duration time.Duration, err error) {
+	startTime := time.Now()
+	defer func() {
+		duration = time.Since(startTime)
+	}()
+
+	type bucketResult struct {
+		checksums map[int64][]string
+		err       error
+	}
+	sourceCh, targetCh := make(chan bucketResult, 1), make(chan bucketResult, 1)
+	go func() {
+		checksums, err := ctx.queryBucketChecksums(ctx.sourceDB(), ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName)
+		sourceCh <- bucketResult{checksums, err}
+	}()
+	go func() {
+		checksums, err := ctx.queryBucketChecksums(ctx.targetDB(), ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName)
+		targetCh <- bucketResult{checksums, err}
+	}()
+	sourceResult, targetResult := <-sourceCh, <-targetCh
+	if sourceResult.err != nil {
+		return false, duration, sourceResult.err
+	}
+	if targetResult.err != nil {
+		return false, duration, targetResult.err
+	}
+
+	ctx.buckets = compareBucketChecksums(sourceResult.checksums, targetResult.checksums)
+	isEqual = true
+	for i := range ctx.buckets {
+		if !ctx.buckets[i].isEqual && ctx.Context.IsSuperSetAsEqual {
+			if ctx.buckets[i].isEqual, err = ctx.isBucketSubset(ctx.buckets[i].bucket); err != nil {
+				return false, duration, err
+			}
+		}
+		isEqual = isEqual && ctx.buckets[i].isEqual
+	}
+	return isEqual, duration, nil
+}
+
+// TrackBuckets records every bucket of the last bucket comparison as a chunk and remembers the
+// different ones. The buckets share one query, so its duration is split evenly among them.
+func (ctx *ChecksumContext) TrackBuckets(duration time.Duration) {
+	ctx.differentBuckets = nil
+	for _, b := range ctx.buckets {
+		ctx.bucket = b.bucket
+		ctx.lastSourceChecksum = bucketChecksumSummary(b.source)
+		ctx.lastTargetChecksum = bucketChecksumSummary(b.target)
+		ctx.TrackChunk(ctx.NextChunkNumber(), b.isEqual, nil, duration/time.Duration(len(ctx.buckets)))
+	}
+}
+
+// bucketChecksumSummary condenses one side of a bucket comparison; a missing bucket has no rows
+func bucketChecksumSummary(checksum []string) tracking.ChunkChecksum {
+	if checksum == nil {
+		return tracking.ChunkChecksum{RowCount: 0}
+	}
+	return checksumSummary(checksum, 1)
+}
+
+// queryBucketChecksums runs the bucket checksum query and returns the checksum parts per bucket
+func (ctx *ChecksumContext) queryBucketChecksums(db dbQuerier, databaseName, tableName string) (checksums map[int64][]string, err error) {
+	query, err := builder.BuildBucketChecksumSQL(databaseName, tableName, ctx.CheckColumns, ctx.Context.KeylessBuckets, ctx.Context.HashFunction)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	results, err := scanChecksumRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	checksums = make(map[int64][]string)
+	for i := 0; i+len(columns) <= len(results); i += len(columns) {
+		bucket, err := strconv.ParseInt(results[i], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("critical: table %s.%s returned bucket %q: %v", databaseName, tableName, results[i], err)
+		}
+		checksums[bucket] = results[i+1 : i+len(columns)]
+	}
+	return checksums, nil
+}
+
+// compareBucketChecksums pairs up the buckets of both sides, in bucket order
+func compareBucketChecksums(source, target map[int64][]string) (buckets []bucketComparison) {
+	for bucket, checksum := range source {
+		buckets = append(buckets, bucketComparison{bucket: bucket, source: checksum, target: target[bucket]})
+	}
+	for bucket, checksum := range target {
+		if _, found := source[bucket]; !found {
+			buckets = append(buckets, bucketComparison{bucket: bucket, target: checksum})
+		}
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].bucket < buckets[j].bucket })
+	for i := range buckets {
+		buckets[i].isEqual = reflect.DeepEqual(buckets[i].source, buckets[i].target)
+	}
+	return buckets
+}
+
+// isBucketSubset reports whether every source row of the bucket has at least as many copies on the target
+func (ctx *ChecksumContext) isBucketSubset(bucket int64) (bool, error) {
+	sourceRecords, err := ctx.queryBucketRecords(ctx.sourceDB(), ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, bucket)
+	if err != nil {
+		return false, err
+	}
+	defer sourceRecords.close()
+	targetRecords, err := ctx.queryBucketRecords(ctx.targetDB(), ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, bucket)
+	if err != nil {
+		return false, err
+	}
+	defer targetRecords.close()
+	return isBucketRecordSubset(sourceRecords, targetRecords)
+}
+
+// isBucketRecordSubset reports whether every source row has at least as many copies on the target,
+// walking both sides in Key order
+func isBucketRecordSubset(source, target bucketRecordSource) (bool, error) {
+	targetRecord, targetOK, err := target.next()
+	if err != nil {
+		return false, err
+	}
+	for {
+		sourceRecord, sourceOK, err := source.next()
+		if err != nil || !sourceOK {
+			return err == nil, err
+		}
+		for targetOK && targetRecord.Key < sourceRecord.Key {
+			if targetRecord, targetOK, err = target.next(); err != nil {
+				return false, err
+			}
+		}
+		if !targetOK || targetRecord.Key != sourceRecord.Key || targetRecord.Count < sourceRecord.Count {
+			return false, nil
+		}
+	}
+}
+
+// queryBucketRecords streams the distinct rows of a hash bucket in Key order
+func (ctx *ChecksumContext) queryBucketRecords(db dbQuerier, databaseName, tableName string, bucket int64) (*rowsBucketRecordSource, error) {
+	query, err := builder.BuildBucketRecordsPreparedQuery(databaseName, tableName, ctx.CheckColumns, ctx.Context.KeylessBuckets, ctx.Context.HashFunction)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(query, bucket)
+	if err != nil {
+		return nil, err
+	}
+	return newRowsBucketRecordSource(rows, ctx.CheckColumns.Names()), nil
+}
+
+// AnalyzeAndReportBucketDifferences performs the differential analysis of a table without a usable
+// unique key. Every different hash bucket is compared as a multiset of rows; the buckets are
+// compared first when the checksum loop has not done so, e.g. after a row count mismatch.
+func (td *TableDiffer) AnalyzeAndReportBucketDifferences() error {
+	ctx := td.Context
+
+	buckets := ctx.DifferentBuckets()
+	if len(buckets) == 0 {
+		if _, _, err := ctx.IterationBucketChecksum(); err != nil {
+			return err
+		}
+		for _, b := range ctx.buckets {
+			if !b.isEqual {
+				buckets = append(buckets, b.bucket)
+			}
+		}
+	}
+
+	ctx.Context.Log.Infof("Starting differential analysis of %d hash bucket(s) for table pair: %s.%s => %s.%s",
+		len(buckets),
+		ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName,
+		ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName)
+
+	report := &DifferenceReport{
+		SampleDifferences: make([]RecordDifference, 0),
+	}
+	for _, bucket := range buckets {
+		if err := ctx.Throttle(); err != nil {
+			return err
+		}
+		bucketReport, err := td.analyzeBucketDifferences(bucket)
+		if err != nil {
+			return err
+		}
+		td.mergeChunkReport(report, bucketReport, ctx.Context.MaxSampleDifferences)
+	}
+
+	td.reportResults(report)
+	ctx.TrackDifferenceDetails(report.SampleDifferences)
+	if ctx.Context.GenerateSyncSQL {
+		ctx.Context.Log.Warnf("Sync SQL needs a unique key; none is generated for table %s.%s.", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName)
+	}
+	return nil
+}
+
+// analyzeBucketDifferences compares the rows of one hash bucket on both sides
+func (td *TableDiffer) analyzeBucketDifferences(bucket int64) (*DifferenceReport, error) {
+	ctx := td.Context
+
+	sourceRecords, err := ctx.queryBucketRecords(ctx.sourceDB(), ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get source records: %v", err)
+	}
+	defer sourceRecords.close()
+	targetRecords, err := ctx.queryBucketRecords(ctx.targetDB(), ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get target records: %v", err)
+	}
+	defer targetRecords.close()
+	return td.compareBucketRecords(sourceRecords, targetRecords)
+}
+
+// compareBucketRecords merge-joins the rows of a bucket as multisets, in Key order. Copies present on
+// both sides are identical; the surplus copies of a row count as source-only or target-only records.
+func (td *TableDiffer) compareBucketRecords(source, target bucketRecordSource) (*DifferenceReport, error) {
+	report := &DifferenceReport{
+		SampleDifferences: make([]RecordDifference, 0),
+	}
+	addSample := func(record bucketRecord, sourceCount, targetCount int64) {
+		if len(report.SampleDifferences) >= td.Context.Context.MaxSampleDifferences {
+			return
+		}
+		difference := RecordDifference{
+			PrimaryKeyValues: record.Values,
+			DifferenceType:   "count_mismatch",
+			SourceCount:      sourceCount,
+			TargetCount:      targetCount,
+		}
+		if sourceCount > 0 {
+			difference.SourceChecksum = record.Checksum
+		}
+		if targetCount > 0 {
+			difference.TargetChecksum = record.Checksum
+		}
+		switch {
+		case targetCount == 0:
+			difference.DifferenceType = "source_only"
+		case sourceCount == 0:
+			difference.DifferenceType = "target_only"
+		}
+		report.SampleDifferences = append(report.SampleDifferences, difference)
+	}
+
+	sourceRecord, sourceOK, err := source.next()
+	if err != nil {
+		return nil, err
+	}
+	targetRecord, targetOK, err := target.next()
+	if err != nil {
+		return nil, err
+	}
+	for sourceOK || targetOK {
+		advanceSource, advanceTarget := true, true
+		switch {
+		case !targetOK || (sourceOK && sourceRecord.Key < targetRecord.Key):
+			report.SourceOnlyRecords += sourceRecord.Count
+			addSample(sourceRecord, sourceRecord.Count, 0)
+			advanceTarget = false
+		case !sourceOK || targetRecord.Key < sourceRecord.Key:
+			report.TargetOnlyRecords += targetRecord.Count
+			addSample(targetRecord, 0, targetRecord.Count)
+			advanceSource = false
+		case sourceRecord.Count > targetRecord.Count:
+			report.IdenticalRecords += targetRecord.Count
+			report.SourceOnlyRecords += sourceRecord.Count - targetRecord.Count
+			addSample(sourceRecord, sourceRecord.Count, targetRecord.Count)
+		case sourceRecord.Count < targetRecord.Count:
+			report.IdenticalRecords += sourceRecord.Count
+			report.TargetOnlyRecords += targetRecord.Count - sourceRecord.Count
+			addSample(targetRecord, sourceRecord.Count, targetRecord.Count)
+		default:
+			report.IdenticalRecords += sourceRecord.Count
+		}
+		if advanceSource {
+			if sourceRecord, sourceOK, err = source.next(); err != nil {
+				return nil, err
+			}
+		}
+		if advanceTarget {
+			if targetRecord, targetOK, err = target.next(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return report, nil
+}
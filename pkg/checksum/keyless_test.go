@@ -0,0 +1,148 @@
+package checksum
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+func TestCompareBucketChecksums(t *testing.T) {
+	source := map[int64][]string{
+		0: {"2", "a1", "1f"},
+		3: {"1", "b2", "2e"},
+		5: {"4", "c3", "3d"},
+	}
+	target := map[int64][]string{
+		0: {"2", "a1", "1f"},
+		// A duplicated row cancels out of CRC32XOR but not out of the count and sum
+		3: {"3", "b2", "5c"},
+		7: {"1", "d4", "4c"},
+	}
+	buckets := compareBucketChecksums(source, target)
+
+	var order []int64
+	var different []int64
+	for _, b := range buckets {
+		order = append(order, b.bucket)
+		if !b.isEqual {
+			different = append(different, b.bucket)
+		}
+	}
+	if want := []int64{0, 3, 5, 7}; !reflect.DeepEqual(order, want) {
+		t.Errorf("buckets = %v, want %v", order, want)
+	}
+	if want := []int64{3, 5, 7}; !reflect.DeepEqual(different, want) {
+		t.Errorf("different buckets = %v, want %v", different, want)
+	}
+	if buckets[2].target != nil || buckets[3].source != nil {
+		t.Error("a bucket missing on one side should have no checksum there")
+	}
+}
+
+func TestTrackBucketsRecordsDifferentBuckets(t *testing.T) {
+	ctx := newTrackingTestContext()
+	ctx.IsKeyless = true
+	ctx.buckets = []bucketComparison{
+		{bucket: 1, source: []string{"1", "aa", "aa"}, target: []string{"1", "aa", "aa"}, isEqual: true},
+		{bucket: 4, source: []string{"2", "0", "154"}, isEqual: false},
+	}
+	ctx.TrackBuckets(0)
+
+	if got := ctx.DifferentBuckets(); !reflect.DeepEqual(got, []int64{4}) {
+		t.Errorf("DifferentBuckets() = %v, want [4]", got)
+	}
+	if got := ctx.PerTableContext.DifferentChunkRanges; !reflect.DeepEqual(got, []string{"bucket 4 of 64"}) {
+		t.Errorf("DifferentChunkRanges = %v", got)
+	}
+	if ctx.GetIteration() != 2 {
+		t.Errorf("each bucket should take a chunk number, got %d", ctx.GetIteration())
+	}
+}
+
+func TestUseHashBuckets(t *testing.T) {
+	ctx := newTrackingTestContext()
+	if err := ctx.useHashBuckets("has no unique key"); err != nil || !ctx.IsKeyless {
+		t.Errorf("keyless fallback should be taken, got err=%v keyless=%t", err, ctx.IsKeyless)
+	}
+
+	ctx = newTrackingTestContext()
+	ctx.Context.KeylessBuckets = 0
+	if err := ctx.useHashBuckets("has no unique key"); err == nil || ctx.IsKeyless {
+		t.Error("--keyless-buckets=0 should fail tables without a usable unique key")
+	}
+}
+
+// bucketRecordList is a bucketRecordSource over records given in Key order
+type bucketRecordList []bucketRecord
+
+func (records *bucketRecordList) next() (bucketRecord, bool, error) {
+	if len(*records) == 0 {
+		return bucketRecord{}, false, nil
+	}
+	record := (*records)[0]
+	*records = (*records)[1:]
+	return record, true, nil
+}
+
+// bucketRow is a distinct row of a bucket whose msg column is also its key
+func bucketRow(msg, checksum string, count int64) bucketRecord {
+	return bucketRecord{Key: msg, Checksum: checksum, Values: map[string]interface{}{"msg": msg}, Count: count}
+}
+
+func TestCompareBucketRecords(t *testing.T) {
+	baseContext := types.NewBaseContext()
+	td := &TableDiffer{Context: &ChecksumContext{Context: baseContext}}
+
+	source := bucketRecordList{bucketRow("a", "h1", 2), bucketRow("b", "h2", 1), bucketRow("c", "h3", 1)}
+	// d hashes like c: rows are matched by their values, not their hashes
+	target := bucketRecordList{bucketRow("a", "h1", 1), bucketRow("b", "h2", 1), bucketRow("d", "h3", 3)}
+	report, err := td.compareBucketRecords(&source, &target)
+	if err != nil {
+		t.Fatalf("compareBucketRecords: %v", err)
+	}
+
+	if report.IdenticalRecords != 2 || report.SourceOnlyRecords != 2 || report.TargetOnlyRecords != 3 || report.ModifiedRecords != 0 {
+		t.Errorf("report = identical %d, source-only %d, target-only %d, modified %d; want 2, 2, 3, 0",
+			report.IdenticalRecords, report.SourceOnlyRecords, report.TargetOnlyRecords, report.ModifiedRecords)
+	}
+	var order []string
+	samples := map[string]RecordDifference{}
+	for _, d := range report.SampleDifferences {
+		order = append(order, d.PrimaryKeyValues["msg"].(string))
+		samples[d.PrimaryKeyValues["msg"].(string)] = d
+	}
+	if want := []string{"a", "c", "d"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("samples = %v, want %v in row order", order, want)
+	}
+	if d := samples["a"]; d.DifferenceType != "count_mismatch" || d.SourceCount != 2 || d.TargetCount != 1 {
+		t.Errorf("row appearing 2x on source, 1x on target: %+v", d)
+	}
+	if d := samples["c"]; d.DifferenceType != "source_only" || d.SourceChecksum != "h3" || d.TargetChecksum != "" {
+		t.Errorf("row missing on target: %+v", d)
+	}
+	if d := samples["d"]; d.DifferenceType != "target_only" || d.TargetCount != 3 || d.SourceChecksum != "" {
+		t.Errorf("row missing on source: %+v", d)
+	}
+}
+
+// A bucket is a subset when every source row has as many copies on the target, matched by value
+func TestIsBucketRecordSubset(t *testing.T) {
+	cases := []struct {
+		source, target bucketRecordList
+		want           bool
+	}{
+		{bucketRecordList{bucketRow("a", "h1", 1), bucketRow("c", "h3", 2)}, bucketRecordList{bucketRow("a", "h1", 1), bucketRow("b", "h2", 1), bucketRow("c", "h3", 3)}, true},
+		{bucketRecordList{bucketRow("a", "h1", 2)}, bucketRecordList{bucketRow("a", "h1", 1)}, false},
+		// A target row with the same hash is still another row
+		{bucketRecordList{bucketRow("a", "h1", 1)}, bucketRecordList{bucketRow("b", "h1", 1)}, false},
+		{bucketRecordList{bucketRow("z", "h9", 1)}, bucketRecordList{bucketRow("a", "h1", 1)}, false},
+		{nil, bucketRecordList{bucketRow("a", "h1", 1)}, true},
+	}
+	for i, c := range cases {
+		got, err := isBucketRecordSubset(&c.source, &c.target)
+		if err != nil || got != c.want {
+			t.Errorf("case %d: isBucketRecordSubset = %t, %v; want %t", i, got, err, c.want)
+		}
+	}
+}
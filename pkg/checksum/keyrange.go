@@ -0,0 +1,102 @@
+package checksum
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+// KeyRange is a range of unique-key values on the source table: Min < key <= Max,
+// or Min <= key <= Max when IncludeMin is set, matching the chunk bounds of the checksum loop.
+type KeyRange struct {
+	Min        *types.ColumnValues
+	Max        *types.ColumnValues
+	IncludeMin bool
+}
+
+func (r KeyRange) String() string {
+	open := "("
+	if r.IncludeMin {
+		open = "["
+	}
+	return fmt.Sprintf("%s%s, %s]", open, r.Min, r.Max)
+}
+
+// TimeRange is a range of time column values: Min <= value < Max, or Min <= value <= Max when
+// IncludeMax is set, matching the chunk bounds of the time-column loop.
+type TimeRange struct {
+	Min        time.Time
+	Max        time.Time
+	IncludeMax bool
+}
+
+func (r TimeRange) String() string {
+	return timeRangeString(r.Min, r.Max, r.IncludeMax)
+}
+
+// CurrentKeyRange returns the key range of the chunk being checked
+func (ctx *ChecksumContext) CurrentKeyRange() KeyRange {
+	return KeyRange{
+		Min:        ctx.ChecksumIterationRangeMinValues,
+		Max:        ctx.ChecksumIterationRangeMaxValues,
+		IncludeMin: ctx.iterationIncludesMin,
+	}
+}
+
+// KeyRangeThroughEnd returns the key range from the start of r through the end of the context's key
+// range: r and every chunk after it, which a chunk loop stopping at r never checked
+func (ctx *ChecksumContext) KeyRangeThroughEnd(r KeyRange) KeyRange {
+	return KeyRange{Min: r.Min, Max: ctx.UniqueKeyRangeMaxValues, IncludeMin: r.IncludeMin}
+}
+
+// DifferentKeyRanges returns the key ranges of the chunks found different so far, in check order
+func (ctx *ChecksumContext) DifferentKeyRanges() []KeyRange {
+	return ctx.differentKeyRanges
+}
+
+// CurrentTimeRange returns the time range of the time chunk being checked
+func (ctx *ChecksumContext) CurrentTimeRange() TimeRange {
+	return TimeRange{
+		Min:        ctx.TimeIterationRangeMinValue,
+		Max:        ctx.TimeIterationRangeMaxValue,
+		IncludeMax: ctx.isFinalTimeChunk(),
+	}
+}
+
+// DifferentTimeRanges returns the time ranges of the time chunks found different so far, in check order
+func (ctx *ChecksumContext) DifferentTimeRanges() []TimeRange {
+	return ctx.differentTimeRanges
+}
+
+// recordDifferentChunk remembers the range of a chunk whose checksum differs, both for the
+// per-table summary and for the differential analysis after the chunk loop.
+func (ctx *ChecksumContext) recordDifferentChunk() {
+	if ctx.IsKeyless {
+		ctx.differentBuckets = append(ctx.differentBuckets, ctx.bucket)
+		ctx.PerTableContext.DifferentChunkRanges = append(ctx.PerTableContext.DifferentChunkRanges, ctx.bucketString())
+		return
+	}
+	if ctx.TimeColumn != nil {
+		timeRange := ctx.CurrentTimeRange()
+		ctx.differentTimeRanges = append(ctx.differentTimeRanges, timeRange)
+		ctx.PerTableContext.DifferentChunkRanges = append(ctx.PerTableContext.DifferentChunkRanges, timeRange.String())
+		return
+	}
+	keyRange := ctx.CurrentKeyRange()
+	ctx.differentKeyRanges = append(ctx.differentKeyRanges, keyRange)
+	chunkRange := keyRange.String()
+	if ctx.partition != "" {
+		chunkRange = fmt.Sprintf("partition %s: %s", ctx.partition, chunkRange)
+	}
+	ctx.PerTableContext.DifferentChunkRanges = append(ctx.PerTableContext.DifferentChunkRanges, chunkRange)
+}
+
+// timeRangeString renders a time chunk: [min, max), or [min, max] for the final chunk
+func timeRangeString(rangeMin, rangeMax time.Time, isFinal bool) string {
+	closing := ")"
+	if isFinal {
+		closing = "]"
+	}
+	return fmt.Sprintf("[%s, %s%s", rangeMin.Format("2006-01-02 15:04:05"), rangeMax.Format("2006-01-02 15:04:05"), closing)
+}
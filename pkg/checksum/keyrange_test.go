@@ -0,0 +1,108 @@
+package checksum
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+func TestKeyRangeString(t *testing.T) {
+	r := KeyRange{
+		Min: types.ToColumnValues([]interface{}{int64(1), "a"}),
+		Max: types.ToColumnValues([]interface{}{int64(9), "z"}),
+	}
+	if got := r.String(); got != "(1,a, 9,z]" {
+		t.Errorf("exclusive range rendered as %q", got)
+	}
+	r.IncludeMin = true
+	if got := r.String(); got != "[1,a, 9,z]" {
+		t.Errorf("inclusive range rendered as %q", got)
+	}
+}
+
+func TestCurrentKeyRangeIncludesMinOnFirstChunk(t *testing.T) {
+	ctx := NewChecksumContext(types.NewBaseContext(), types.NewTableContext("srcdb", "t1", "tgtdb", "t1"))
+	if !ctx.nextChunkIncludesMin() {
+		t.Fatal("the first chunk of a table must include its minimum")
+	}
+	ctx.iterationIncludesMin = ctx.nextChunkIncludesMin()
+	ctx.ChecksumIterationRangeMinValues = types.ToColumnValues([]interface{}{int64(1)})
+	ctx.ChecksumIterationRangeMaxValues = types.ToColumnValues([]interface{}{int64(1000)})
+
+	r := ctx.CurrentKeyRange()
+	if !r.IncludeMin || r.Min != ctx.ChecksumIterationRangeMinValues || r.Max != ctx.ChecksumIterationRangeMaxValues {
+		t.Errorf("first chunk range = %s (IncludeMin=%v), want [1, 1000]", r, r.IncludeMin)
+	}
+
+	if ctx.nextChunkIncludesMin() {
+		t.Error("later chunks start after the previous chunk's end and must not include their minimum")
+	}
+
+	worker := ctx.NewRangeContext(KeyRange{Min: ctx.ChecksumIterationRangeMaxValues, Max: types.ToColumnValues([]interface{}{int64(2000)})})
+	if worker.nextChunkIncludesMin() {
+		t.Error("a key range starting after its minimum must not include it in its first chunk")
+	}
+}
+
+func TestTrackChunkRecordsDifferentChunks(t *testing.T) {
+	ctx := NewChecksumContext(types.NewBaseContext(), types.NewTableContext("srcdb", "t1", "tgtdb", "t1"))
+	chunks := [][2]int64{{1, 100}, {100, 200}, {200, 300}}
+	for i, chunk := range chunks {
+		ctx.ChecksumIterationRangeMinValues = types.ToColumnValues([]interface{}{chunk[0]})
+		ctx.ChecksumIterationRangeMaxValues = types.ToColumnValues([]interface{}{chunk[1]})
+		ctx.iterationIncludesMin = i == 0
+		ctx.TrackChunk(i, i == 1, nil, time.Millisecond)
+	}
+	// An errored chunk is not a different one
+	ctx.TrackChunk(3, false, errors.New("timeout"), time.Millisecond)
+
+	want := []string{"[1, 100]", "(200, 300]"}
+	if !reflect.DeepEqual(ctx.PerTableContext.DifferentChunkRanges, want) {
+		t.Errorf("DifferentChunkRanges = %v, want %v", ctx.PerTableContext.DifferentChunkRanges, want)
+	}
+	if ranges := ctx.DifferentKeyRanges(); len(ranges) != 2 || !ranges[0].IncludeMin || ranges[1].IncludeMin {
+		t.Errorf("DifferentKeyRanges = %v", ranges)
+	}
+}
+
+// Different time chunks keep their time ranges for the differential analysis
+func TestTrackChunkRecordsDifferentTimeChunks(t *testing.T) {
+	baseContext := types.NewBaseContext()
+	begin := time.Date(2026, 7, 4, 0, 0, 0, 0, time.Local)
+	baseContext.SpecifiedDatetimeRangeEnd = begin.Add(time.Hour)
+	ctx := NewChecksumContext(baseContext, types.NewTableContext("srcdb", "t1", "tgtdb", "t1"))
+	ctx.TimeColumn = types.ParseColumnList("updated_at")
+	for i := 0; i < 2; i++ {
+		ctx.TimeIterationRangeMinValue = begin.Add(time.Duration(i) * 30 * time.Minute)
+		ctx.TimeIterationRangeMaxValue = ctx.TimeIterationRangeMinValue.Add(30 * time.Minute)
+		ctx.TrackChunk(i, i == 0, nil, time.Millisecond)
+	}
+	ranges := ctx.DifferentTimeRanges()
+	if len(ranges) != 1 || !ranges[0].IncludeMax || ranges[0].String() != "[2026-07-04 00:30:00, 2026-07-04 01:00:00]" {
+		t.Errorf("DifferentTimeRanges = %v", ranges)
+	}
+}
+
+// A loop stopping at a different chunk leaves the rest of the key range unchecked
+func TestKeyRangeThroughEnd(t *testing.T) {
+	ctx := NewChecksumContext(types.NewBaseContext(), types.NewTableContext("srcdb", "t1", "tgtdb", "t1"))
+	ctx.UniqueKeyRangeMaxValues = types.ToColumnValues([]interface{}{int64(1000)})
+	stop := KeyRange{Min: types.ToColumnValues([]interface{}{int64(100)}), Max: types.ToColumnValues([]interface{}{int64(200)})}
+	if got := ctx.KeyRangeThroughEnd(stop).String(); got != "(100, 1000]" {
+		t.Errorf("KeyRangeThroughEnd = %s, want (100, 1000]", got)
+	}
+}
+
+func TestTimeRangeString(t *testing.T) {
+	begin := time.Date(2026, 7, 4, 0, 0, 0, 0, time.Local)
+	end := begin.Add(30 * time.Minute)
+	if got := timeRangeString(begin, end, false); got != "[2026-07-04 00:00:00, 2026-07-04 00:30:00)" {
+		t.Errorf("timeRangeString = %q", got)
+	}
+	if got := timeRangeString(begin, end, true); got != "[2026-07-04 00:00:00, 2026-07-04 00:30:00]" {
+		t.Errorf("final timeRangeString = %q", got)
+	}
+}
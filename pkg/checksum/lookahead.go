@@ -0,0 +1,94 @@
+package checksum
+
+import (
+	"fmt"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+// Boundary lookahead (--chunk-lookahead): instead of probing the end of chunk N+1 on the source
+// only after chunk N's checksums came back, a planner goroutine walks the key range with the same
+// probes and queues up to --chunk-lookahead chunk boundaries ahead of the chunk loop, so the probe
+// round trip overlaps the checksum queries. CalculateNextIterationRangeEndValues takes the queued
+// boundaries in order, so chunk numbering, tracking and retries work as without the lookahead.
+// A chunk size retuned by --chunk-time applies once the already queued boundaries are used up.
+
+// chunkBoundary is one chunk planned by the lookahead; found is false past the end of the key range
+type chunkBoundary struct {
+	min         *types.ColumnValues
+	max         *types.ColumnValues
+	includesMin bool
+	chunkSize   int64
+	found       bool
+	err         error
+}
+
+// boundaryLookahead is a running planner: queue is closed once it has exited
+type boundaryLookahead struct {
+	queue chan chunkBoundary
+	stop  chan struct{}
+}
+
+// StartBoundaryLookahead starts planning up to depth chunks ahead of the chunk loop. It is a no-op
+// for a depth below 1, when the chunks are planned or sampled already, and under a consistent snapshot, whose
+// pinned source connection cannot run the probes alongside the checksum queries.
+func (ctx *ChecksumContext) StartBoundaryLookahead(depth int) {
+	if depth < 1 || ctx.lookahead != nil || ctx.chunkPlan != nil || ctx.sample != nil || ctx.sourceSnapshot != nil {
+		return
+	}
+	lookahead := &boundaryLookahead{
+		queue: make(chan chunkBoundary, depth),
+		stop:  make(chan struct{}),
+	}
+	ctx.lookahead = lookahead
+
+	start, includeStart := ctx.nextChunkMin(), ctx.nextChunkIncludesMin()
+	go func() {
+		defer close(lookahead.queue)
+		for planned := 1; ; planned++ {
+			boundary := chunkBoundary{min: start, includesMin: includeStart, chunkSize: ctx.GetChunkSize()}
+			boundary.max, boundary.found, boundary.err = ctx.probeChunkEnd(start, includeStart, boundary.chunkSize, fmt.Sprintf("lookahead:%d", planned))
+			select {
+			case lookahead.queue <- boundary:
+			case <-lookahead.stop:
+				return
+			}
+			if !boundary.found || boundary.err != nil {
+				return
+			}
+			start, includeStart = boundary.max, false
+		}
+	}()
+}
+
+// StopBoundaryLookahead stops the planner and waits for its probe in flight to finish
+func (ctx *ChecksumContext) StopBoundaryLookahead() {
+	if ctx.lookahead == nil {
+		return
+	}
+	close(ctx.lookahead.stop)
+	for range ctx.lookahead.queue {
+	}
+	ctx.lookahead = nil
+}
+
+// nextLookaheadBoundary makes the next planned chunk the current one, like CalculateNextIterationRangeEndValues
+func (ctx *ChecksumContext) nextLookaheadBoundary() (hasFurtherRange bool, err error) {
+	boundary, ok := <-ctx.lookahead.queue
+	if !ok {
+		return false, nil
+	}
+	ctx.iterationIncludesMin = boundary.includesMin
+	ctx.ChecksumIterationRangeMinValues = boundary.min
+	ctx.iterationChunkSize = boundary.chunkSize
+	if boundary.err != nil {
+		return false, boundary.err
+	}
+	if !boundary.found {
+		ctx.Context.Log.Debugf("Debug: Iteration complete: no further range to iterate of source table: %s.%s", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName)
+		return false, nil
+	}
+	ctx.ChecksumIterationRangeMaxValues = boundary.max
+	ctx.rowsEstimated += boundary.chunkSize
+	return true, nil
+}
@@ -0,0 +1,54 @@
+package checksum
+
+import (
+	"testing"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+func TestLookaheadBoundariesBecomeChunks(t *testing.T) {
+	ctx := NewChecksumContext(types.NewBaseContext(), types.NewTableContext("srcdb", "t1", "tgtdb", "t1"))
+	key := func(v int64) *types.ColumnValues { return types.ToColumnValues([]interface{}{v}) }
+
+	// A planner that has queued two chunks and the end of the key range, then exited
+	ctx.lookahead = &boundaryLookahead{queue: make(chan chunkBoundary, 3), stop: make(chan struct{})}
+	ctx.lookahead.queue <- chunkBoundary{min: key(1), max: key(1000), includesMin: true, chunkSize: 1000, found: true}
+	ctx.lookahead.queue <- chunkBoundary{min: key(1000), max: key(1800), chunkSize: 800, found: true}
+	ctx.lookahead.queue <- chunkBoundary{min: key(1800), chunkSize: 800}
+	close(ctx.lookahead.queue)
+
+	var ranges []string
+	for {
+		hasFurtherRange, err := ctx.CalculateNextIterationRangeEndValues()
+		if err != nil {
+			t.Fatalf("CalculateNextIterationRangeEndValues: %v", err)
+		}
+		if !hasFurtherRange {
+			break
+		}
+		ranges = append(ranges, ctx.CurrentKeyRange().String())
+	}
+	if len(ranges) != 2 || ranges[0] != "[1, 1000]" || ranges[1] != "(1000, 1800]" {
+		t.Errorf("chunks = %v, want [[1, 1000] (1000, 1800]]", ranges)
+	}
+	if got := ctx.EstimatedRowsChecked(); got != 1800 {
+		t.Errorf("EstimatedRowsChecked = %d, want 1800", got)
+	}
+	if hasFurtherRange, err := ctx.CalculateNextIterationRangeEndValues(); hasFurtherRange || err != nil {
+		t.Errorf("a finished lookahead should report no further range, got %v, %v", hasFurtherRange, err)
+	}
+
+	ctx.StopBoundaryLookahead()
+	if ctx.lookahead != nil {
+		t.Error("StopBoundaryLookahead should clear the lookahead")
+	}
+}
+
+func TestStartBoundaryLookaheadDisabled(t *testing.T) {
+	ctx := NewChecksumContext(types.NewBaseContext(), types.NewTableContext("srcdb", "t1", "tgtdb", "t1"))
+	ctx.StartBoundaryLookahead(0)
+	if ctx.lookahead != nil {
+		t.Error("a lookahead of 0 should not start the planner")
+	}
+	ctx.StopBoundaryLookahead()
+}
@@ -0,0 +1,530 @@
+package checksum
+
+import (
+	"bytes"
+	gosql "database/sql"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Streaming differential analysis: the source and target record queries of a chunk are both
+// ordered by the unique key, so their rows are merge-joined as they stream in, holding a single
+// row per side and emitting differences in key order. Keys are compared with the MySQL types of
+// their columns: numerically for integer, decimal and floating-point columns, by position for
+// ENUM columns, chronologically for temporal columns, bytewise for binary strings, and in the
+// column's collation for other strings. The record queries select the collation weights
+// (WEIGHT_STRING) of the non-binary string key columns, which compare bytewise in ORDER BY's
+// order, trailing spaces padded with the space's weight unless the collation is NO PAD. Keys read
+// without weights, like those of the column-level differences, have differing non-binary strings
+// compared by the source server (STRCMP); identical keys never need it.
+// Source and target key columns only need to sort alike: numbers of any width or signedness,
+// temporal values, binary strings, or strings in the same collation.
+
+// recordSource yields records in unique-key order
+type recordSource interface {
+	next() (record RecordData, ok bool, err error)
+}
+
+// rowsRecordSource streams the records of a record query (see buildRecordQuery)
+type rowsRecordSource struct {
+	rows       *gosql.Rows
+	keyColumns []string
+	// weighted marks the key columns whose collation weights follow the checksum
+	weighted []bool
+	scanDest []interface{}
+	scanPtrs []interface{}
+}
+
+func newRowsRecordSource(rows *gosql.Rows, keyColumns []string, weighted []bool) *rowsRecordSource {
+	columns := len(keyColumns) + 1 // key columns + checksum
+	for _, w := range weighted {
+		if w {
+			columns++
+		}
+	}
+	source := &rowsRecordSource{
+		rows:       rows,
+		keyColumns: keyColumns,
+		weighted:   weighted,
+		scanDest:   make([]interface{}, columns),
+		scanPtrs:   make([]interface{}, columns),
+	}
+	for i := range source.scanDest {
+		source.scanPtrs[i] = &source.scanDest[i]
+	}
+	return source
+}
+
+func (source *rowsRecordSource) next() (record RecordData, ok bool, err error) {
+	if !source.rows.Next() {
+		return RecordData{}, false, source.rows.Err()
+	}
+	if err := source.rows.Scan(source.scanPtrs...); err != nil {
+		return RecordData{}, false, err
+	}
+	record.KeyValues = make([]interface{}, len(source.keyColumns))
+	record.PrimaryKeyValues = make(map[string]interface{}, len(source.keyColumns))
+	for i, name := range source.keyColumns {
+		record.KeyValues[i] = source.scanDest[i]
+		record.PrimaryKeyValues[name] = source.scanDest[i]
+	}
+	record.Checksum = formatPrimaryKeyValue(source.scanDest[len(source.keyColumns)])
+	weight := len(source.keyColumns) + 1
+	for i, w := range source.weighted {
+		if !w {
+			continue
+		}
+		if record.KeyWeights == nil {
+			record.KeyWeights = make([][]byte, len(source.keyColumns))
+		}
+		record.KeyWeights[i], _ = source.scanDest[weight].([]byte)
+		weight++
+	}
+	return record, true, nil
+}
+
+func (source *rowsRecordSource) close() error {
+	return source.rows.Close()
+}
+
+// keyColumn is a unique key column as information_schema.COLUMNS describes it
+type keyColumn struct {
+	name       string
+	dataType   string
+	columnType string
+	charset    string
+	collation  string
+	enumValues []string
+	// padWeight is the weight of a space, which pads shorter strings in a PAD SPACE collation
+	padWeight []byte
+}
+
+// keyComparer compares unique-key values in the order MySQL sorts them
+type keyComparer struct {
+	columns []keyColumn
+	// db compares differing non-binary strings in their collation
+	db dbQuerier
+}
+
+// readKeyColumns returns the given columns of a table, in the given order
+func readKeyColumns(db dbQuerier, databaseName, tableName string, names []string) (columns []keyColumn, err error) {
+	rows, err := db.Query(`
+    select COLUMN_NAME, DATA_TYPE, COLUMN_TYPE, IFNULL(CHARACTER_SET_NAME, ''), IFNULL(COLLATION_NAME, '')
+      from information_schema.COLUMNS
+     where TABLE_SCHEMA = ? and TABLE_NAME = ?
+  `, databaseName, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("critical: table %s.%s get key column types failed: %v", databaseName, tableName, err)
+	}
+	defer rows.Close()
+	found := map[string]keyColumn{}
+	for rows.Next() {
+		var column keyColumn
+		if err := rows.Scan(&column.name, &column.dataType, &column.columnType, &column.charset, &column.collation); err != nil {
+			return nil, err
+		}
+		column.dataType = strings.ToLower(column.dataType)
+		if column.dataType == "enum" {
+			column.enumValues = parseEnumValues(column.columnType)
+		}
+		found[strings.ToLower(column.name)] = column
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		column, ok := found[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("critical: table %s.%s has no column %s", databaseName, tableName, name)
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// parseEnumValues returns the values of an enum('a','b') column type in their order
+func parseEnumValues(columnType string) (values []string) {
+	start, end := strings.Index(columnType, "("), strings.LastIndex(columnType, ")")
+	if start < 0 || end < start {
+		return nil
+	}
+	var value strings.Builder
+	inQuote := false
+	list := columnType[start+1 : end]
+	for i := 0; i < len(list); i++ {
+		c := list[i]
+		switch {
+		case c == '\'' && inQuote && i+1 < len(list) && list[i+1] == '\'':
+			value.WriteByte('\'')
+			i++
+		case c == '\'':
+			if inQuote {
+				values = append(values, value.String())
+				value.Reset()
+			}
+			inQuote = !inQuote
+		case inQuote:
+			value.WriteByte(c)
+		}
+	}
+	return values
+}
+
+// keyComparer returns the comparer of the table's unique-key values, reading the key
+// column types of both sides on first use
+func (td *TableDiffer) keyComparer() (*keyComparer, error) {
+	if td.keys != nil {
+		return td.keys, nil
+	}
+	ctx := td.Context
+	names := ctx.UniqueKey.Names()
+	sourceColumns, err := readKeyColumns(ctx.Context.SourceDB, ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, names)
+	if err != nil {
+		return nil, err
+	}
+	targetColumns, err := readKeyColumns(ctx.Context.TargetDB, ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, names)
+	if err != nil {
+		return nil, err
+	}
+	if err := sameKeyOrder(sourceColumns, targetColumns); err != nil {
+		return nil, err
+	}
+	for i, column := range sourceColumns {
+		if !column.weighted() || noPadCollation(column.collation) {
+			continue
+		}
+		query := fmt.Sprintf("select weight_string(convert(' ' using %s) collate %s)", column.charset, column.collation)
+		if err := ctx.Context.SourceDB.QueryRow(query).Scan(&sourceColumns[i].padWeight); err != nil {
+			return nil, fmt.Errorf("critical: read the space weight of collation %s failed: %v", column.collation, err)
+		}
+	}
+	td.keys = &keyComparer{columns: sourceColumns, db: ctx.Context.SourceDB}
+	return td.keys, nil
+}
+
+// sameKeyOrder checks that the source and target key columns sort alike, so both sides' rows
+// can be merged in one key order. Types only need the same comparison class: numbers of any
+// width or signedness, temporal values, binary strings, or strings in the same collation.
+func sameKeyOrder(sourceColumns, targetColumns []keyColumn) error {
+	for i, source := range sourceColumns {
+		target := targetColumns[i]
+		if keyComparisonClass(source) != keyComparisonClass(target) {
+			return fmt.Errorf("critical: key column %s is %s %s on the source and %s %s on the target, the rows cannot be merged in key order",
+				source.name, source.columnType, source.collation, target.columnType, target.collation)
+		}
+	}
+	return nil
+}
+
+// keyComparisonClass names how compareValue orders the values of a key column
+func keyComparisonClass(column keyColumn) string {
+	switch column.dataType {
+	case "tinyint", "smallint", "mediumint", "int", "bigint", "year", "decimal", "float", "double":
+		return "numeric"
+	case "enum":
+		// Enum values sort by their position in the definition
+		return "enum " + strings.Join(column.enumValues, ",")
+	case "date", "datetime", "timestamp":
+		return "temporal"
+	case "time":
+		return "time"
+	}
+	if column.charset == "" || column.collation == "binary" {
+		return "binary"
+	}
+	// MySQL 8.0.30 reports utf8 collations as utf8mb3, which sort the same
+	collation := strings.ToLower(column.collation)
+	if strings.HasPrefix(collation, "utf8_") {
+		collation = "utf8mb3_" + strings.TrimPrefix(collation, "utf8_")
+	}
+	return "string " + collation
+}
+
+// weighted reports whether the key column is a non-binary string, whose values sort by their
+// collation weights
+func (column keyColumn) weighted() bool {
+	return strings.HasPrefix(keyComparisonClass(column), "string ")
+}
+
+// noPadCollation reports whether trailing spaces count in the collation: the UCA 9.0.0 (_0900_)
+// collations of MySQL 8.0 and the _nopad_ ones of MariaDB; all others are PAD SPACE
+func noPadCollation(collation string) bool {
+	collation = strings.ToLower(collation)
+	return strings.Contains(collation, "_0900_") || strings.Contains(collation, "_nopad_")
+}
+
+// weightedColumns marks the key columns whose values compare by their collation weights
+func (comparer *keyComparer) weightedColumns() []bool {
+	weighted := make([]bool, len(comparer.columns))
+	for i, column := range comparer.columns {
+		weighted[i] = column.weighted()
+	}
+	return weighted
+}
+
+// compare returns -1, 0 or 1 as key a sorts before, with or after key b
+func (comparer *keyComparer) compare(a, b []interface{}) (int, error) {
+	return comparer.compareWeighted(a, b, nil, nil)
+}
+
+// compareWeighted is compare for keys with the collation weights of their string columns (see
+// RecordData.KeyWeights); columns that lack a weight on either side are compared by value
+func (comparer *keyComparer) compareWeighted(a, b []interface{}, aWeights, bWeights [][]byte) (int, error) {
+	for i, column := range comparer.columns {
+		var order int
+		var err error
+		if i < len(aWeights) && i < len(bWeights) && aWeights[i] != nil && bWeights[i] != nil {
+			order = compareWeights(aWeights[i], bWeights[i], column.padWeight)
+		} else {
+			order, err = comparer.compareValue(column, a[i], b[i])
+		}
+		if err != nil || order != 0 {
+			return order, err
+		}
+	}
+	return 0, nil
+}
+
+// compareWeights compares two collation weight strings bytewise, the shorter one padded with
+// padWeight when it is set, as a PAD SPACE collation compares a string with trailing spaces
+func compareWeights(a, b, padWeight []byte) int {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	if order := bytes.Compare(a[:n], b[:n]); order != 0 {
+		return order
+	}
+	if len(padWeight) == 0 {
+		return compareInts(len(a), len(b))
+	}
+	rest, sign := a[n:], 1
+	if len(b) > len(a) {
+		rest, sign = b[n:], -1
+	}
+	padding := bytes.Repeat(padWeight, len(rest)/len(padWeight)+1)[:len(rest)]
+	return sign * bytes.Compare(rest, padding)
+}
+
+// compareValue compares two values of a key column; NULL sorts first
+func (comparer *keyComparer) compareValue(column keyColumn, a, b interface{}) (int, error) {
+	switch {
+	case a == nil && b == nil:
+		return 0, nil
+	case a == nil:
+		return -1, nil
+	case b == nil:
+		return 1, nil
+	}
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			return compareTimes(ta, tb), nil
+		}
+	}
+	as, bs := keyValueBytes(a), keyValueBytes(b)
+	switch column.dataType {
+	case "tinyint", "smallint", "mediumint", "int", "bigint", "year", "decimal", "float", "double":
+		return compareNumbers(as, bs)
+	case "enum":
+		if order, ok := compareEnumValues(column.enumValues, string(as), string(bs)); ok {
+			return order, nil
+		}
+	case "date", "datetime", "timestamp":
+		return bytes.Compare(as, bs), nil
+	case "time":
+		return compareTimeOfDay(string(as), string(bs))
+	}
+	if bytes.Equal(as, bs) {
+		return 0, nil
+	}
+	if column.charset == "" || column.collation == "binary" {
+		return bytes.Compare(as, bs), nil
+	}
+	return comparer.compareInCollation(column, as, bs)
+}
+
+// compareInCollation asks the source server how two strings sort in the column's collation
+func (comparer *keyComparer) compareInCollation(column keyColumn, a, b []byte) (order int, err error) {
+	if comparer.db == nil {
+		return bytes.Compare(a, b), nil
+	}
+	query := fmt.Sprintf("select strcmp(convert(? using %s) collate %s, convert(? using %s) collate %s)",
+		column.charset, column.collation, column.charset, column.collation)
+	if err := comparer.db.QueryRow(query, a, b).Scan(&order); err != nil {
+		return 0, fmt.Errorf("compare key column %s in collation %s: %v", column.name, column.collation, err)
+	}
+	return order, nil
+}
+
+// keyValueBytes renders a scanned key value as MySQL sends it in the text protocol
+func keyValueBytes(value interface{}) []byte {
+	switch v := value.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	case float32:
+		return []byte(strconv.FormatFloat(float64(v), 'g', -1, 32))
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'g', -1, 64))
+	case time.Time:
+		return []byte(v.Format("2006-01-02 15:04:05.999999"))
+	}
+	return []byte(formatPrimaryKeyValue(value))
+}
+
+// compareNumbers compares two integer, decimal or floating-point literals exactly
+func compareNumbers(a, b []byte) (int, error) {
+	x, ok := new(big.Rat).SetString(string(a))
+	if !ok {
+		return 0, fmt.Errorf("critical: key value %q is not a number", a)
+	}
+	y, ok := new(big.Rat).SetString(string(b))
+	if !ok {
+		return 0, fmt.Errorf("critical: key value %q is not a number", b)
+	}
+	return x.Cmp(y), nil
+}
+
+func compareTimes(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+// compareEnumValues compares enum values by their position in the column definition, as ORDER BY does
+func compareEnumValues(enumValues []string, a, b string) (int, bool) {
+	x, y := -1, -1
+	for i, value := range enumValues {
+		if value == a {
+			x = i
+		}
+		if value == b {
+			y = i
+		}
+	}
+	if x < 0 || y < 0 {
+		return 0, false
+	}
+	return compareInts(x, y), true
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareTimeOfDay compares two TIME values, [-]hhh:mm:ss[.ffffff]
+func compareTimeOfDay(a, b string) (int, error) {
+	x, err := timeOfDayMicroseconds(a)
+	if err != nil {
+		return 0, err
+	}
+	y, err := timeOfDayMicroseconds(b)
+	if err != nil {
+		return 0, err
+	}
+	return x.Cmp(y), nil
+}
+
+func timeOfDayMicroseconds(value string) (*big.Rat, error) {
+	negative := strings.HasPrefix(value, "-")
+	parts := strings.Split(strings.TrimPrefix(value, "-"), ":")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("critical: key value %q is not a time", value)
+	}
+	seconds := new(big.Rat)
+	for i, multiplier := range []int64{3600, 60, 1} {
+		part, ok := new(big.Rat).SetString(parts[i])
+		if !ok {
+			return nil, fmt.Errorf("critical: key value %q is not a time", value)
+		}
+		seconds.Add(seconds, part.Mul(part, big.NewRat(multiplier, 1)))
+	}
+	if negative {
+		seconds.Neg(seconds)
+	}
+	return seconds, nil
+}
+
+// mergeRecords merge-joins the source and target records of a chunk, both in key order,
+// and reports their differences in key order
+func (td *TableDiffer) mergeRecords(source, target recordSource, keys *keyComparer) (*DifferenceReport, error) {
+	report := &DifferenceReport{
+		SampleDifferences: make([]RecordDifference, 0),
+	}
+	sourceRecord, sourceOK, err := source.next()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get source records: %v", err)
+	}
+	targetRecord, targetOK, err := target.next()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get target records: %v", err)
+	}
+	for sourceOK || targetOK {
+		order := -1
+		if !sourceOK {
+			order = 1
+		} else if targetOK {
+			if order, err = keys.compareWeighted(sourceRecord.KeyValues, targetRecord.KeyValues, sourceRecord.KeyWeights, targetRecord.KeyWeights); err != nil {
+				return nil, err
+			}
+		}
+		switch {
+		case order < 0:
+			report.SourceOnlyRecords++
+			td.addSampleDifference(report, RecordDifference{
+				PrimaryKeyValues: sourceRecord.PrimaryKeyValues,
+				DifferenceType:   "source_only",
+				SourceChecksum:   sourceRecord.Checksum,
+			})
+		case order > 0:
+			report.TargetOnlyRecords++
+			td.addSampleDifference(report, RecordDifference{
+				PrimaryKeyValues: targetRecord.PrimaryKeyValues,
+				DifferenceType:   "target_only",
+				TargetChecksum:   targetRecord.Checksum,
+			})
+		case sourceRecord.Checksum != targetRecord.Checksum:
+			report.ModifiedRecords++
+			td.addSampleDifference(report, RecordDifference{
+				PrimaryKeyValues: sourceRecord.PrimaryKeyValues,
+				DifferenceType:   "modified",
+				SourceChecksum:   sourceRecord.Checksum,
+				TargetChecksum:   targetRecord.Checksum,
+			})
+		default:
+			report.IdenticalRecords++
+		}
+		if order <= 0 {
+			if sourceRecord, sourceOK, err = source.next(); err != nil {
+				return nil, fmt.Errorf("failed to get source records: %v", err)
+			}
+		}
+		if order >= 0 {
+			if targetRecord, targetOK, err = target.next(); err != nil {
+				return nil, fmt.Errorf("failed to get target records: %v", err)
+			}
+		}
+	}
+	return report, nil
+}
+
+// addSampleDifference keeps the difference as a sample while there are fewer than --max-sample-differences
+func (td *TableDiffer) addSampleDifference(report *DifferenceReport, difference RecordDifference) {
+	if len(report.SampleDifferences) < td.Context.Context.MaxSampleDifferences {
+		report.SampleDifferences = append(report.SampleDifferences, difference)
+	}
+}
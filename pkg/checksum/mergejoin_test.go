@@ -0,0 +1,149 @@
+package checksum
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+func TestParseEnumValues(t *testing.T) {
+	got := parseEnumValues("enum('small','it''s',  'x,y')")
+	want := []string{"small", "it's", "x,y"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseEnumValues = %q, want %q", got, want)
+	}
+}
+
+// Keys compare in the order MySQL sorts them, not as rendered strings
+func TestKeyComparerCompareValue(t *testing.T) {
+	comparer := &keyComparer{}
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		column keyColumn
+		a, b   interface{}
+		want   int
+	}{
+		{keyColumn{dataType: "int"}, []byte("9"), []byte("10"), -1},
+		{keyColumn{dataType: "bigint"}, []byte("-5"), int64(-4), -1},
+		{keyColumn{dataType: "bigint"}, []byte("18446744073709551615"), []byte("9223372036854775807"), 1},
+		{keyColumn{dataType: "decimal"}, []byte("2.50"), []byte("2.5"), 0},
+		{keyColumn{dataType: "double"}, float64(1e20), []byte("99999"), 1},
+		{keyColumn{dataType: "enum", enumValues: []string{"small", "large"}}, []byte("small"), []byte("large"), -1},
+		{keyColumn{dataType: "datetime"}, day, day.Add(time.Second), -1},
+		{keyColumn{dataType: "time"}, []byte("-01:00:00"), []byte("00:30:00"), -1},
+		{keyColumn{dataType: "time"}, []byte("100:00:00"), []byte("99:59:59.5"), 1},
+		{keyColumn{dataType: "varbinary"}, []byte{0x01}, []byte{0xff}, -1},
+		{keyColumn{dataType: "varchar", charset: "utf8mb4", collation: "utf8mb4_general_ci"}, []byte("abc"), []byte("abc"), 0},
+		{keyColumn{dataType: "int"}, nil, []byte("1"), -1},
+	}
+	for _, c := range cases {
+		got, err := comparer.compareValue(c.column, c.a, c.b)
+		if err != nil || got != c.want {
+			t.Errorf("%s: compare(%v, %v) = %d, %v; want %d", c.column.dataType, c.a, c.b, got, err, c.want)
+		}
+	}
+	if _, err := comparer.compareValue(keyColumn{dataType: "int"}, []byte("x"), []byte("1")); err == nil {
+		t.Error("a non-numeric integer key should be an error")
+	}
+}
+
+// Differences come out in key order across both sides
+func TestMergeRecordsKeyOrder(t *testing.T) {
+	td := &TableDiffer{Context: &ChecksumContext{Context: types.NewBaseContext()}}
+	record := func(id int64, checksum string) RecordData {
+		return RecordData{PrimaryKeyValues: map[string]interface{}{"id": id}, KeyValues: []interface{}{id}, Checksum: checksum}
+	}
+	source := recordList{record(2, "a"), record(3, "b"), record(10, "c")}
+	target := recordList{record(1, "x"), record(3, "changed"), record(9, "y"), record(10, "c")}
+	report, err := td.mergeRecords(&source, &target, intKeys)
+	if err != nil {
+		t.Fatalf("mergeRecords: %v", err)
+	}
+	var got []string
+	for _, diff := range report.SampleDifferences {
+		got = append(got, formatPrimaryKeyValue(diff.PrimaryKeyValues["id"])+":"+diff.DifferenceType)
+	}
+	want := []string{"1:target_only", "2:source_only", "3:modified", "9:target_only"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("differences = %v, want %v", got, want)
+	}
+	if report.IdenticalRecords != 1 {
+		t.Errorf("IdenticalRecords = %d, want 1", report.IdenticalRecords)
+	}
+}
+
+// Key columns merge when they sort alike, whatever their display width or server version
+func TestSameKeyOrder(t *testing.T) {
+	column := func(columnType, dataType, charset, collation string) []keyColumn {
+		return []keyColumn{{name: "id", dataType: dataType, columnType: columnType, charset: charset, collation: collation}}
+	}
+	cases := []struct {
+		source, target []keyColumn
+		ok             bool
+	}{
+		{column("int(11)", "int", "", ""), column("int", "int", "", ""), true},
+		{column("int", "int", "", ""), column("bigint unsigned", "bigint", "", ""), true},
+		{column("datetime", "datetime", "", ""), column("timestamp", "timestamp", "", ""), true},
+		{column("varbinary(16)", "varbinary", "", ""), column("binary(16)", "binary", "", ""), true},
+		{column("varchar(32)", "varchar", "utf8", "utf8_general_ci"), column("varchar(32)", "varchar", "utf8mb3", "utf8mb3_general_ci"), true},
+		{column("varchar(32)", "varchar", "utf8mb4", "utf8mb4_general_ci"), column("varchar(32)", "varchar", "utf8mb4", "utf8mb4_0900_ai_ci"), false},
+		{column("int", "int", "", ""), column("varchar(11)", "varchar", "utf8mb4", "utf8mb4_general_ci"), false},
+		{column("varchar(16)", "varchar", "latin1", "latin1_bin"), column("varbinary(16)", "varbinary", "", ""), false},
+	}
+	for _, c := range cases {
+		err := sameKeyOrder(c.source, c.target)
+		if (err == nil) != c.ok {
+			t.Errorf("%s %s vs %s %s: err = %v, want ok %v", c.source[0].columnType, c.source[0].collation, c.target[0].columnType, c.target[0].collation, err, c.ok)
+		}
+	}
+}
+
+// Collation weights compare bytewise, the shorter one padded with spaces in a PAD SPACE collation
+func TestCompareWeights(t *testing.T) {
+	space := []byte{0x20}
+	cases := []struct {
+		a, b, padWeight []byte
+		want            int
+	}{
+		{[]byte{0x41, 0x42}, []byte{0x41, 0x41}, nil, 1},
+		{[]byte{0x41}, []byte{0x41, 0x20}, nil, -1},
+		{[]byte{0x41}, []byte{0x41, 0x20, 0x20}, space, 0},
+		{[]byte{0x41}, []byte{0x41, 0x09}, space, 1},
+		{[]byte{0x41, 0x21}, []byte{0x41}, space, 1},
+		{[]byte{0x00, 0x41}, []byte{0x00, 0x41, 0x02, 0x09}, []byte{0x02, 0x09}, 0},
+	}
+	for _, c := range cases {
+		if got := compareWeights(c.a, c.b, c.padWeight); got != c.want {
+			t.Errorf("compareWeights(%x, %x, pad %x) = %d, want %d", c.a, c.b, c.padWeight, got, c.want)
+		}
+	}
+}
+
+// Keys equal in their collation pair up by their weights, without asking the server
+func TestMergeRecordsByKeyWeights(t *testing.T) {
+	td := &TableDiffer{Context: &ChecksumContext{
+		Context:      types.NewBaseContext(),
+		UniqueKey:    types.NewColumnList([]string{"code"}),
+		CheckColumns: types.NewColumnList([]string{"code", "name"}),
+	}}
+	keys := &keyComparer{columns: []keyColumn{{name: "code", dataType: "varchar", charset: "utf8mb4", collation: "utf8mb4_general_ci", padWeight: []byte{0x00, 0x20}}}}
+	record := func(code string, weight []byte, checksum string) RecordData {
+		return RecordData{PrimaryKeyValues: map[string]interface{}{"code": code}, KeyValues: []interface{}{[]byte(code)}, KeyWeights: [][]byte{weight}, Checksum: checksum}
+	}
+	source := recordList{record("abc", []byte{0x00, 0x41, 0x00, 0x42, 0x00, 0x43}, "x"), record("b", []byte{0x00, 0x42}, "y")}
+	target := recordList{record("ABC", []byte{0x00, 0x41, 0x00, 0x42, 0x00, 0x43}, "x"), record("B ", []byte{0x00, 0x42, 0x00, 0x20}, "z")}
+	report, err := td.mergeRecords(&source, &target, keys)
+	if err != nil {
+		t.Fatalf("mergeRecords: %v", err)
+	}
+	if report.IdenticalRecords != 1 || report.ModifiedRecords != 1 || report.SourceOnlyRecords+report.TargetOnlyRecords != 0 {
+		t.Errorf("report = %+v, want 1 identical and 1 modified record", report)
+	}
+
+	query, err := td.buildRecordQuery("test_db", "test_table", "1=1", keys)
+	if err != nil || !contains(query, "WEIGHT_STRING(`code`)") {
+		t.Errorf("the record query should select the weights of string key columns (%v):\n%s", err, query)
+	}
+}
@@ -0,0 +1,132 @@
+package checksum
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/builder"
+	"github.com/ChaosHour/go-data-checksum/pkg/tracking"
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+// Oversized chunks (--chunk-size-limit): chunk boundaries are probed on the source, so a chunk
+// only holds about the chunk size of rows there. Where the target has many more rows in the same
+// key range, its chunk query reads all of them at once. Before a chunk is checked, its target rows
+// are counted with a LIMIT just past --chunk-size-limit times the chunk size; a chunk reaching it is
+// handled by --oversized-chunk-action: split checks it in pieces of chunk size target rows, skip
+// leaves it unchecked (the table is not reported equal), abort fails the table. The action is
+// recorded in chunk_comparisons.oversized_action.
+
+// oversizedChunkLimit returns the most target rows a chunk of chunkSize rows may hold, 0 when unlimited
+func oversizedChunkLimit(chunkSizeLimit float64, chunkSize int64) int64 {
+	if chunkSizeLimit <= 0 || chunkSize <= 0 {
+		return 0
+	}
+	return int64(math.Ceil(chunkSizeLimit * float64(chunkSize)))
+}
+
+// GuardOversizedChunk counts the target rows of the current chunk and applies --oversized-chunk-action
+// to an oversized one. skip reports the chunk must not be checked: it was skipped, or split into
+// pieces that the next calls of CalculateNextIterationRangeEndValues return. An aborted chunk
+// returns the error.
+func (ctx *ChecksumContext) GuardOversizedChunk() (skip bool, err error) {
+	limit := oversizedChunkLimit(ctx.Context.ChunkSizeLimit, ctx.iterationChunkSize)
+	if limit == 0 || ctx.oversizedAction == types.SplitOversizedChunk {
+		return false, nil
+	}
+	keyRange := ctx.CurrentKeyRange()
+	targetRows, err := ctx.countTargetRows(keyRange, limit+1)
+	if err != nil {
+		return false, err
+	}
+	if targetRows <= limit {
+		return false, nil
+	}
+	ctx.oversizedAction = ctx.Context.OversizedChunkAction
+	switch ctx.oversizedAction {
+	case types.AbortOversizedChunk:
+		return false, fmt.Errorf("critical: chunk %s of table %s.%s holds more than %d rows on the target, over --chunk-size-limit %g", keyRange, ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, limit, ctx.Context.ChunkSizeLimit)
+	case types.SkipOversizedChunk:
+		ctx.Context.Log.Warnf("Chunk %s of table %s.%s holds more than %d rows on the target; skipping it.", keyRange, ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, limit)
+		ctx.TrackSkippedChunk(ctx.NextChunkNumber(), targetRows)
+		return true, nil
+	}
+	chunks, err := ctx.splitTargetRange(keyRange, ctx.iterationChunkSize)
+	if err != nil {
+		return false, err
+	}
+	ctx.Context.Log.Infof("Chunk %s of table %s.%s holds more than %d rows on the target; checking it in %d pieces.", keyRange, ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, limit, len(chunks))
+	ctx.pendingChunks = chunks
+	return true, nil
+}
+
+// countTargetRows counts the rows of keyRange on the target, stopping at limit
+func (ctx *ChecksumContext) countTargetRows(keyRange KeyRange, limit int64) (rowCount int64, err error) {
+	query, explodedArgs, err := builder.BuildBoundedRangeCountPreparedQuery(
+		ctx.PerTableContext.TargetDatabaseName,
+		ctx.PerTableContext.TargetTableName,
+		ctx.partition,
+		ctx.UniqueKey,
+		keyRange.Min.AbstractValues(),
+		keyRange.Max.AbstractValues(),
+		keyRange.IncludeMin,
+		limit,
+	)
+	if err != nil {
+		return 0, err
+	}
+	if err = ctx.targetDB().QueryRow(query, explodedArgs...).Scan(&rowCount); err != nil {
+		return 0, fmt.Errorf("critical: count rows of chunk %s of table %s.%s failed: %v", keyRange, ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, err)
+	}
+	return rowCount, nil
+}
+
+// splitTargetRange cuts keyRange into pieces of chunkSize target rows; the last piece ends at keyRange.Max
+func (ctx *ChecksumContext) splitTargetRange(keyRange KeyRange, chunkSize int64) (chunks []KeyRange, err error) {
+	var bounds []*types.ColumnValues
+	start, includeStart := keyRange.Min, keyRange.IncludeMin
+	for {
+		bound, found, err := ctx.probeTableRangeEnd(ctx.targetDB(), ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, "",
+			false, start, keyRange.Max, includeStart, chunkSize, "oversized-split")
+		if err != nil {
+			return nil, err
+		}
+		if !found || bound.String() == keyRange.Max.String() {
+			break
+		}
+		bounds = append(bounds, bound)
+		start, includeStart = bound, false
+	}
+	return keyRangesBetween(keyRange, bounds), nil
+}
+
+// nextPendingChunk makes the next piece of a split oversized chunk the current one, like
+// CalculateNextIterationRangeEndValues
+func (ctx *ChecksumContext) nextPendingChunk() (hasFurtherRange bool, err error) {
+	chunk := ctx.pendingChunks[0]
+	ctx.pendingChunks = ctx.pendingChunks[1:]
+	ctx.iterationIncludesMin = chunk.IncludeMin
+	ctx.ChecksumIterationRangeMinValues = chunk.Min
+	ctx.ChecksumIterationRangeMaxValues = chunk.Max
+	ctx.oversizedAction = types.SplitOversizedChunk
+	return true, nil
+}
+
+// SkippedChunks returns the number of oversized chunks of the table left unchecked
+func (ctx *ChecksumContext) SkippedChunks() int {
+	return ctx.chunksSkipped
+}
+
+// TrackSkippedChunk records a skipped oversized chunk and the target rows counted in it
+func (ctx *ChecksumContext) TrackSkippedChunk(chunkNumber int, targetRows int64) {
+	ctx.chunksSkipped++
+	if ctx.JobTracker == nil {
+		return
+	}
+	rangeStart, rangeEnd := ctx.chunkRanges()
+	if err := ctx.JobTracker.RecordChunkComparison(ctx.ComparisonID, chunkNumber, rangeStart, rangeEnd,
+		tracking.ChunkChecksum{RowCount: -1}, tracking.ChunkChecksum{RowCount: targetRows},
+		tracking.StatusSkipped, string(ctx.oversizedAction), ctx.iterationChunkSize, 0); err != nil {
+		ctx.Context.Log.Warnf("tracking: record chunk %d failed: %v", chunkNumber, err)
+	}
+}
@@ -0,0 +1,81 @@
+package checksum
+
+import (
+	"testing"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+func TestOversizedChunkLimit(t *testing.T) {
+	tests := []struct {
+		chunkSizeLimit float64
+		chunkSize      int64
+		want           int64
+	}{
+		{0, 1000, 0},
+		{2, 0, 0},
+		{2, 1000, 2000},
+		{1.5, 3, 5},
+	}
+	for _, tt := range tests {
+		if got := oversizedChunkLimit(tt.chunkSizeLimit, tt.chunkSize); got != tt.want {
+			t.Errorf("oversizedChunkLimit(%g, %d) = %d, want %d", tt.chunkSizeLimit, tt.chunkSize, got, tt.want)
+		}
+	}
+}
+
+func TestSplitChunkPiecesComeFirst(t *testing.T) {
+	baseContext := types.NewBaseContext()
+	baseContext.ChunkSizeLimit = 2
+	ctx := NewChecksumContext(baseContext, types.NewTableContext("srcdb", "t1", "tgtdb", "t1"))
+	ctx.chunkPlan = testChunkPlan(1, 100, 200)
+	ctx.iterationChunkSize = 100
+	// Chunk [1, 100] was found oversized and split in two
+	ctx.pendingChunks = keyRangesBetween(ctx.chunkPlan.chunks[0], []*types.ColumnValues{types.ToColumnValues([]interface{}{int64(50)})})
+	ctx.chunkPlan.next = 1
+
+	var ranges []string
+	for {
+		hasFurtherRange, err := ctx.CalculateNextIterationRangeEndValues()
+		if err != nil {
+			t.Fatalf("CalculateNextIterationRangeEndValues: %v", err)
+		}
+		if !hasFurtherRange {
+			break
+		}
+		keyRange := ctx.CurrentKeyRange()
+		ranges = append(ranges, keyRange.String())
+		if len(ranges) <= 2 {
+			if ctx.oversizedAction != types.SplitOversizedChunk {
+				t.Errorf("piece %s should be recorded as split", keyRange)
+			}
+			// Pieces are not counted again
+			if skip, err := ctx.GuardOversizedChunk(); skip || err != nil {
+				t.Errorf("GuardOversizedChunk of piece %s = %t, %v", keyRange, skip, err)
+			}
+		} else if ctx.oversizedAction != "" {
+			t.Errorf("chunk %s after the pieces has oversized action %q", keyRange, ctx.oversizedAction)
+		}
+	}
+	want := []string{"[1, 50]", "(50, 100]", "(100, 200]"}
+	if len(ranges) != len(want) {
+		t.Fatalf("chunks = %v, want %v", ranges, want)
+	}
+	for i := range want {
+		if ranges[i] != want[i] {
+			t.Errorf("chunk %d = %s, want %s", i, ranges[i], want[i])
+		}
+	}
+}
+
+func TestSkippedChunksAreMerged(t *testing.T) {
+	ctx := NewChecksumContext(types.NewBaseContext(), types.NewTableContext("srcdb", "t1", "tgtdb", "t1"))
+	ctx.oversizedAction = types.SkipOversizedChunk
+	ctx.TrackSkippedChunk(0, 5000)
+
+	parent := NewChecksumContext(types.NewBaseContext(), types.NewTableContext("srcdb", "t1", "tgtdb", "t1"))
+	parent.MergeRangeContexts([]*ChecksumContext{ctx, ctx})
+	if got := parent.SkippedChunks(); got != 2 {
+		t.Errorf("SkippedChunks() = %d, want 2", got)
+	}
+}
@@ -0,0 +1,182 @@
+package checksum
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/tracking"
+)
+
+// Partition-aware checksumming: the chunk loop of a partitioned table would walk the global unique
+// key, touching every partition. When the target has the same partitions (names, method, expression
+// and bounds), the table is checked one partition at a time instead: each partition gets its own
+// context whose queries read through a PARTITION clause, and its own count check, chunk loop and
+// result. --partitions restricts the check to the named partitions. Partition results are tracked
+// in partition_comparisons, and a resumed table skips the partitions found equal or different
+// there. Keyless tables, --specified-time-column and sampling check partitioned tables as a whole.
+
+// tablePartition is a partition of a table as information_schema.PARTITIONS describes it
+type tablePartition struct {
+	name        string
+	method      string
+	expression  string
+	description string
+}
+
+// readPartitions returns the partitions of a table in their order, none when it is not partitioned.
+// Subpartitions are folded into their partition.
+func readPartitions(db *gosql.DB, databaseName, tableName string) (partitions []tablePartition, err error) {
+	query := `
+    select PARTITION_NAME, MIN(IFNULL(PARTITION_METHOD, '')), MIN(IFNULL(PARTITION_EXPRESSION, '')), MIN(IFNULL(PARTITION_DESCRIPTION, ''))
+      from information_schema.PARTITIONS
+     where TABLE_SCHEMA = ? and TABLE_NAME = ? and PARTITION_NAME is not null
+     group by PARTITION_NAME
+     order by MIN(PARTITION_ORDINAL_POSITION)
+  `
+	rows, err := db.Query(query, databaseName, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("critical: table %s.%s get partitions failed: %v", databaseName, tableName, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var partition tablePartition
+		if err := rows.Scan(&partition.name, &partition.method, &partition.expression, &partition.description); err != nil {
+			return nil, err
+		}
+		partitions = append(partitions, partition)
+	}
+	return partitions, rows.Err()
+}
+
+// samePartitions reports whether two tables are partitioned alike, or names the first difference
+func samePartitions(source, target []tablePartition) (bool, string) {
+	if len(source) != len(target) {
+		return false, fmt.Sprintf("%d source and %d target partitions", len(source), len(target))
+	}
+	for i := range source {
+		s, t := source[i], target[i]
+		if !strings.EqualFold(s.name, t.name) || !strings.EqualFold(s.method, t.method) || s.expression != t.expression || s.description != t.description {
+			return false, fmt.Sprintf("partition %s differs from target partition %s", s.name, t.name)
+		}
+	}
+	return true, ""
+}
+
+// selectPartitions returns the partitions named in the comma-separated list, in table order and
+// as the table names them; an empty list selects every partition
+func selectPartitions(partitions []tablePartition, list string) (names []string, err error) {
+	selected := map[string]bool{}
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		found := false
+		for _, partition := range partitions {
+			if strings.EqualFold(partition.name, name) {
+				selected[partition.name], found = true, true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("critical: no partition %q", name)
+		}
+	}
+	for _, partition := range partitions {
+		if len(selected) == 0 || selected[partition.name] {
+			names = append(names, partition.name)
+		}
+	}
+	return names, nil
+}
+
+// ReadPartitions returns the partitions of the table to check one at a time, nil to check the
+// table as a whole: when it is not partitioned, partitioned differently on the target, or sampled.
+// --partitions given for the table itself, not for every table, must name partitions to check.
+func (ctx *ChecksumContext) ReadPartitions() (partitions []string, err error) {
+	requested := ctx.Context.Partitions.For(ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName)
+	if ctx.Context.IsSampling() {
+		return nil, nil
+	}
+	source, err := readPartitions(ctx.Context.SourceDB, ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName)
+	if err != nil {
+		return nil, err
+	}
+	if len(source) == 0 {
+		if ctx.Context.Partitions.Has(ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName) {
+			return nil, fmt.Errorf("critical: --partitions given for table %s.%s, which is not partitioned", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName)
+		}
+		return nil, nil
+	}
+	target, err := readPartitions(ctx.Context.TargetDB, ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName)
+	if err != nil {
+		return nil, err
+	}
+	if same, difference := samePartitions(source, target); !same {
+		if ctx.Context.Partitions.Has(ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName) {
+			return nil, fmt.Errorf("critical: table %s.%s is not partitioned like target table %s.%s (%s), --partitions cannot be used", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, difference)
+		}
+		ctx.Context.Log.Infof("Table pair: %s.%s => %s.%s is checked as a whole: the tables are partitioned differently (%s).", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, difference)
+		return nil, nil
+	}
+	if partitions, err = selectPartitions(source, requested); err != nil {
+		return nil, fmt.Errorf("%v in table %s.%s", err, ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName)
+	}
+	return partitions, nil
+}
+
+// Partition returns the partition the context checks, "" for the whole table
+func (ctx *ChecksumContext) Partition() string {
+	return ctx.partition
+}
+
+// NewPartitionContext returns a context checking only the given partition of the table. It shares the
+// table's chunk counter but not its snapshot: with --consistent-snapshot each partition opens its own,
+// so refreshing it leaves the table and the other partitions alone. MergeRangeContexts folds its
+// results back into the table's context.
+func (ctx *ChecksumContext) NewPartitionContext(partition string) *ChecksumContext {
+	perTableContext := *ctx.PerTableContext
+	perTableContext.DifferentChunkRanges = nil
+	perTableContext.DifferentColumns = nil
+	partitionContext := NewChecksumContext(ctx.Context, &perTableContext)
+	partitionContext.partition = partition
+	partitionContext.CheckColumns = ctx.CheckColumns
+	partitionContext.UniqueKey = ctx.UniqueKey
+	partitionContext.UniqueIndexName = ctx.UniqueIndexName
+	partitionContext.chunkCounter = ctx.iterationCounter()
+	partitionContext.JobTracker = ctx.JobTracker
+	partitionContext.ComparisonID = ctx.ComparisonID
+	partitionContext.Throttler = ctx.Throttler
+	partitionContext.queryPlans = ctx.queryPlans
+	return partitionContext
+}
+
+// CheckedPartitions returns the status of the partitions a resumed table already found equal or
+// different, counting their chunks into the table's tallies, and continues the chunk numbering
+// after the table's last recorded chunk
+func (ctx *ChecksumContext) CheckedPartitions() map[string]string {
+	if ctx.JobTracker == nil || ctx.ComparisonID == 0 {
+		return nil
+	}
+	recorded, err := ctx.JobTracker.GetPartitionComparisons(ctx.ComparisonID)
+	if err != nil {
+		ctx.Context.Log.Warnf("tracking: read partitions of table comparison %d failed: %v", ctx.ComparisonID, err)
+		return nil
+	}
+	checked := map[string]string{}
+	for _, partition := range recorded {
+		if partition.Status != tracking.StatusEqual && partition.Status != tracking.StatusDifferent {
+			continue
+		}
+		checked[partition.PartitionName] = partition.Status
+		ctx.chunksEqual += partition.ChunksEqual
+		ctx.chunksDifferent += partition.ChunksDifferent
+	}
+	checkpoint, err := ctx.JobTracker.GetChunkCheckpoint(ctx.ComparisonID)
+	if err != nil {
+		ctx.Context.Log.Warnf("tracking: read checkpoint of table comparison %d failed: %v", ctx.ComparisonID, err)
+	} else if checkpoint != nil {
+		atomic.StoreInt64(ctx.iterationCounter(), int64(checkpoint.ChunkNumber)+1)
+	}
+	return checked
+}
@@ -0,0 +1,75 @@
+package checksum
+
+import (
+	gosql "database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+func TestSamePartitions(t *testing.T) {
+	source := []tablePartition{
+		{name: "p2023", method: "RANGE", expression: "`year`", description: "2024"},
+		{name: "p2024", method: "RANGE", expression: "`year`", description: "2025"},
+	}
+	if same, difference := samePartitions(source, []tablePartition{source[0], {name: "P2024", method: "range", expression: "`year`", description: "2025"}}); !same {
+		t.Errorf("partitions differing in case only should match: %s", difference)
+	}
+	if same, _ := samePartitions(source, source[:1]); same {
+		t.Error("a missing target partition should not match")
+	}
+	if same, _ := samePartitions(source, []tablePartition{source[0], {name: "p2024", method: "RANGE", expression: "`year`", description: "2026"}}); same {
+		t.Error("partitions with different bounds should not match")
+	}
+	if same, _ := samePartitions(nil, nil); !same {
+		t.Error("tables without partitions should match")
+	}
+}
+
+func TestSelectPartitions(t *testing.T) {
+	partitions := []tablePartition{{name: "p1"}, {name: "p2"}, {name: "p3"}}
+	cases := []struct {
+		list string
+		want []string
+	}{
+		{"", []string{"p1", "p2", "p3"}},
+		{"p3, P1", []string{"p1", "p3"}},
+		{"p2,p2", []string{"p2"}},
+	}
+	for _, c := range cases {
+		got, err := selectPartitions(partitions, c.list)
+		if err != nil || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("selectPartitions(%q) = %v, %v; want %v", c.list, got, err, c.want)
+		}
+	}
+	if _, err := selectPartitions(partitions, "p1,p9"); err == nil {
+		t.Error("an unknown partition should be rejected")
+	}
+}
+
+func TestPartitionContextRecordsDifferentChunks(t *testing.T) {
+	ctx := NewChecksumContext(types.NewBaseContext(), types.NewTableContext("srcdb", "t1", "tgtdb", "t1"))
+	partitionContext := ctx.NewPartitionContext("p1")
+	if partitionContext.iterationCounter() != ctx.iterationCounter() {
+		t.Error("a partition should share the chunk counter of its table")
+	}
+	if ctx.sourceSnapshot = new(gosql.Conn); ctx.NewPartitionContext("p2").sourceSnapshot != nil {
+		t.Error("a partition should open its own snapshot rather than share the table's")
+	}
+	ctx.sourceSnapshot = nil
+	partitionContext.ChecksumIterationRangeMinValues = types.ToColumnValues([]interface{}{int64(1)})
+	partitionContext.ChecksumIterationRangeMaxValues = types.ToColumnValues([]interface{}{int64(100)})
+	partitionContext.iterationIncludesMin = true
+	partitionContext.TrackChunk(0, false, nil, time.Millisecond)
+
+	ctx.MergeRangeContexts([]*ChecksumContext{partitionContext})
+	want := []string{"partition p1: [1, 100]"}
+	if !reflect.DeepEqual(ctx.PerTableContext.DifferentChunkRanges, want) {
+		t.Errorf("DifferentChunkRanges = %v, want %v", ctx.PerTableContext.DifferentChunkRanges, want)
+	}
+	if worker := partitionContext.NewRangeContext(KeyRange{}); worker.Partition() != "p1" {
+		t.Errorf("a key range worker of a partition checks partition %q", worker.Partition())
+	}
+}
@@ -0,0 +1,288 @@
+package checksum
+
+import (
+	"context"
+	gosql "database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/builder"
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+	"github.com/go-sql-driver/mysql"
+)
+
+// Prefilter (--prefilter): before any chunk query, each table is compared as a whole. Where the
+// engines, row formats and column definitions of both sides match, --prefilter=checksum-table
+// compares CHECKSUM TABLE, whose differences only count between servers of the same version;
+// otherwise, and always with --prefilter=metadata, information_schema.TABLES is compared: a table
+// whose UPDATE_TIME on both sides is older than the start of a tracked comparison of the full
+// table that found it equal is unchanged since, and engines keeping exact TABLE_ROWS (MyISAM,
+// Aria, MEMORY) prove a difference by their row counts. Row estimates of other engines decide
+// nothing. A difference is only final when the whole table is compared row for row (no
+// --check-column-names, --is-superset-as-equal, time range or --partitions) and it persists over
+// prefilterAttempts reads, riding out replication lag like a chunk's retries. Only tables that
+// need a chunk check go through the chunk loop.
+
+// PrefilterVerdict is what the prefilter concluded about a table
+type PrefilterVerdict string
+
+const (
+	// DefinitelyEqual tables are reported equal without a chunk check
+	DefinitelyEqual PrefilterVerdict = "definitely equal"
+	// DefinitelyDifferent tables are reported different without a chunk check
+	DefinitelyDifferent PrefilterVerdict = "definitely different"
+	// NeedsChunkCheck tables go through the chunk loop
+	NeedsChunkCheck PrefilterVerdict = "needs chunk check"
+)
+
+// prefilterAttempts is how many times a difference is read before the table is called different
+const prefilterAttempts = 3
+
+// exactRowCountEngines keep an exact TABLE_ROWS in information_schema.TABLES; other engines estimate it
+var exactRowCountEngines = map[string]bool{"myisam": true, "aria": true, "memory": true}
+
+// erUnknownSystemVariable is the MySQL error number of "Unknown system variable"
+const erUnknownSystemVariable = 1193
+
+// tableMetadata is the information_schema.TABLES row of a table
+type tableMetadata struct {
+	engine    string
+	rowFormat string
+	tableRows gosql.NullInt64
+	// updateTime is the Unix time of the table's last change, NULL when the server does not know it
+	updateTime gosql.NullInt64
+}
+
+// Prefilter compares the table as a whole and returns the verdict with its reason. A missing target
+// table is definitely different; a missing source table is left to the chunk check to report.
+func (ctx *ChecksumContext) Prefilter() (verdict PrefilterVerdict, reason string, err error) {
+	source, target, err := ctx.readPrefilterMetadata()
+	if err != nil {
+		return NeedsChunkCheck, "", err
+	}
+	if source == nil {
+		return NeedsChunkCheck, "source table not found", nil
+	}
+	if target == nil {
+		ctx.prefilterMethod = types.MetadataPrefilter
+		return DefinitelyDifferent, "target table not found", nil
+	}
+	if ctx.Context.Prefilter == types.ChecksumTablePrefilter {
+		comparable, why, err := ctx.checksumTableComparable(source, target)
+		if err != nil {
+			return NeedsChunkCheck, "", err
+		}
+		if comparable {
+			return ctx.prefilterByChecksumTable()
+		}
+		verdict, reason, err = ctx.prefilterByMetadata(source, target)
+		return verdict, why + "; " + reason, err
+	}
+	return ctx.prefilterByMetadata(source, target)
+}
+
+// prefilterDecidesDifference reports whether a whole-table difference makes the table different
+func (ctx *ChecksumContext) prefilterDecidesDifference() bool {
+	return ctx.Context.RequestedColumnNames == "" && !ctx.Context.IsSuperSetAsEqual && !ctx.Context.IsDatetimeColumnSpecified() &&
+		ctx.Context.Partitions.For(ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName) == ""
+}
+
+// coversFullTable reports whether the comparison checks every row and column of the table. Only
+// such comparisons found equal let the metadata prefilter call the table unchanged later on.
+func (ctx *ChecksumContext) coversFullTable() bool {
+	return ctx.prefilterDecidesDifference() && !ctx.Context.IsSampling()
+}
+
+// checksumTableComparable reports whether CHECKSUM TABLE of both sides can be compared, or why not
+func (ctx *ChecksumContext) checksumTableComparable(source, target *tableMetadata) (bool, string, error) {
+	if !strings.EqualFold(source.engine, target.engine) {
+		return false, fmt.Sprintf("engines differ (%s, %s)", source.engine, target.engine), nil
+	}
+	if !strings.EqualFold(source.rowFormat, target.rowFormat) {
+		return false, fmt.Sprintf("row formats differ (%s, %s)", source.rowFormat, target.rowFormat), nil
+	}
+	sourceColumns, err := readColumnDefinitions(ctx.Context.SourceDB, ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName)
+	if err != nil {
+		return false, "", err
+	}
+	targetColumns, err := readColumnDefinitions(ctx.Context.TargetDB, ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName)
+	if err != nil {
+		return false, "", err
+	}
+	if strings.Join(sourceColumns, "\n") != strings.Join(targetColumns, "\n") {
+		return false, "column definitions differ", nil
+	}
+	return true, "", nil
+}
+
+// prefilterByChecksumTable compares CHECKSUM TABLE of both sides
+func (ctx *ChecksumContext) prefilterByChecksumTable() (PrefilterVerdict, string, error) {
+	for attempt := 1; ; attempt++ {
+		sourceChecksum, err := checksumTable(ctx.Context.SourceDB, ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName)
+		if err != nil {
+			return NeedsChunkCheck, "", err
+		}
+		targetChecksum, err := checksumTable(ctx.Context.TargetDB, ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName)
+		if err != nil {
+			return NeedsChunkCheck, "", err
+		}
+		if sourceChecksum == targetChecksum {
+			ctx.prefilterMethod = types.ChecksumTablePrefilter
+			return DefinitelyEqual, fmt.Sprintf("CHECKSUM TABLE is %s on both sides", sourceChecksum), nil
+		}
+		reason := fmt.Sprintf("CHECKSUM TABLE differs (source %s, target %s)", sourceChecksum, targetChecksum)
+		if !ctx.prefilterDecidesDifference() {
+			return NeedsChunkCheck, reason, nil
+		}
+		// The CHECKSUM TABLE algorithm and the storage of temporal columns change between server
+		// versions, so identical rows checksum differently across versions
+		if attempt == 1 {
+			sourceVersion, targetVersion, err := ctx.readServerVersions()
+			if err != nil {
+				return NeedsChunkCheck, "", err
+			}
+			if sourceVersion != targetVersion {
+				return NeedsChunkCheck, fmt.Sprintf("%s, but the servers run different versions (%s, %s)", reason, sourceVersion, targetVersion), nil
+			}
+		}
+		if attempt == prefilterAttempts {
+			ctx.prefilterMethod = types.ChecksumTablePrefilter
+			return DefinitelyDifferent, reason, nil
+		}
+		time.Sleep(1 * time.Second)
+	}
+}
+
+// readServerVersions returns @@version of the source and target servers
+func (ctx *ChecksumContext) readServerVersions() (sourceVersion, targetVersion string, err error) {
+	if err = ctx.Context.SourceDB.QueryRow("select @@version").Scan(&sourceVersion); err != nil {
+		return "", "", fmt.Errorf("critical: source get version failed: %v", err)
+	}
+	if err = ctx.Context.TargetDB.QueryRow("select @@version").Scan(&targetVersion); err != nil {
+		return "", "", fmt.Errorf("critical: target get version failed: %v", err)
+	}
+	return sourceVersion, targetVersion, nil
+}
+
+// prefilterByMetadata compares the information_schema.TABLES rows of both sides
+func (ctx *ChecksumContext) prefilterByMetadata(source, target *tableMetadata) (PrefilterVerdict, string, error) {
+	if source.updateTime.Valid && target.updateTime.Valid {
+		lastEqualStart, found, err := ctx.JobTracker.GetLastEqualStartTime(ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName,
+			ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName)
+		if err != nil {
+			return NeedsChunkCheck, "", err
+		}
+		if found && source.updateTime.Int64 < lastEqualStart && target.updateTime.Int64 < lastEqualStart {
+			ctx.prefilterMethod = types.MetadataPrefilter
+			return DefinitelyEqual, fmt.Sprintf("unchanged since found equal at %s", time.Unix(lastEqualStart, 0).Format("2006-01-02 15:04:05")), nil
+		}
+	}
+	for attempt := 1; ; attempt++ {
+		reason := fmt.Sprintf("about %s source and %s target rows", formatTableRows(source.tableRows), formatTableRows(target.tableRows))
+		if !exactRowCountEngines[strings.ToLower(source.engine)] || !exactRowCountEngines[strings.ToLower(target.engine)] ||
+			!source.tableRows.Valid || !target.tableRows.Valid || source.tableRows.Int64 == target.tableRows.Int64 || !ctx.prefilterDecidesDifference() {
+			return NeedsChunkCheck, reason, nil
+		}
+		if attempt == prefilterAttempts {
+			ctx.prefilterMethod = types.MetadataPrefilter
+			ctx.SourceRowCount, ctx.TargetRowCount = source.tableRows.Int64, target.tableRows.Int64
+			return DefinitelyDifferent, fmt.Sprintf("%d source and %d target rows", source.tableRows.Int64, target.tableRows.Int64), nil
+		}
+		time.Sleep(1 * time.Second)
+		var err error
+		if source, target, err = ctx.readPrefilterMetadata(); err != nil {
+			return NeedsChunkCheck, "", err
+		}
+		if source == nil || target == nil {
+			return NeedsChunkCheck, "table dropped during the prefilter", nil
+		}
+	}
+}
+
+func formatTableRows(tableRows gosql.NullInt64) string {
+	if !tableRows.Valid {
+		return "unknown"
+	}
+	return fmt.Sprintf("%d", tableRows.Int64)
+}
+
+// readPrefilterMetadata reads the information_schema.TABLES rows of both sides, nil for a missing table
+func (ctx *ChecksumContext) readPrefilterMetadata() (source, target *tableMetadata, err error) {
+	if source, err = readTableMetadata(ctx.Context.SourceDB, ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName); err != nil {
+		return nil, nil, err
+	}
+	if target, err = readTableMetadata(ctx.Context.TargetDB, ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName); err != nil {
+		return nil, nil, err
+	}
+	return source, target, nil
+}
+
+// readTableMetadata returns the information_schema.TABLES row of a table, nil when it does not exist.
+// MySQL 8.0 caches TABLE_ROWS and UPDATE_TIME for a day by default, so the cache is turned off for
+// the session first; older servers have no such cache.
+func readTableMetadata(db *gosql.DB, databaseName, tableName string) (*tableMetadata, error) {
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(context.Background(), "SET SESSION information_schema_stats_expiry = 0"); err != nil {
+		var mysqlErr *mysql.MySQLError
+		if !errors.As(err, &mysqlErr) || mysqlErr.Number != erUnknownSystemVariable {
+			return nil, err
+		}
+	}
+	query := `
+    select IFNULL(ENGINE, ''), IFNULL(ROW_FORMAT, ''), TABLE_ROWS, UNIX_TIMESTAMP(UPDATE_TIME)
+      from information_schema.TABLES
+     where TABLE_SCHEMA = ? and TABLE_NAME = ?
+  `
+	metadata := &tableMetadata{}
+	err = conn.QueryRowContext(context.Background(), query, databaseName, tableName).Scan(&metadata.engine, &metadata.rowFormat, &metadata.tableRows, &metadata.updateTime)
+	if err == gosql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("critical: table %s.%s get metadata failed: %v", databaseName, tableName, err)
+	}
+	return metadata, nil
+}
+
+// readColumnDefinitions returns the name, type, collation and nullability of each column of a table, in order
+func readColumnDefinitions(db *gosql.DB, databaseName, tableName string) (definitions []string, err error) {
+	query := `
+    select CONCAT_WS(' ', COLUMN_NAME, COLUMN_TYPE, IFNULL(COLLATION_NAME, ''), IS_NULLABLE)
+      from information_schema.COLUMNS
+     where TABLE_SCHEMA = ? and TABLE_NAME = ?
+     order by ORDINAL_POSITION
+  `
+	rows, err := db.Query(query, databaseName, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("critical: table %s.%s get column definitions failed: %v", databaseName, tableName, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var definition string
+		if err := rows.Scan(&definition); err != nil {
+			return nil, err
+		}
+		definitions = append(definitions, definition)
+	}
+	return definitions, rows.Err()
+}
+
+// checksumTable returns the CHECKSUM TABLE value of a table
+func checksumTable(db *gosql.DB, databaseName, tableName string) (string, error) {
+	var table string
+	var checksum gosql.NullString
+	query := fmt.Sprintf("CHECKSUM TABLE %s.%s", builder.EscapeName(databaseName), builder.EscapeName(tableName))
+	if err := db.QueryRow(query).Scan(&table, &checksum); err != nil {
+		return "", fmt.Errorf("critical: CHECKSUM TABLE %s.%s failed: %v", databaseName, tableName, err)
+	}
+	if !checksum.Valid {
+		return "", fmt.Errorf("critical: CHECKSUM TABLE %s.%s returned NULL", databaseName, tableName)
+	}
+	return checksum.String, nil
+}
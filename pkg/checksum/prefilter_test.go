@@ -0,0 +1,88 @@
+package checksum
+
+import (
+	gosql "database/sql"
+	"testing"
+	"time"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+func TestPrefilterDecidesDifference(t *testing.T) {
+	ctx := NewChecksumContext(types.NewBaseContext(), types.NewTableContext("db", "t", "db", "t"))
+	if !ctx.prefilterDecidesDifference() {
+		t.Error("whole-table comparison: a difference should decide the table")
+	}
+	ctx.Context.IsSuperSetAsEqual = true
+	if ctx.prefilterDecidesDifference() {
+		t.Error("--is-superset-as-equal: a difference should not decide the table")
+	}
+	ctx.Context.IsSuperSetAsEqual = false
+	ctx.Context.RequestedColumnNames = "a,b"
+	if ctx.prefilterDecidesDifference() {
+		t.Error("--check-column-names: a difference should not decide the table")
+	}
+	ctx.Context.RequestedColumnNames = ""
+	ctx.Context.Partitions = types.TableOptions{"db.t": "p1"}
+	if ctx.prefilterDecidesDifference() {
+		t.Error("--partitions: a difference should not decide the table")
+	}
+}
+
+// Only comparisons of every row and column may later certify a table as unchanged
+func TestCoversFullTable(t *testing.T) {
+	partial := []struct {
+		name  string
+		apply func(ctx *types.BaseContext)
+	}{
+		{"--check-column-names", func(ctx *types.BaseContext) { ctx.RequestedColumnNames = "a" }},
+		{"--is-superset-as-equal", func(ctx *types.BaseContext) { ctx.IsSuperSetAsEqual = true }},
+		{"--specified-time-column", func(ctx *types.BaseContext) {
+			ctx.SpecifiedDatetimeColumn = "updated_at"
+			ctx.SpecifiedDatetimeRangeBegin = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			ctx.SpecifiedDatetimeRangeEnd = time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+		}},
+		{"--partitions", func(ctx *types.BaseContext) { ctx.Partitions = types.TableOptions{"db.t": "p1"} }},
+		{"--sample-chunks", func(ctx *types.BaseContext) { ctx.SampleChunks = 10 }},
+	}
+	ctx := NewChecksumContext(types.NewBaseContext(), types.NewTableContext("db", "t", "db", "t"))
+	if !ctx.coversFullTable() {
+		t.Error("a plain comparison covers the full table")
+	}
+	for _, tt := range partial {
+		ctx := NewChecksumContext(types.NewBaseContext(), types.NewTableContext("db", "t", "db", "t"))
+		tt.apply(ctx.Context)
+		if ctx.coversFullTable() {
+			t.Errorf("%s: the comparison does not cover the full table", tt.name)
+		}
+	}
+}
+
+// Metadata that proves nothing leaves the table to the chunk check without another read
+func TestPrefilterByMetadataNeedsChunkCheck(t *testing.T) {
+	rows := func(n int64) gosql.NullInt64 { return gosql.NullInt64{Int64: n, Valid: true} }
+	cases := []struct {
+		name           string
+		source, target tableMetadata
+		superset       bool
+	}{
+		{"estimated row counts differ", tableMetadata{engine: "InnoDB", tableRows: rows(100)}, tableMetadata{engine: "InnoDB", tableRows: rows(90)}, false},
+		{"exact row counts match", tableMetadata{engine: "MyISAM", tableRows: rows(100)}, tableMetadata{engine: "MyISAM", tableRows: rows(100)}, false},
+		{"one side estimates", tableMetadata{engine: "MyISAM", tableRows: rows(100)}, tableMetadata{engine: "InnoDB", tableRows: rows(90)}, false},
+		{"unknown row count", tableMetadata{engine: "MyISAM"}, tableMetadata{engine: "MyISAM", tableRows: rows(90)}, false},
+		{"superset", tableMetadata{engine: "MyISAM", tableRows: rows(100)}, tableMetadata{engine: "MyISAM", tableRows: rows(90)}, true},
+		{"untracked update time", tableMetadata{engine: "InnoDB", updateTime: rows(1000)}, tableMetadata{engine: "InnoDB", updateTime: rows(1000)}, false},
+	}
+	for _, c := range cases {
+		ctx := NewChecksumContext(types.NewBaseContext(), types.NewTableContext("db", "t", "db", "t"))
+		ctx.Context.IsSuperSetAsEqual = c.superset
+		source, target := c.source, c.target
+		verdict, _, err := ctx.prefilterByMetadata(&source, &target)
+		if err != nil || verdict != NeedsChunkCheck {
+			t.Errorf("%s: got (%s, %v), want %s", c.name, verdict, err, NeedsChunkCheck)
+		}
+		if ctx.prefilterMethod != "" {
+			t.Errorf("%s: undecided table has prefilter method %q", c.name, ctx.prefilterMethod)
+		}
+	}
+}
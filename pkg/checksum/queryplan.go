@@ -0,0 +1,182 @@
+package checksum
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/builder"
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+// Chunk query plan guard: a chunk checksum query only reads its chunk when the optimizer ranges
+// over the chunk index. Like pt-table-checksum, the chunk queries FORCE INDEX: the chunk index on
+// the source, the first index starting with the unique key on the target. A target without such
+// an index, or an index MySQL cannot range over, still makes every chunk read the whole table.
+// The first time the chunk loop of a table runs a query shape (first chunk or later chunk,
+// aggregate or row-level), the query is EXPLAINed with the chunk's own bounds on both sides, and
+// the table is refused unless both plans range over the chunk index. --ignore-query-plan turns
+// the guard off.
+
+// queryPlanGuard remembers the query shapes of a table already EXPLAINed; the key-range workers
+// of a table share their parent's
+type queryPlanGuard struct {
+	mutex sync.Mutex
+	// checked maps a side and query text to the outcome of its EXPLAIN
+	checked map[string]error
+	// targetIndexName is the target index starting with the unique key, resolved on first use
+	targetIndexName *string
+}
+
+func newQueryPlanGuard() *queryPlanGuard {
+	return &queryPlanGuard{checked: map[string]error{}}
+}
+
+// resolveTargetIndexName returns the target index starting with the unique key, "" when there is
+// none, reading the target's indexes the first time; the caller holds the mutex
+func (guard *queryPlanGuard) resolveTargetIndexName(ctx *ChecksumContext) (string, error) {
+	if guard.targetIndexName == nil {
+		indexes, err := readIndexes(ctx.Context.TargetDB, ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName)
+		if err != nil {
+			return "", err
+		}
+		targetIndexName := ""
+		for _, index := range indexes {
+			if index.hasColumnPrefix(ctx.UniqueKey.Names()) {
+				targetIndexName = index.name
+				break
+			}
+		}
+		guard.targetIndexName = &targetIndexName
+	}
+	return *guard.targetIndexName, nil
+}
+
+// chunkIndexNames returns the indexes the chunk queries force on the source and on the target:
+// the chunk index, and the target index starting with the unique key, whose name may differ
+func (ctx *ChecksumContext) chunkIndexNames() (sourceIndexName, targetIndexName string, err error) {
+	if ctx.queryPlans == nil {
+		return ctx.UniqueIndexName, "", nil
+	}
+	ctx.queryPlans.mutex.Lock()
+	defer ctx.queryPlans.mutex.Unlock()
+	targetIndexName, err = ctx.queryPlans.resolveTargetIndexName(ctx)
+	return ctx.UniqueIndexName, targetIndexName, err
+}
+
+// explainRow is the access type and index of one table in an EXPLAIN plan
+type explainRow struct {
+	accessType string
+	key        string
+}
+
+// usesIndexRange reports whether every table of the plan is read by a range (or a single-row
+// const lookup) on indexName, returning the first row that is not
+func usesIndexRange(plan []explainRow, indexName string) (explainRow, bool) {
+	if len(plan) == 0 {
+		return explainRow{}, false
+	}
+	for _, row := range plan {
+		if !strings.EqualFold(row.key, indexName) || (row.accessType != "range" && row.accessType != "const") {
+			return row, false
+		}
+	}
+	return explainRow{}, true
+}
+
+// GuardQueryPlan EXPLAINs the chunk queries of the current chunk on the source and the target, once
+// per query shape of the table, and fails when a plan does not range over the chunk index
+func (ctx *ChecksumContext) GuardQueryPlan() error {
+	if ctx.Context.IgnoreQueryPlan || ctx.queryPlans == nil {
+		return nil
+	}
+	var checkLevel int64 = 1
+	if ctx.Context.IsSuperSetAsEqual {
+		checkLevel = 2
+	}
+	ctx.queryPlans.mutex.Lock()
+	defer ctx.queryPlans.mutex.Unlock()
+
+	targetIndexName, err := ctx.queryPlans.resolveTargetIndexName(ctx)
+	if err != nil {
+		return err
+	}
+
+	keyRange := ctx.CurrentKeyRange()
+	sides := []struct {
+		db                            dbQuerier
+		databaseName, tableName, side string
+		indexName                     string
+	}{
+		{ctx.sourceDB(), ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, "source", ctx.UniqueIndexName},
+		{ctx.targetDB(), ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, "target", targetIndexName},
+	}
+	for _, side := range sides {
+		query, explodedArgs, err := builder.BuildRangeChecksumPreparedQuery(side.databaseName, side.tableName, ctx.partition, ctx.CheckColumns, ctx.UniqueKey,
+			keyRange.Min.AbstractValues(), keyRange.Max.AbstractValues(), keyRange.IncludeMin, checkLevel, ctx.Context.HashFunction, side.indexName)
+		if err != nil {
+			return err
+		}
+		shape := side.side + query
+		if err, checked := ctx.queryPlans.checked[shape]; checked {
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		plan, err := explainQuery(side.db, query, explodedArgs)
+		if err != nil {
+			return fmt.Errorf("critical: EXPLAIN chunk query of table %s.%s failed: %v", side.databaseName, side.tableName, err)
+		}
+		if side.indexName == "" {
+			err = fmt.Errorf("critical: %s table %s.%s has no index starting with the unique key (%s); use --ignore-query-plan to check it anyway", side.side, side.databaseName, side.tableName, strings.Join(ctx.UniqueKey.Names(), ","))
+		} else if row, ok := usesIndexRange(plan, side.indexName); !ok {
+			err = fmt.Errorf("critical: chunk query of %s table %s.%s would not range over index %s (EXPLAIN type=%s, key=%s); use --ignore-query-plan to check it anyway", side.side, side.databaseName, side.tableName, side.indexName, row.accessType, row.key)
+		} else {
+			ctx.Context.Log.Debugf("Debug: chunk query of %s table %s.%s ranges over index %s", side.side, side.databaseName, side.tableName, side.indexName)
+		}
+		ctx.queryPlans.checked[shape] = err
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// explainQuery returns the plan MySQL picks for the query, one row per table it reads
+func explainQuery(db dbQuerier, query string, args []interface{}) (plan []explainRow, err error) {
+	rows, err := db.Query("EXPLAIN "+query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	columnIndex := map[string]int{}
+	for i, column := range columns {
+		columnIndex[strings.ToLower(column)] = i
+	}
+	for _, column := range []string{"type", "key"} {
+		if _, found := columnIndex[column]; !found {
+			return nil, fmt.Errorf("EXPLAIN output has no '%s' column", column)
+		}
+	}
+	values := types.NewColumnValues(len(columns))
+	for rows.Next() {
+		if err := rows.Scan(values.ValuesPointers...); err != nil {
+			return nil, err
+		}
+		row := explainRow{accessType: "NULL", key: "NULL"}
+		if values.AbstractValues()[columnIndex["type"]] != nil {
+			row.accessType = strings.ToLower(values.StringColumn(columnIndex["type"]))
+		}
+		if values.AbstractValues()[columnIndex["key"]] != nil {
+			row.key = values.StringColumn(columnIndex["key"])
+		}
+		plan = append(plan, row)
+	}
+	return plan, rows.Err()
+}
@@ -0,0 +1,35 @@
+package checksum
+
+import (
+	"testing"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+func TestUsesIndexRange(t *testing.T) {
+	tests := []struct {
+		name string
+		plan []explainRow
+		want bool
+	}{
+		{"range on chunk index", []explainRow{{accessType: "range", key: "PRIMARY"}}, true},
+		{"single-row chunk", []explainRow{{accessType: "const", key: "PRIMARY"}}, true},
+		{"full table scan", []explainRow{{accessType: "all", key: "NULL"}}, false},
+		{"full index scan", []explainRow{{accessType: "index", key: "PRIMARY"}}, false},
+		{"other index", []explainRow{{accessType: "range", key: "idx_created"}}, false},
+		{"no plan", nil, false},
+	}
+	for _, tt := range tests {
+		if _, got := usesIndexRange(tt.plan, "primary"); got != tt.want {
+			t.Errorf("%s: usesIndexRange = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRangeContextsShareQueryPlans(t *testing.T) {
+	ctx := NewChecksumContext(types.NewBaseContext(), types.NewTableContext("srcdb", "t1", "tgtdb", "t1"))
+	worker := ctx.NewRangeContext(KeyRange{Min: types.ToColumnValues([]interface{}{int64(1)}), Max: types.ToColumnValues([]interface{}{int64(100)}), IncludeMin: true})
+	if worker.queryPlans != ctx.queryPlans {
+		t.Error("key-range workers should share the table's EXPLAINed query shapes")
+	}
+}
@@ -0,0 +1,341 @@
+package checksum
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+// Sampling (--sample-percent, --sample-chunks): instead of every chunk, a random set of chunks of
+// the table is checked and the outcome is reported with the confidence it gives about the rest.
+// The table is laid out as a grid of population chunk slots, found without walking the key index
+// chunk by chunk. A single integer key splits its key space from minimum to maximum into one slot
+// per chunk size of source rows; a slot's chunk is chunk size rows from the slot's first key value,
+// cut at the next slot, so slots over gaps in the key are smaller or empty. Any other key uses a
+// chunk plan as grid: the planned chunks with --chunk-plan=scan, else the plan tracked by an
+// earlier run on the same table pair and hosts, stretched to the current key range, else one
+// scanned (and tracked for later runs) now. Each sampled chunk is tracked with its slot as chunk
+// number (the further pieces of a split oversized one past the grid), and a tracked run leaves out
+// the slots that earlier runs on the same hosts and grid have sampled, starting over once every
+// slot was sampled.
+
+// sampleConfidence is the confidence the sample report states its bound with
+const sampleConfidence = 0.99
+
+// chunkSample is the sampled chunks of a table in key order, their slots, and the chunk to check next
+type chunkSample struct {
+	chunks     []KeyRange
+	slots      []int64
+	population int64
+	chunkSize  int64
+	next       int
+	// slotNumbered is set once the current chunk, or the first piece of it, was numbered by its slot
+	slotNumbered bool
+}
+
+// sampleSize returns how many of population chunk slots to sample: sampleChunks, or else
+// samplePercent of them, at least one and at most all of them
+func sampleSize(population int64, samplePercent float64, sampleChunks int) int64 {
+	size := int64(sampleChunks)
+	if size <= 0 {
+		size = int64(math.Ceil(samplePercent / 100 * float64(population)))
+	}
+	if size < 1 {
+		size = 1
+	}
+	if size > population {
+		size = population
+	}
+	return size
+}
+
+// pickSampleSlots draws size distinct slots of [0, population) at random, none of them excluded,
+// and returns them sorted. When fewer than size slots are left, the exclusions are dropped.
+func pickSampleSlots(population, size int64, excluded map[int64]bool, rng *rand.Rand) (slots []int64, restarted bool) {
+	var remaining int64
+	for slot := range excluded {
+		if slot >= 0 && slot < population {
+			remaining++
+		}
+	}
+	if population-remaining < size {
+		excluded, restarted = nil, true
+	}
+	picked := map[int64]bool{}
+	if size*2 > population-int64(len(excluded)) {
+		// A large share of the slots: shuffle all candidates rather than drawing until enough are distinct
+		candidates := make([]int64, 0, population)
+		for slot := int64(0); slot < population; slot++ {
+			if !excluded[slot] {
+				candidates = append(candidates, slot)
+			}
+		}
+		rng.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+		slots = candidates[:size]
+	} else {
+		for int64(len(slots)) < size {
+			slot := rng.Int63n(population)
+			if excluded[slot] || picked[slot] {
+				continue
+			}
+			picked[slot] = true
+			slots = append(slots, slot)
+		}
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
+	return slots, restarted
+}
+
+// PlanSample picks the chunks of the table to check. Call it after the unique key range is read
+// and, with --chunk-plan=scan, the chunks are planned.
+func (ctx *ChecksumContext) PlanSample() (err error) {
+	column := ctx.UniqueKey.Columns()[0]
+	integerKey := ctx.UniqueKey.Len() == 1 && (column.Type == types.IntegerColumnType || column.Type == types.MediumIntColumnType)
+	if ctx.chunkPlan == nil && !integerKey && ctx.UniqueKeyRangeMinValues.AbstractValues()[0] != nil {
+		if chunks := ctx.loadTrackedChunkPlan(); chunks != nil {
+			ctx.chunkPlan = &chunkPlan{chunks: chunks, chunkSize: ctx.GetChunkSize()}
+			ctx.Context.Log.Debugf("Debug: Sampling table %s.%s from the %d chunk(s) of a tracked chunk plan", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, len(chunks))
+		} else if err = ctx.PlanChunks(); err != nil {
+			return err
+		}
+	}
+	chunkSize := ctx.GetChunkSize()
+	population := int64(1)
+	if ctx.chunkPlan != nil {
+		population = int64(len(ctx.chunkPlan.chunks))
+		chunkSize = ctx.chunkPlan.chunkSize
+	} else {
+		rowCount := ctx.SourceRowCount
+		if rowCount < 0 {
+			if rowCount, err = ctx.estimateSourceRows(); err != nil {
+				return err
+			}
+		}
+		if rowCount > chunkSize {
+			population = (rowCount + chunkSize - 1) / chunkSize
+		}
+	}
+	sample := &chunkSample{population: population, chunkSize: chunkSize}
+	ctx.sample = sample
+	if population == 0 || ctx.UniqueKeyRangeMinValues.AbstractValues()[0] == nil {
+		return nil
+	}
+
+	size := sampleSize(population, ctx.Context.SamplePercent, ctx.Context.SampleChunks)
+	slots, restarted := pickSampleSlots(population, size, ctx.sampledSlots(population), rand.New(rand.NewSource(time.Now().UnixNano())))
+	if restarted {
+		ctx.Context.Log.Infof("Earlier runs sampled (nearly) every chunk of table %s.%s; sampling from all %d chunks again.", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, population)
+	}
+	if ctx.chunkPlan != nil {
+		for _, slot := range slots {
+			sample.chunks = append(sample.chunks, ctx.chunkPlan.chunks[slot])
+			sample.slots = append(sample.slots, slot)
+		}
+		// The sampled chunks replace the plan in the chunk loop
+		ctx.chunkPlan = nil
+	} else if err = ctx.sampleIntegerSlots(sample, slots, column.IsUnsigned); err != nil {
+		return err
+	}
+	ctx.Context.Log.Infof("Sampling %d of %d chunk(s) of table %s.%s.", len(sample.chunks), population, ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName)
+	ctx.TrackSample()
+	return nil
+}
+
+// sampleIntegerSlots builds the chunks of the given slots of a single integer key: chunk size rows
+// from the slot's first key value, at most up to the last key value of the slot. Slots without
+// rows are left out.
+func (ctx *ChecksumContext) sampleIntegerSlots(sample *chunkSample, slots []int64, unsigned bool) error {
+	for _, slot := range slots {
+		start, end := integerSlotBounds(ctx.UniqueKeyRangeMinValues.StringColumn(0), ctx.UniqueKeyRangeMaxValues.StringColumn(0), unsigned, slot, sample.population)
+		if start == nil {
+			continue
+		}
+		chunkEnd, found, err := ctx.probeChunkEndWithin(start, end, true, sample.chunkSize, fmt.Sprintf("sample:%d", slot))
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+		sample.chunks = append(sample.chunks, KeyRange{Min: start, Max: chunkEnd, IncludeMin: true})
+		sample.slots = append(sample.slots, slot)
+	}
+	return nil
+}
+
+// integerSlotBounds returns the first and last key value of slot of population slots splitting
+// the integer key space from minValue to maxValue evenly, or nils when the slot holds no value
+func integerSlotBounds(minValue, maxValue string, unsigned bool, slot, population int64) (start, end *types.ColumnValues) {
+	lo, ok := new(big.Int).SetString(minValue, 10)
+	if !ok {
+		return nil, nil
+	}
+	hi, ok := new(big.Int).SetString(maxValue, 10)
+	if !ok {
+		return nil, nil
+	}
+	span := new(big.Int).Sub(hi, lo)
+	span.Add(span, big.NewInt(1))
+	slotStart := func(slot int64) *big.Int {
+		offset := new(big.Int).Mul(span, big.NewInt(slot))
+		offset.Quo(offset, big.NewInt(population))
+		return offset.Add(offset, lo)
+	}
+	first, last := slotStart(slot), slotStart(slot+1)
+	last.Sub(last, big.NewInt(1))
+	if last.Cmp(first) < 0 {
+		return nil, nil
+	}
+	if unsigned {
+		return types.ToColumnValues([]interface{}{first.Uint64()}), types.ToColumnValues([]interface{}{last.Uint64()})
+	}
+	return types.ToColumnValues([]interface{}{first.Int64()}), types.ToColumnValues([]interface{}{last.Int64()})
+}
+
+// loadTrackedChunkPlan returns the chunk plan an earlier tracked run stored for the table pair, its
+// first chunk starting at and its last one ending at the current key range, or nil when there is none
+func (ctx *ChecksumContext) loadTrackedChunkPlan() []KeyRange {
+	if ctx.JobTracker == nil {
+		return nil
+	}
+	stored, err := ctx.JobTracker.GetTableChunkPlan(ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName,
+		ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName)
+	if err != nil {
+		ctx.Context.Log.Warnf("tracking: read chunk plan of table %s.%s failed: %v", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, err)
+		return nil
+	}
+	if len(stored) == 0 {
+		return nil
+	}
+	chunks, err := ctx.decodeChunkPlan(stored)
+	if err != nil {
+		ctx.Context.Log.Warnf("tracking: cannot reuse the chunk plan of table %s.%s: %v", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, err)
+		return nil
+	}
+	chunks[0].Min, chunks[0].IncludeMin = ctx.UniqueKeyRangeMinValues, true
+	chunks[len(chunks)-1].Max = ctx.UniqueKeyRangeMaxValues
+	return chunks
+}
+
+// nextSampledChunk makes the next sampled chunk the current one, like CalculateNextIterationRangeEndValues
+func (ctx *ChecksumContext) nextSampledChunk() (hasFurtherRange bool, err error) {
+	if ctx.sample.next >= len(ctx.sample.chunks) {
+		ctx.Context.Log.Debugf("Debug: Iteration complete: no further sampled chunk of source table: %s.%s", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName)
+		return false, nil
+	}
+	chunk := ctx.sample.chunks[ctx.sample.next]
+	ctx.sample.next++
+	ctx.sample.slotNumbered = false
+	ctx.iterationIncludesMin = chunk.IncludeMin
+	ctx.ChecksumIterationRangeMinValues = chunk.Min
+	ctx.ChecksumIterationRangeMaxValues = chunk.Max
+	ctx.iterationChunkSize = ctx.sample.chunkSize
+	ctx.rowsEstimated += ctx.sample.chunkSize
+	return true, nil
+}
+
+// currentSlot returns the slot of the sampled chunk being checked
+func (sample *chunkSample) currentSlot() int64 {
+	if sample.next == 0 {
+		return 0
+	}
+	return sample.slots[sample.next-1]
+}
+
+// chunkNumber numbers a checked chunk of the sample: the sampled chunk by its slot, the further
+// pieces of it when it is split as oversized past the grid, by the table's chunk counter, so they
+// neither share the slot's number nor claim other slots
+func (sample *chunkSample) chunkNumber(counter int64) int64 {
+	if sample.slotNumbered {
+		return sample.population + counter
+	}
+	sample.slotNumbered = true
+	return sample.currentSlot()
+}
+
+// SampleSummary reports how many of the chunks checked so far differ and the share of all chunk
+// slots that, with sampleConfidence, differ at most; empty when the table is not sampled
+func (ctx *ChecksumContext) SampleSummary() string {
+	if ctx.sample == nil {
+		return ""
+	}
+	sampled := int64(ctx.sample.next)
+	different := int64(len(ctx.differentKeyRanges))
+	bound := sampleUpperBound(ctx.sample.population, sampled, different, 1-sampleConfidence)
+	return fmt.Sprintf("%d of %d sampled chunks differ; ≥%s%% confidence that <%s%% of chunks differ",
+		different, sampled, strconv.FormatFloat(100*sampleConfidence, 'f', -1, 64), formatPercentUp(100*float64(bound)/float64(ctx.sample.population)))
+}
+
+// sampleUpperBound returns the smallest number of different chunks among population for which
+// seeing at most different of sampled chunks (drawn without replacement) has a probability of
+// at most alpha: with confidence 1-alpha, fewer chunks than that differ
+func sampleUpperBound(population, sampled, different int64, alpha float64) int64 {
+	lo, hi := different+1, population
+	if lo > hi {
+		return population
+	}
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		if hypergeometricCDF(population, mid, sampled, different) <= alpha {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	return lo
+}
+
+// hypergeometricCDF returns the probability of drawing at most x of the k marked items when
+// drawing n of population items without replacement
+func hypergeometricCDF(population, k, n, x int64) float64 {
+	lnChoose := func(a, b int64) float64 {
+		if b < 0 || b > a {
+			return math.Inf(-1)
+		}
+		la, _ := math.Lgamma(float64(a + 1))
+		lb, _ := math.Lgamma(float64(b + 1))
+		lab, _ := math.Lgamma(float64(a - b + 1))
+		return la - lb - lab
+	}
+	total := lnChoose(population, n)
+	var cdf float64
+	for i := int64(0); i <= x; i++ {
+		cdf += math.Exp(lnChoose(k, i) + lnChoose(population-k, n-i) - total)
+	}
+	return math.Min(cdf, 1)
+}
+
+// formatPercentUp renders a percentage rounded up to two significant digits, so the stated bound
+// is never below the computed one
+func formatPercentUp(percent float64) string {
+	if percent <= 0 {
+		return "0"
+	}
+	scale := math.Pow(10, 1-math.Floor(math.Log10(percent)))
+	return strconv.FormatFloat(math.Ceil(percent*scale-1e-9)/scale, 'f', -1, 64)
+}
+
+// sampledSlots returns the slots of a grid of population chunks that earlier tracked runs have
+// sampled, nil without tracking
+func (ctx *ChecksumContext) sampledSlots(population int64) map[int64]bool {
+	if ctx.JobTracker == nil {
+		return nil
+	}
+	chunkNumbers, err := ctx.JobTracker.GetSampledChunkNumbers(ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName,
+		ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, population)
+	if err != nil {
+		ctx.Context.Log.Warnf("tracking: read sampled chunks of table %s.%s failed: %v", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, err)
+		return nil
+	}
+	slots := make(map[int64]bool, len(chunkNumbers))
+	for _, chunkNumber := range chunkNumbers {
+		slots[chunkNumber] = true
+	}
+	return slots
+}
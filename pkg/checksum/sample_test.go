@@ -0,0 +1,153 @@
+package checksum
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+func TestSampleSize(t *testing.T) {
+	tests := []struct {
+		population    int64
+		samplePercent float64
+		sampleChunks  int
+		want          int64
+	}{
+		{40000, 1, 0, 400},
+		{40000, 0, 250, 250},
+		{10, 0.1, 0, 1},
+		{10, 0, 50, 10},
+		{3, 50, 0, 2},
+	}
+	for _, tt := range tests {
+		if got := sampleSize(tt.population, tt.samplePercent, tt.sampleChunks); got != tt.want {
+			t.Errorf("sampleSize(%d, %g, %d) = %d, want %d", tt.population, tt.samplePercent, tt.sampleChunks, got, tt.want)
+		}
+	}
+}
+
+func TestPickSampleSlots(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	excluded := map[int64]bool{}
+	for slot := int64(0); slot < 900; slot++ {
+		excluded[slot] = true
+	}
+	for _, size := range []int64{10, 80} {
+		slots, restarted := pickSampleSlots(1000, size, excluded, rng)
+		if restarted || int64(len(slots)) != size {
+			t.Fatalf("pickSampleSlots(1000, %d): got %d slots, restarted=%t", size, len(slots), restarted)
+		}
+		for i, slot := range slots {
+			if excluded[slot] || slot >= 1000 || (i > 0 && slot <= slots[i-1]) {
+				t.Errorf("pickSampleSlots(1000, %d): slots %v should be sorted, distinct and not excluded", size, slots)
+				break
+			}
+		}
+	}
+
+	// Only 100 slots were never sampled: a sample of 200 starts over
+	slots, restarted := pickSampleSlots(1000, 200, excluded, rng)
+	if !restarted || len(slots) != 200 {
+		t.Errorf("pickSampleSlots(1000, 200): got %d slots, restarted=%t", len(slots), restarted)
+	}
+}
+
+func TestSampleUpperBound(t *testing.T) {
+	// Every chunk checked: the exact number of different chunks is known
+	if got := sampleUpperBound(50, 50, 3, 0.01); got != 4 {
+		t.Errorf("sampleUpperBound(50, 50, 3) = %d, want 4", got)
+	}
+	// 0 of 400 of 40000 chunks: about 4.6/400 of the chunks
+	got := sampleUpperBound(40000, 400, 0, 0.01)
+	if got < 440 || got > 470 {
+		t.Errorf("sampleUpperBound(40000, 400, 0) = %d, want about 455", got)
+	}
+	if different := sampleUpperBound(40000, 400, 4, 0.01); different <= got {
+		t.Errorf("sampleUpperBound should grow with the different chunks seen: %d <= %d", different, got)
+	}
+}
+
+func TestFormatPercentUp(t *testing.T) {
+	tests := map[float64]string{
+		1.1375: "1.2",
+		1.1:    "1.1",
+		0.0123: "0.013",
+		25.2:   "26",
+		100:    "100",
+		0:      "0",
+	}
+	for percent, want := range tests {
+		if got := formatPercentUp(percent); got != want {
+			t.Errorf("formatPercentUp(%g) = %q, want %q", percent, got, want)
+		}
+	}
+}
+
+// Slots split the key space evenly, however the rows spread over it
+func TestIntegerSlotBounds(t *testing.T) {
+	tests := []struct {
+		min, max   string
+		unsigned   bool
+		slot       int64
+		population int64
+		start, end string
+	}{
+		{"1", "1000", false, 3, 10, "301", "400"},
+		{"1", "1000000", false, 9, 10, "900001", "1000000"},
+		{"-5", "4", false, 1, 3, "-2", "0"},
+		{"0", "18446744073709551615", true, 1, 2, "9223372036854775808", "18446744073709551615"},
+		{"1", "2", false, 0, 4, "", ""},
+	}
+	for _, tt := range tests {
+		start, end := integerSlotBounds(tt.min, tt.max, tt.unsigned, tt.slot, tt.population)
+		if tt.start == "" {
+			if start != nil || end != nil {
+				t.Errorf("integerSlotBounds(%s, %s, slot %d of %d) should be empty", tt.min, tt.max, tt.slot, tt.population)
+			}
+			continue
+		}
+		if start == nil || start.String() != tt.start || end.String() != tt.end {
+			t.Errorf("integerSlotBounds(%s, %s, slot %d of %d) = %v, %v, want %s, %s", tt.min, tt.max, tt.slot, tt.population, start, end, tt.start, tt.end)
+		}
+	}
+}
+
+func TestSampledChunksAreNumberedBySlot(t *testing.T) {
+	ctx := NewChecksumContext(types.NewBaseContext(), types.NewTableContext("srcdb", "t1", "tgtdb", "t1"))
+	plan := testChunkPlan(1, 100, 200, 300)
+	ctx.sample = &chunkSample{chunks: []KeyRange{plan.chunks[0], plan.chunks[2]}, slots: []int64{0, 2}, population: 3, chunkSize: 100}
+
+	var chunkNumbers []int
+	for {
+		hasFurtherRange, err := ctx.CalculateNextIterationRangeEndValues()
+		if err != nil {
+			t.Fatalf("CalculateNextIterationRangeEndValues: %v", err)
+		}
+		if !hasFurtherRange {
+			break
+		}
+		chunkNumbers = append(chunkNumbers, ctx.NextChunkNumber())
+	}
+	if len(chunkNumbers) != 2 || chunkNumbers[0] != 0 || chunkNumbers[1] != 2 {
+		t.Errorf("sampled chunks numbered %v, want [0 2]", chunkNumbers)
+	}
+
+	// The pieces of a split sampled chunk: the first keeps the slot, the others go past the grid.
+	// A chunk split as oversized is not numbered itself.
+	ctx.sample.slotNumbered = false
+	ctx.pendingChunks = keyRangesBetween(plan.chunks[2], []*types.ColumnValues{types.ToColumnValues([]interface{}{int64(250)})})
+	var pieceNumbers []int
+	for len(ctx.pendingChunks) > 0 {
+		if _, err := ctx.CalculateNextIterationRangeEndValues(); err != nil {
+			t.Fatalf("CalculateNextIterationRangeEndValues: %v", err)
+		}
+		pieceNumbers = append(pieceNumbers, ctx.NextChunkNumber())
+	}
+	if len(pieceNumbers) != 2 || pieceNumbers[0] != 2 || pieceNumbers[1] < 3 {
+		t.Errorf("pieces of sampled slot 2 numbered %v, want slot 2 then a number past the grid of 3", pieceNumbers)
+	}
+	if summary := ctx.SampleSummary(); summary == "" {
+		t.Error("a sampled table should have a sample summary")
+	}
+}
@@ -0,0 +1,294 @@
+package checksum
+
+import (
+	"context"
+	gosql "database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Consistent snapshots (--consistent-snapshot): each table is checked through one
+// pinned connection per side. The target's applier is stopped first, then the source
+// opens START TRANSACTION WITH CONSISTENT SNAPSHOT at a known binlog position; the
+// target's applier runs until exactly the source's GTID set, the target opens its own
+// snapshot there and its applier is started again. Both sides are read as of the same
+// point of the replication stream while writes continue.
+
+// snapshotAttempts bounds how often the source snapshot is retaken when commits
+// keep landing while it opens.
+const snapshotAttempts = 5
+
+// targetApplierMu serializes the tables stopping the target's applier at their source GTID set
+var targetApplierMu sync.Mutex
+
+// dbQuerier is what the data queries of a table need: the connection pool, or the
+// pinned snapshot connection in --consistent-snapshot mode.
+type dbQuerier interface {
+	Query(query string, args ...interface{}) (*gosql.Rows, error)
+	QueryRow(query string, args ...interface{}) *gosql.Row
+}
+
+// snapshotConn runs queries on a pinned connection holding a snapshot transaction
+type snapshotConn struct {
+	conn *gosql.Conn
+}
+
+func (c snapshotConn) Query(query string, args ...interface{}) (*gosql.Rows, error) {
+	return c.conn.QueryContext(context.Background(), query, args...)
+}
+
+func (c snapshotConn) QueryRow(query string, args ...interface{}) *gosql.Row {
+	return c.conn.QueryRowContext(context.Background(), query, args...)
+}
+
+// sourceDB returns where the data queries of the source table run
+func (ctx *ChecksumContext) sourceDB() dbQuerier {
+	if ctx.sourceSnapshot != nil {
+		return snapshotConn{conn: ctx.sourceSnapshot}
+	}
+	return ctx.Context.SourceDB
+}
+
+// targetDB returns where the data queries of the target table run
+func (ctx *ChecksumContext) targetDB() dbQuerier {
+	if ctx.targetSnapshot != nil {
+		return snapshotConn{conn: ctx.targetSnapshot}
+	}
+	return ctx.Context.TargetDB
+}
+
+// binlogStatus reads the binary log coordinates (file:position) and the executed GTID set of the server behind conn
+func binlogStatus(conn *gosql.Conn) (position, gtidSet string, err error) {
+	rows, err := conn.QueryContext(context.Background(), "SHOW MASTER STATUS")
+	if err != nil {
+		// MySQL 8.4 removed SHOW MASTER STATUS
+		if rows, err = conn.QueryContext(context.Background(), "SHOW BINARY LOG STATUS"); err != nil {
+			return "", "", err
+		}
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", "", err
+	}
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return "", "", err
+		}
+		return "", "", fmt.Errorf("critical: binary logging is disabled")
+	}
+	values := make([]gosql.NullString, len(columns))
+	valuePointers := make([]interface{}, len(columns))
+	for i := range values {
+		valuePointers[i] = &values[i]
+	}
+	if err = rows.Scan(valuePointers...); err != nil {
+		return "", "", err
+	}
+	if position, gtidSet, err = parseBinlogStatus(columns, values); err != nil {
+		return "", "", err
+	}
+	return position, gtidSet, rows.Err()
+}
+
+// parseBinlogStatus picks the binlog coordinates (file:position) and the executed GTID set out of
+// a binary log status row. The GTID set is empty without GTIDs, or on servers that do not list it
+// (MariaDB); MySQL breaks long sets over lines, which are joined again.
+func parseBinlogStatus(columns []string, values []gosql.NullString) (position, gtidSet string, err error) {
+	var file, offset string
+	for i, column := range columns {
+		switch column {
+		case "File":
+			file = values[i].String
+		case "Position":
+			offset = values[i].String
+		case "Executed_Gtid_Set":
+			gtidSet = strings.ReplaceAll(values[i].String, "\n", "")
+		}
+	}
+	if file == "" || offset == "" {
+		return "", "", fmt.Errorf("binary log status has no File and Position")
+	}
+	return file + ":" + offset, gtidSet, nil
+}
+
+// execReplicaStatement runs a replication statement on conn, falling back to the SLAVE syntax of
+// servers before MySQL 8.0.22
+func execReplicaStatement(conn *gosql.Conn, statement string) error {
+	if _, err := conn.ExecContext(context.Background(), statement); err != nil {
+		if _, legacyErr := conn.ExecContext(context.Background(), strings.Replace(statement, "REPLICA", "SLAVE", 1)); legacyErr != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applierRunning reports whether the replication applier of the server behind conn is running
+func applierRunning(conn *gosql.Conn) (bool, error) {
+	var running int
+	err := conn.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM performance_schema.replication_applier_status WHERE SERVICE_STATE = 'ON'").Scan(&running)
+	return running > 0, err
+}
+
+// executedGTIDSet reads the GTID set executed by the server behind conn
+func executedGTIDSet(conn *gosql.Conn) (string, error) {
+	var gtidSet string
+	err := conn.QueryRowContext(context.Background(), "SELECT @@GLOBAL.gtid_executed").Scan(&gtidSet)
+	return strings.ReplaceAll(gtidSet, "\n", ""), err
+}
+
+// startSnapshot opens a consistent-snapshot transaction on conn
+func startSnapshot(conn *gosql.Conn) error {
+	for _, stmt := range []string{
+		"SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ",
+		"START TRANSACTION WITH CONSISTENT SNAPSHOT",
+	} {
+		if _, err := conn.ExecContext(context.Background(), stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OpenSnapshot pins the source and target connections of the table and opens their
+// snapshots; it does nothing unless --consistent-snapshot is set. The source
+// coordinates are read before and after the snapshot opens, and the snapshot is
+// retaken until they agree, so the recorded position is the snapshot's own. The
+// target's applier, when running, is held at the source's GTID set while the
+// target's snapshot opens.
+func (ctx *ChecksumContext) OpenSnapshot() (err error) {
+	if !ctx.Context.ConsistentSnapshot {
+		return nil
+	}
+	sourceConn, err := ctx.Context.SourceDB.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	targetConn, err := ctx.Context.TargetDB.Conn(context.Background())
+	if err != nil {
+		sourceConn.Close()
+		return err
+	}
+	defer func() {
+		if err != nil {
+			closeSnapshot(sourceConn)
+			closeSnapshot(targetConn)
+		}
+	}()
+
+	// Stop the target's applier before the source snapshot opens, so the target cannot apply
+	// past the source's GTID set; it is started again once the target's snapshot is open
+	targetApplierMu.Lock()
+	defer targetApplierMu.Unlock()
+	isApplierRunning, err := applierRunning(targetConn)
+	if err != nil {
+		return fmt.Errorf("critical: read applier status of target for table %s.%s failed: %v", ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, err)
+	}
+	if isApplierRunning {
+		if err = execReplicaStatement(targetConn, "STOP REPLICA SQL_THREAD"); err != nil {
+			return fmt.Errorf("critical: stop the applier of target for table %s.%s failed: %v", ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, err)
+		}
+		defer func() {
+			if startErr := execReplicaStatement(targetConn, "START REPLICA SQL_THREAD"); startErr != nil {
+				ctx.Context.Log.Errorf("Failed to start the applier of target again after the snapshot of table %s.%s: %v", ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, startErr)
+			}
+		}()
+	}
+
+	var position, gtidSet string
+	for attempt := 1; ; attempt++ {
+		positionBefore, _, err := binlogStatus(sourceConn)
+		if err != nil {
+			return fmt.Errorf("critical: read binlog position of source for table %s.%s failed: %v", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, err)
+		}
+		if err = startSnapshot(sourceConn); err != nil {
+			return err
+		}
+		if position, gtidSet, err = binlogStatus(sourceConn); err != nil {
+			return err
+		}
+		if position == positionBefore {
+			break
+		}
+		if _, err = sourceConn.ExecContext(context.Background(), "ROLLBACK"); err != nil {
+			return err
+		}
+		if attempt == snapshotAttempts {
+			return fmt.Errorf("critical: source binlog position of table %s.%s kept moving while opening a snapshot, gave up after %d attempts", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, attempt)
+		}
+	}
+	if gtidSet == "" {
+		return fmt.Errorf("critical: consistent snapshot needs gtid_mode=ON on the source to wait for the target")
+	}
+
+	timeoutSeconds := int64(ctx.Context.SnapshotWaitTimeout / time.Second)
+	if timeoutSeconds < 1 {
+		timeoutSeconds = 1
+	}
+	startTime := time.Now()
+	if isApplierRunning {
+		// The GTID set comes from the source's binary log status: UUIDs, intervals, ':' and ','
+		if err = execReplicaStatement(targetConn, fmt.Sprintf("START REPLICA SQL_THREAD UNTIL SQL_AFTER_GTIDS = '%s'", gtidSet)); err != nil {
+			return fmt.Errorf("critical: run the applier of target up to source GTID set %s for table %s.%s failed: %v", gtidSet, ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, err)
+		}
+	}
+	var waitResult gosql.NullInt64
+	if err = targetConn.QueryRowContext(context.Background(), "SELECT WAIT_FOR_EXECUTED_GTID_SET(?, ?)", gtidSet, timeoutSeconds).Scan(&waitResult); err != nil {
+		return err
+	}
+	if !waitResult.Valid || waitResult.Int64 != 0 {
+		return fmt.Errorf("critical: target did not reach source GTID set %s of table %s.%s within %s", gtidSet, ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, ctx.Context.SnapshotWaitTimeout)
+	}
+	// The target's snapshot must open at the GTID set it was held at: nothing may be applied meanwhile
+	targetGTIDSet, err := executedGTIDSet(targetConn)
+	if err != nil {
+		return err
+	}
+	if err = startSnapshot(targetConn); err != nil {
+		return err
+	}
+	targetGTIDSetAfter, err := executedGTIDSet(targetConn)
+	if err != nil {
+		return err
+	}
+	if targetGTIDSetAfter != targetGTIDSet {
+		return fmt.Errorf("critical: target applied transactions while the snapshot of table %s.%s opened (GTID set %s, then %s)", ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, targetGTIDSet, targetGTIDSetAfter)
+	}
+
+	ctx.sourceSnapshot, ctx.targetSnapshot = sourceConn, targetConn
+	ctx.SnapshotBinlogPosition, ctx.SnapshotGTIDSet = position, gtidSet
+	ctx.Context.Log.Infof("Consistent snapshot of table pair: %s.%s => %s.%s at source binlog position %s, GTID set %s (target caught up in %s).",
+		ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName,
+		position, gtidSet, time.Since(startTime))
+	ctx.TrackSnapshot()
+	return nil
+}
+
+// RefreshSnapshot replaces the snapshots of the table with new ones. Retrying a chunk
+// within the same snapshots would read the same rows again.
+func (ctx *ChecksumContext) RefreshSnapshot() error {
+	if ctx.sourceSnapshot == nil {
+		return nil
+	}
+	ctx.CloseSnapshot()
+	return ctx.OpenSnapshot()
+}
+
+// CloseSnapshot ends the snapshot transactions and releases the pinned connections
+func (ctx *ChecksumContext) CloseSnapshot() {
+	closeSnapshot(ctx.sourceSnapshot)
+	closeSnapshot(ctx.targetSnapshot)
+	ctx.sourceSnapshot, ctx.targetSnapshot = nil, nil
+}
+
+func closeSnapshot(conn *gosql.Conn) {
+	if conn == nil {
+		return
+	}
+	// The snapshot transactions only read; ROLLBACK just ends them
+	conn.ExecContext(context.Background(), "ROLLBACK")
+	conn.Close()
+}
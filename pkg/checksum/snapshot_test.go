@@ -0,0 +1,39 @@
+package checksum
+
+import (
+	gosql "database/sql"
+	"testing"
+)
+
+func TestParseBinlogStatus(t *testing.T) {
+	row := func(values ...string) []gosql.NullString {
+		row := make([]gosql.NullString, len(values))
+		for i, value := range values {
+			row[i] = gosql.NullString{String: value, Valid: true}
+		}
+		return row
+	}
+	mysqlColumns := []string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set"}
+	tests := []struct {
+		name              string
+		columns           []string
+		values            []gosql.NullString
+		position, gtidSet string
+	}{
+		{"single GTID set", mysqlColumns, row("binlog.000042", "1337", "", "", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-77"),
+			"binlog.000042:1337", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-77"},
+		{"GTID set over lines", mysqlColumns, row("binlog.000042", "1337", "", "", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-77,\n4c2a9b10-71ca-11e1-9e33-c80aa9429562:1-5"),
+			"binlog.000042:1337", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-77,4c2a9b10-71ca-11e1-9e33-c80aa9429562:1-5"},
+		{"gtid_mode=OFF", mysqlColumns, row("mysql-bin.000003", "154", "", "", ""), "mysql-bin.000003:154", ""},
+		{"no Executed_Gtid_Set column", mysqlColumns[:4], row("mariadb-bin.000001", "328", "", ""), "mariadb-bin.000001:328", ""},
+	}
+	for _, tt := range tests {
+		position, gtidSet, err := parseBinlogStatus(tt.columns, tt.values)
+		if err != nil || position != tt.position || gtidSet != tt.gtidSet {
+			t.Errorf("%s: parseBinlogStatus = %q, %q, %v; want %q, %q", tt.name, position, gtidSet, err, tt.position, tt.gtidSet)
+		}
+	}
+	if _, _, err := parseBinlogStatus([]string{"Executed_Gtid_Set"}, row("")); err == nil {
+		t.Error("a status row without File and Position should be an error")
+	}
+}
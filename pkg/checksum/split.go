@@ -0,0 +1,176 @@
+package checksum
+
+import (
+	gosql "database/sql"
+	"math/big"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+// Intra-table parallelism (--table-threads): the key range of a table is split into
+// consecutive sub-ranges, each checked by its own worker context. The workers share
+// the table's chunk counter, so tracked chunk numbers stay unique, and keep their
+// own results until MergeRangeContexts folds them back into the table's context.
+
+// SplitKeyRange divides [UniqueKeyRangeMinValues, UniqueKeyRangeMaxValues] into at most n
+// consecutive key ranges, never smaller than one chunk of source rows. A single integer key
+// is split arithmetically; any other key at every (rows/n)-th source row. Planned chunks (see
+// chunkplan.go) are split into runs of whole chunks. A single range is returned when the table is
+// too small or empty.
+func (ctx *ChecksumContext) SplitKeyRange(n int) (ranges []KeyRange, err error) {
+	fullRange := KeyRange{Min: ctx.UniqueKeyRangeMinValues, Max: ctx.UniqueKeyRangeMaxValues, IncludeMin: true}
+	if n < 2 || ctx.UniqueKeyRangeMinValues.AbstractValues()[0] == nil {
+		return []KeyRange{fullRange}, nil
+	}
+	if ctx.chunkPlan != nil {
+		if ranges = ctx.chunkPlan.split(n); len(ranges) < 2 {
+			return []KeyRange{fullRange}, nil
+		}
+		ctx.Context.Log.Debugf("Debug: Split the %d planned chunks of table %s.%s into %d range(s)", len(ctx.chunkPlan.chunks), ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, len(ranges))
+		return ranges, nil
+	}
+
+	rowCount := ctx.SourceRowCount
+	if rowCount < 0 {
+		if rowCount, err = ctx.estimateSourceRows(); err != nil {
+			return nil, err
+		}
+	}
+	if maxRanges := rowCount / ctx.GetChunkSize(); int64(n) > maxRanges {
+		n = int(maxRanges)
+	}
+	if n < 2 {
+		return []KeyRange{fullRange}, nil
+	}
+
+	var bounds []*types.ColumnValues
+	if column := ctx.UniqueKey.Columns()[0]; ctx.UniqueKey.Len() == 1 && (column.Type == types.IntegerColumnType || column.Type == types.MediumIntColumnType) {
+		bounds = splitIntegerRange(ctx.UniqueKeyRangeMinValues.StringColumn(0), ctx.UniqueKeyRangeMaxValues.StringColumn(0), column.IsUnsigned, n)
+	} else if bounds, err = ctx.splitRangeByRows(fullRange, rowCount/int64(n), n); err != nil {
+		return nil, err
+	}
+	ctx.Context.Log.Debugf("Debug: Split key range %s of table %s.%s into %d range(s)", fullRange, ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, len(bounds)+1)
+	return keyRangesBetween(fullRange, bounds), nil
+}
+
+// estimateSourceRows reads the optimizer's row estimate of the source table, or of its partition
+func (ctx *ChecksumContext) estimateSourceRows() (rowCount int64, err error) {
+	var tableRows gosql.NullInt64
+	if ctx.partition != "" {
+		err = ctx.Context.SourceDB.QueryRow(`
+    select SUM(TABLE_ROWS)
+      from information_schema.PARTITIONS
+     where TABLE_SCHEMA = ? and TABLE_NAME = ? and PARTITION_NAME = ?
+  `, ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, ctx.partition).Scan(&tableRows)
+		return tableRows.Int64, err
+	}
+	err = ctx.Context.SourceDB.QueryRow(`
+    select TABLE_ROWS
+      from information_schema.tables
+     where table_schema = ? and table_name = ?
+  `, ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName).Scan(&tableRows)
+	return tableRows.Int64, err
+}
+
+// splitIntegerRange returns up to n-1 evenly spaced bounds strictly between min and max
+func splitIntegerRange(minValue, maxValue string, unsigned bool, n int) (bounds []*types.ColumnValues) {
+	lo, ok := new(big.Int).SetString(minValue, 10)
+	if !ok {
+		return nil
+	}
+	hi, ok := new(big.Int).SetString(maxValue, 10)
+	if !ok {
+		return nil
+	}
+	span := new(big.Int).Sub(hi, lo)
+	previous := lo
+	for k := 1; k < n; k++ {
+		bound := new(big.Int).Mul(span, big.NewInt(int64(k)))
+		bound.Quo(bound, big.NewInt(int64(n))).Add(bound, lo)
+		if bound.Cmp(previous) <= 0 || bound.Cmp(hi) >= 0 {
+			continue
+		}
+		previous = bound
+		if unsigned {
+			bounds = append(bounds, types.ToColumnValues([]interface{}{bound.Uint64()}))
+		} else {
+			bounds = append(bounds, types.ToColumnValues([]interface{}{bound.Int64()}))
+		}
+	}
+	return bounds
+}
+
+// splitRangeByRows returns the keys of every step-th source row of keyRange, at most n-1 of them
+func (ctx *ChecksumContext) splitRangeByRows(keyRange KeyRange, step int64, n int) (bounds []*types.ColumnValues, err error) {
+	start, includeStart := keyRange.Min, keyRange.IncludeMin
+	for k := 1; k < n; k++ {
+		bound, found, err := ctx.probeRangeEnd(false, start, keyRange.Max, includeStart, step, "split")
+		if err != nil {
+			return nil, err
+		}
+		// The estimate was too high: the remaining rows all go to the last range
+		if !found {
+			break
+		}
+		bounds = append(bounds, bound)
+		start, includeStart = bound, false
+	}
+	return bounds, nil
+}
+
+// keyRangesBetween cuts fullRange at the given ascending bounds
+func keyRangesBetween(fullRange KeyRange, bounds []*types.ColumnValues) (ranges []KeyRange) {
+	keyRange := KeyRange{Min: fullRange.Min, IncludeMin: fullRange.IncludeMin}
+	for _, bound := range bounds {
+		keyRange.Max = bound
+		ranges = append(ranges, keyRange)
+		keyRange = KeyRange{Min: bound}
+	}
+	keyRange.Max = fullRange.Max
+	return append(ranges, keyRange)
+}
+
+// NewRangeContext returns a worker context checking only keyRange of the table
+func (ctx *ChecksumContext) NewRangeContext(keyRange KeyRange) *ChecksumContext {
+	perTableContext := *ctx.PerTableContext
+	perTableContext.DifferentChunkRanges = nil
+	perTableContext.DifferentColumns = nil
+	worker := NewChecksumContext(ctx.Context, &perTableContext)
+	worker.CheckColumns = ctx.CheckColumns
+	worker.UniqueKey = ctx.UniqueKey
+	worker.UniqueIndexName = ctx.UniqueIndexName
+	worker.partition = ctx.partition
+	worker.UniqueKeyRangeMinValues = keyRange.Min
+	worker.UniqueKeyRangeMaxValues = keyRange.Max
+	worker.rangeStartExclusive = !keyRange.IncludeMin
+	if ctx.chunkPlan != nil {
+		worker.chunkPlan = ctx.chunkPlan.within(keyRange)
+	}
+	worker.chunkCounter = ctx.iterationCounter()
+	worker.JobTracker = ctx.JobTracker
+	worker.ComparisonID = ctx.ComparisonID
+	worker.Throttler = ctx.Throttler
+	worker.queryPlans = ctx.queryPlans
+	return worker
+}
+
+// MergeRangeContexts folds the chunk tallies, skipped chunks, different chunks and columns, row estimates and
+// throttled time of the workers, given in key order, into the table's context
+func (ctx *ChecksumContext) MergeRangeContexts(workers []*ChecksumContext) {
+	for _, worker := range workers {
+		ctx.chunksEqual += worker.chunksEqual
+		ctx.chunksDifferent += worker.chunksDifferent
+		ctx.chunksError += worker.chunksError
+		ctx.chunksSkipped += worker.chunksSkipped
+		ctx.rowsEstimated += worker.rowsEstimated
+		ctx.throttledTime += worker.throttledTime
+		ctx.differentKeyRanges = append(ctx.differentKeyRanges, worker.differentKeyRanges...)
+		ctx.PerTableContext.DifferentChunkRanges = append(ctx.PerTableContext.DifferentChunkRanges, worker.PerTableContext.DifferentChunkRanges...)
+		for column, chunks := range worker.PerTableContext.DifferentColumns {
+			if ctx.PerTableContext.DifferentColumns == nil {
+				ctx.PerTableContext.DifferentColumns = map[string]int{}
+			}
+			ctx.PerTableContext.DifferentColumns[column] += chunks
+		}
+	}
+}
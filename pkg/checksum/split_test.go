@@ -0,0 +1,89 @@
+package checksum
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+func boundValues(bounds []*types.ColumnValues) (values []interface{}) {
+	for _, bound := range bounds {
+		values = append(values, bound.AbstractValues()[0])
+	}
+	return values
+}
+
+func TestSplitIntegerRange(t *testing.T) {
+	if got, want := boundValues(splitIntegerRange("1", "1000", false, 4)), []interface{}{int64(250), int64(500), int64(750)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bounds = %v, want %v", got, want)
+	}
+	// Narrow ranges yield fewer, distinct bounds
+	if got, want := boundValues(splitIntegerRange("1", "3", false, 8)), []interface{}{int64(2)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bounds = %v, want %v", got, want)
+	}
+	// Unsigned keys past the int64 range
+	if got, want := boundValues(splitIntegerRange("0", "18446744073709551614", true, 2)), []interface{}{uint64(9223372036854775807)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bounds = %v, want %v", got, want)
+	}
+	if bounds := splitIntegerRange("<nil>", "10", false, 2); bounds != nil {
+		t.Errorf("unparseable minimum should not split, got %v", boundValues(bounds))
+	}
+}
+
+func TestKeyRangesBetween(t *testing.T) {
+	fullRange := KeyRange{
+		Min:        types.ToColumnValues([]interface{}{int64(1)}),
+		Max:        types.ToColumnValues([]interface{}{int64(1000)}),
+		IncludeMin: true,
+	}
+	bounds := splitIntegerRange("1", "1000", false, 3)
+	var got []string
+	for _, r := range keyRangesBetween(fullRange, bounds) {
+		got = append(got, r.String())
+	}
+	if want := []string{"[1, 334]", "(334, 667]", "(667, 1000]"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ranges = %v, want %v", got, want)
+	}
+	if ranges := keyRangesBetween(fullRange, nil); len(ranges) != 1 || ranges[0] != fullRange {
+		t.Errorf("no bounds should keep the full range, got %v", ranges)
+	}
+}
+
+func TestRangeContextsShareChunkNumbers(t *testing.T) {
+	ctx := NewChecksumContext(types.NewBaseContext(), types.NewTableContext("srcdb", "t1", "tgtdb", "t1"))
+	ranges := keyRangesBetween(KeyRange{
+		Min:        types.ToColumnValues([]interface{}{int64(1)}),
+		Max:        types.ToColumnValues([]interface{}{int64(1000)}),
+		IncludeMin: true,
+	}, splitIntegerRange("1", "1000", false, 2))
+	workers := []*ChecksumContext{ctx.NewRangeContext(ranges[0]), ctx.NewRangeContext(ranges[1])}
+
+	if n := workers[0].NextChunkNumber(); n != 0 {
+		t.Errorf("first chunk number = %d, want 0", n)
+	}
+	if n := workers[1].NextChunkNumber(); n != 1 {
+		t.Errorf("second worker's chunk number = %d, want 1", n)
+	}
+	if ctx.GetIteration() != 2 {
+		t.Errorf("table iteration = %d, want 2", ctx.GetIteration())
+	}
+
+	// The second worker finds its first chunk different
+	workers[1].iterationIncludesMin = workers[1].nextChunkIncludesMin()
+	workers[1].ChecksumIterationRangeMinValues = ranges[1].Min
+	workers[1].ChecksumIterationRangeMaxValues = ranges[1].Max
+	workers[0].TrackChunk(0, true, nil, 0)
+	workers[1].TrackChunk(1, false, nil, 0)
+	if len(ctx.PerTableContext.DifferentChunkRanges) != 0 {
+		t.Error("workers must not touch the table's chunk list before the merge")
+	}
+
+	ctx.MergeRangeContexts(workers)
+	if ranges := ctx.DifferentKeyRanges(); len(ranges) != 1 || ranges[0].IncludeMin {
+		t.Errorf("DifferentKeyRanges = %v", ranges)
+	}
+	if want := []string{"(500, 1000]"}; !reflect.DeepEqual(ctx.PerTableContext.DifferentChunkRanges, want) {
+		t.Errorf("DifferentChunkRanges = %v, want %v", ctx.PerTableContext.DifferentChunkRanges, want)
+	}
+}
@@ -1,7 +1,6 @@
 package checksum
 
 import (
-	gosql "database/sql"
 	"fmt"
 	"reflect"
 	"time"
@@ -127,14 +126,14 @@ func (ctx *ChecksumContext) IterationTimeRangeQueryChecksum() (isChunkChecksumEq
 		duration = time.Since(startTime)
 	}()
 
-	// checkLevel 1 = aggregated CRC32XOR, 2 = per-row CRC32 values
+	// checkLevel 1 = row count, CRC32XOR and hash sum, 2 = per-row CRC32 values
 	var checkLevel int64 = 1
 	if ctx.Context.IsSuperSetAsEqual {
 		checkLevel = 2
 	}
 
-	go ctx.queryTimeRangeChecksumFunc(ctx.Context.SourceDB, ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, checkLevel, ctx.SourceResultQueue)
-	go ctx.queryTimeRangeChecksumFunc(ctx.Context.TargetDB, ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, checkLevel, ctx.TargetResultQueue)
+	go ctx.queryTimeRangeChecksumFunc(ctx.sourceDB(), ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, checkLevel, ctx.SourceResultQueue)
+	go ctx.queryTimeRangeChecksumFunc(ctx.targetDB(), ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName, checkLevel, ctx.TargetResultQueue)
 	sourceResultStruct, targetResultStruct := <-ctx.SourceResultQueue, <-ctx.TargetResultQueue
 	if sourceResultStruct.err != nil {
 		return false, duration, sourceResultStruct.err
@@ -143,8 +142,8 @@ func (ctx *ChecksumContext) IterationTimeRangeQueryChecksum() (isChunkChecksumEq
 		return false, duration, targetResultStruct.err
 	}
 	sourceResult, targetResult := sourceResultStruct.result, targetResultStruct.result
-	ctx.lastSourceChecksum = checksumSummary(sourceResult)
-	ctx.lastTargetChecksum = checksumSummary(targetResult)
+	ctx.lastSourceChecksum = checksumSummary(sourceResult, checkLevel)
+	ctx.lastTargetChecksum = checksumSummary(targetResult, checkLevel)
 
 	if reflect.DeepEqual(sourceResult, targetResult) {
 		return true, duration, nil
@@ -156,7 +155,7 @@ func (ctx *ChecksumContext) IterationTimeRangeQueryChecksum() (isChunkChecksumEq
 }
 
 // queryTimeRangeChecksumFunc fetches the checksum result for the current time chunk (aggregated CRC32XOR or per-row CRC32)
-func (ctx *ChecksumContext) queryTimeRangeChecksumFunc(db *gosql.DB, databaseName, tableName string, checkLevel int64, ch chan *crc32ResultStruct) {
+func (ctx *ChecksumContext) queryTimeRangeChecksumFunc(db dbQuerier, databaseName, tableName string, checkLevel int64, ch chan *crc32ResultStruct) {
 	var ret []string
 	query, err := builder.BuildTimeRangeChecksumSQL(
 		databaseName,
@@ -165,6 +164,7 @@ func (ctx *ChecksumContext) queryTimeRangeChecksumFunc(db *gosql.DB, databaseNam
 		ctx.Context.SpecifiedDatetimeColumn,
 		ctx.isFinalTimeChunk(),
 		checkLevel,
+		ctx.Context.HashFunction,
 	)
 	if err != nil {
 		ch <- newCrc32ResultStruct(ret, err)
@@ -177,19 +177,8 @@ func (ctx *ChecksumContext) queryTimeRangeChecksumFunc(db *gosql.DB, databaseNam
 		return
 	}
 	defer rows.Close()
-	for rows.Next() {
-		rowValues := types.NewColumnValues(1)
-		if err := rows.Scan(rowValues.ValuesPointers...); err != nil {
-			ch <- newCrc32ResultStruct(ret, err)
-			return
-		}
-		ret = append(ret, rowValues.StringColumn(0))
-	}
-	if err = rows.Err(); err != nil {
-		ch <- newCrc32ResultStruct(ret, err)
-		return
-	}
-	ch <- newCrc32ResultStruct(ret, nil)
+	ret, err = scanChecksumRows(rows)
+	ch <- newCrc32ResultStruct(ret, err)
 }
 
 // isOrderedSubset reports whether subset is contained in superset, respecting order
@@ -1,9 +1,12 @@
 package checksum
 
 import (
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/ChaosHour/go-data-checksum/pkg/resume"
 	"github.com/ChaosHour/go-data-checksum/pkg/tracking"
 	"github.com/ChaosHour/go-data-checksum/pkg/types"
 )
@@ -11,28 +14,41 @@ import (
 // Tracking hooks: every method is a no-op when JobTracker is nil, and tracker
 // errors are logged as warnings — tracking must never fail a checksum run.
 
-// checksumSummary condenses a chunk checksum result into a value that fits the
-// VARCHAR(64) checksum columns. The aggregated-CRC32 case (checkLevel 1) is a
-// single element; per-row results (checkLevel 2) become a truncated join.
-func checksumSummary(results []string) string {
-	if len(results) == 1 {
-		return results[0]
+// checksumSummary condenses a chunk checksum result into its chunk_comparisons
+// parts. The aggregate (checkLevel 1) is the row count, CRC32XOR and hash sum;
+// per-row results (checkLevel 2) are counted and become a join truncated to the
+// VARCHAR(64) checksum column.
+func checksumSummary(results []string, checkLevel int64) tracking.ChunkChecksum {
+	if checkLevel == 1 {
+		if len(results) != 3 {
+			return tracking.ChunkChecksum{RowCount: -1, Checksum: strings.Join(results, ",")}
+		}
+		rowCount, err := strconv.ParseInt(results[0], 10, 64)
+		if err != nil {
+			rowCount = -1
+		}
+		return tracking.ChunkChecksum{RowCount: rowCount, Checksum: results[1], HashSum: results[2]}
 	}
 	joined := strings.Join(results, ",")
 	if len(joined) > 64 {
-		return joined[:64]
+		joined = joined[:64]
 	}
-	return joined
+	return tracking.ChunkChecksum{RowCount: int64(len(results)), Checksum: joined}
 }
 
 // columnValuesToStrings renders range values as text; json.Marshal would
-// base64-encode the driver's []uint8 values otherwise.
+// base64-encode the driver's []uint8 values otherwise. Temporal values are
+// written as MySQL literals so resume can feed them back into range queries.
 func columnValuesToStrings(cv *types.ColumnValues) []string {
 	if cv == nil {
 		return nil
 	}
 	values := make([]string, len(cv.AbstractValues()))
-	for i := range values {
+	for i, value := range cv.AbstractValues() {
+		if t, ok := value.(time.Time); ok {
+			values[i] = t.Format("2006-01-02 15:04:05.999999")
+			continue
+		}
 		values[i] = cv.StringColumn(i)
 	}
 	return values
@@ -40,6 +56,9 @@ func columnValuesToStrings(cv *types.ColumnValues) []string {
 
 // chunkRanges returns the current iteration range in whichever mode is active.
 func (ctx *ChecksumContext) chunkRanges() (rangeStart, rangeEnd interface{}) {
+	if ctx.IsKeyless {
+		return ctx.bucketString(), ctx.bucketString()
+	}
 	if ctx.ChecksumIterationRangeMinValues != nil || ctx.ChecksumIterationRangeMaxValues != nil {
 		return columnValuesToStrings(ctx.ChecksumIterationRangeMinValues), columnValuesToStrings(ctx.ChecksumIterationRangeMaxValues)
 	}
@@ -68,8 +87,138 @@ func (ctx *ChecksumContext) TrackTableStart() {
 	ctx.ComparisonID = comparisonID
 }
 
-// TrackChunk records one chunk outcome after its retry loop concludes.
+// ResumeFromCheckpoint seeds the chunk loop from the last chunk recorded for
+// ComparisonID, so a resumed table continues with the next chunk instead of
+// its first row, or checks that chunk again when it ended in an error. Call it after the unique key and its min/max values (or the
+// time column) are known. It reports false, leaving the context untouched,
+// when there is no usable checkpoint and the table restarts from scratch.
+func (ctx *ChecksumContext) ResumeFromCheckpoint() bool {
+	if ctx.JobTracker == nil || ctx.ComparisonID == 0 {
+		return false
+	}
+	checkpoint, err := ctx.JobTracker.GetChunkCheckpoint(ctx.ComparisonID)
+	if err != nil {
+		ctx.Context.Log.Warnf("tracking: read checkpoint of table comparison %d failed: %v", ctx.ComparisonID, err)
+		return false
+	}
+	if checkpoint == nil {
+		return false
+	}
+	if checkpoint.KeyRangeSplits > 1 {
+		ctx.Context.Log.Infof("Table %s.%s was checked in %d parallel key ranges; re-checking the table from its first row.", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, checkpoint.KeyRangeSplits)
+		return false
+	}
+	// A different chunk ends the table's check unless --continue-on-mismatch kept the loop going;
+	// the loop goes on after a skipped oversized chunk
+	if checkpoint.Status == tracking.StatusDifferent && !ctx.Context.ContinueOnMismatch {
+		ctx.Context.Log.Infof("Chunk %d of table %s.%s ended %s; re-checking the table from its first row.", checkpoint.ChunkNumber, ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, checkpoint.Status)
+		return false
+	}
+	// A chunk that ended in an error, e.g. throttled past --max-throttle-time, is checked again:
+	// the table resumes after the range start of that chunk rather than after its range end
+	resumeAfter := checkpoint.RangeEnd
+	nextChunk := checkpoint.ChunkNumber + 1
+	if checkpoint.Status == tracking.StatusError {
+		if checkpoint.ChunkNumber == 0 {
+			ctx.Context.Log.Infof("Chunk 0 of table %s.%s ended %s; re-checking the table from its first row.", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, checkpoint.Status)
+			return false
+		}
+		resumeAfter = checkpoint.RangeStart
+		nextChunk = checkpoint.ChunkNumber
+		checkpoint.ChunksError--
+	}
+	var differentKeyRanges []KeyRange
+	var differentTimeRanges []TimeRange
+	var differentChunkRanges []string
+	if checkpoint.ChunksDifferent > 0 {
+		if !ctx.Context.ContinueOnMismatch {
+			ctx.Context.Log.Infof("Table %s.%s has %d different chunk(s) before chunk %d; re-checking the table from its first row.", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, checkpoint.ChunksDifferent, checkpoint.ChunkNumber)
+			return false
+		}
+		differentKeyRanges, differentTimeRanges, differentChunkRanges, err = ctx.loadDifferentChunks(nextChunk - 1)
+		if err != nil {
+			ctx.Context.Log.Warnf("tracking: cannot resume table comparison %d: %v", ctx.ComparisonID, err)
+			return false
+		}
+	}
+
+	if ctx.TimeColumn != nil {
+		rangeEnd, err := resume.DecodeTimeValue(resumeAfter)
+		if err != nil {
+			ctx.Context.Log.Warnf("tracking: cannot resume table comparison %d: %v", ctx.ComparisonID, err)
+			return false
+		}
+		if rangeEnd.Before(ctx.Context.SpecifiedDatetimeRangeBegin) || rangeEnd.After(ctx.Context.SpecifiedDatetimeRangeEnd) {
+			ctx.Context.Log.Warnf("tracking: checkpoint %s of table comparison %d is outside the requested time range; restarting the table.", resumeAfter, ctx.ComparisonID)
+			return false
+		}
+		// CalculateNextIterationTimeRange starts each later chunk at the previous maximum
+		ctx.TimeIterationRangeMinValue = rangeEnd
+		ctx.TimeIterationRangeMaxValue = rangeEnd
+	} else {
+		rangeEnd, err := resume.DecodeUniqueKeyValues(resumeAfter, ctx.UniqueKey)
+		if err != nil {
+			ctx.Context.Log.Warnf("tracking: cannot resume table comparison %d: %v", ctx.ComparisonID, err)
+			return false
+		}
+		ctx.ChecksumIterationRangeMinValues = rangeEnd
+		ctx.resumedRangeEnd = resumeAfter
+	}
+	atomic.StoreInt64(ctx.iterationCounter(), int64(nextChunk))
+	ctx.chunksEqual = checkpoint.ChunksEqual
+	ctx.chunksDifferent = checkpoint.ChunksDifferent
+	ctx.chunksError = checkpoint.ChunksError
+	ctx.chunksSkipped = checkpoint.ChunksSkipped
+	ctx.differentKeyRanges = differentKeyRanges
+	ctx.differentTimeRanges = differentTimeRanges
+	ctx.PerTableContext.DifferentChunkRanges = differentChunkRanges
+	ctx.Context.Log.Infof("Resuming table %s.%s at chunk %d (after %s).", ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName, nextChunk, resumeAfter)
+	return true
+}
+
+// loadDifferentChunks reads back the chunks up to lastChunk that a resumed
+// --continue-on-mismatch run already found different.
+func (ctx *ChecksumContext) loadDifferentChunks(lastChunk int) (keyRanges []KeyRange, timeRanges []TimeRange, chunkRanges []string, err error) {
+	recorded, err := ctx.JobTracker.GetChunkRanges(ctx.ComparisonID, lastChunk, tracking.StatusDifferent)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, r := range recorded {
+		if ctx.TimeColumn != nil {
+			rangeStart, err := resume.DecodeTimeValue(r.RangeStart)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			rangeEnd, err := resume.DecodeTimeValue(r.RangeEnd)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			timeRange := TimeRange{Min: rangeStart, Max: rangeEnd, IncludeMax: rangeEnd.Equal(ctx.Context.SpecifiedDatetimeRangeEnd)}
+			timeRanges = append(timeRanges, timeRange)
+			chunkRanges = append(chunkRanges, timeRange.String())
+			continue
+		}
+		rangeStart, err := resume.DecodeUniqueKeyValues(r.RangeStart, ctx.UniqueKey)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		rangeEnd, err := resume.DecodeUniqueKeyValues(r.RangeEnd, ctx.UniqueKey)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		keyRange := KeyRange{Min: rangeStart, Max: rangeEnd, IncludeMin: r.ChunkNumber == 0}
+		keyRanges = append(keyRanges, keyRange)
+		chunkRanges = append(chunkRanges, keyRange.String())
+	}
+	return keyRanges, timeRanges, chunkRanges, nil
+}
+
+// TrackChunk records one chunk outcome after its retry loop concludes. The ranges of
+// different chunks are also kept on the context whether or not tracking is enabled.
 func (ctx *ChecksumContext) TrackChunk(chunkNumber int, isEqual bool, chunkErr error, d time.Duration) {
+	if chunkErr == nil && !isEqual {
+		ctx.recordDifferentChunk()
+	}
 	if ctx.JobTracker == nil {
 		return
 	}
@@ -82,8 +231,19 @@ func (ctx *ChecksumContext) TrackChunk(chunkNumber int, isEqual bool, chunkErr e
 		ctx.chunksDifferent++
 	}
 	rangeStart, rangeEnd := ctx.chunkRanges()
+	// Time chunks are bounded by time, not rows: their size is unknown
+	rowCountEstimate := int64(-1)
+	if ctx.TimeColumn == nil && ctx.iterationChunkSize > 0 {
+		rowCountEstimate = ctx.iterationChunkSize
+	}
+	// A failed chunk may not have run its checksum queries; the last checksums are an earlier
+	// chunk's, so it is recorded without checksums and row counts
+	sourceChecksum, targetChecksum := ctx.lastSourceChecksum, ctx.lastTargetChecksum
+	if chunkErr != nil {
+		sourceChecksum, targetChecksum = tracking.ChunkChecksum{RowCount: -1}, tracking.ChunkChecksum{RowCount: -1}
+	}
 	if err := ctx.JobTracker.RecordChunkComparison(ctx.ComparisonID, chunkNumber, rangeStart, rangeEnd,
-		ctx.lastSourceChecksum, ctx.lastTargetChecksum, tracking.ChunkStatus(isEqual, chunkErr), d); err != nil {
+		sourceChecksum, targetChecksum, tracking.ChunkStatus(isEqual, chunkErr), string(ctx.oversizedAction), rowCountEstimate, d); err != nil {
 		ctx.Context.Log.Warnf("tracking: record chunk %d failed: %v", chunkNumber, err)
 	}
 }
@@ -113,11 +273,16 @@ func (ctx *ChecksumContext) TrackDifferenceDetails(differences []RecordDifferenc
 		for col, val := range d.PrimaryKeyValues {
 			pkValues[col] = formatPrimaryKeyValue(val)
 		}
+		var columns []tracking.ColumnDifference
+		for _, column := range d.ColumnDifferences {
+			columns = append(columns, tracking.ColumnDifference{Column: column.Column, Source: column.SourceValue, Target: column.TargetValue})
+		}
 		details = append(details, tracking.DifferenceDetail{
-			Type:             differenceDetailType(d.DifferenceType),
-			PrimaryKeyValues: pkValues,
-			SourceChecksum:   d.SourceChecksum,
-			TargetChecksum:   d.TargetChecksum,
+			Type:              differenceDetailType(d.DifferenceType),
+			PrimaryKeyValues:  pkValues,
+			SourceChecksum:    d.SourceChecksum,
+			TargetChecksum:    d.TargetChecksum,
+			ColumnDifferences: columns,
 		})
 	}
 	if err := ctx.JobTracker.RecordDifferenceDetails(ctx.ComparisonID, details); err != nil {
@@ -125,6 +290,73 @@ func (ctx *ChecksumContext) TrackDifferenceDetails(differences []RecordDifferenc
 	}
 }
 
+// TrackChunkPlan stores the planned chunks of the table, so a resumed run can continue the same plan.
+func (ctx *ChecksumContext) TrackChunkPlan() {
+	if ctx.JobTracker == nil || ctx.ComparisonID == 0 || ctx.chunkPlan == nil {
+		return
+	}
+	rangeStarts := make([]interface{}, len(ctx.chunkPlan.chunks))
+	rangeEnds := make([]interface{}, len(ctx.chunkPlan.chunks))
+	for i, chunk := range ctx.chunkPlan.chunks {
+		rangeStarts[i], rangeEnds[i] = columnValuesToStrings(chunk.Min), columnValuesToStrings(chunk.Max)
+	}
+	if err := ctx.JobTracker.RecordChunkPlan(ctx.ComparisonID, rangeStarts, rangeEnds); err != nil {
+		ctx.Context.Log.Warnf("tracking: record chunk plan failed: %v", err)
+	}
+}
+
+// TrackSnapshot records the source position of the table's consistent snapshot.
+func (ctx *ChecksumContext) TrackSnapshot() {
+	if ctx.JobTracker == nil {
+		return
+	}
+	if err := ctx.JobTracker.RecordTableSnapshot(ctx.ComparisonID, ctx.SnapshotBinlogPosition, ctx.SnapshotGTIDSet); err != nil {
+		ctx.Context.Log.Warnf("tracking: record snapshot of table comparison %d failed: %v", ctx.ComparisonID, err)
+	}
+}
+
+// TrackKeyRangeSplits records how many key ranges the table is checked in, so a
+// resume knows whether its last chunk is a checkpoint.
+func (ctx *ChecksumContext) TrackKeyRangeSplits(splits int) {
+	if ctx.JobTracker == nil {
+		return
+	}
+	if err := ctx.JobTracker.RecordTableKeyRangeSplits(ctx.ComparisonID, splits); err != nil {
+		ctx.Context.Log.Warnf("tracking: record key range splits of table comparison %d failed: %v", ctx.ComparisonID, err)
+	}
+}
+
+// TrackSample records how many chunks of the table's chunk grid are sampled, so later runs can sample others.
+func (ctx *ChecksumContext) TrackSample() {
+	if ctx.JobTracker == nil || ctx.sample == nil {
+		return
+	}
+	if err := ctx.JobTracker.RecordTableSample(ctx.ComparisonID, len(ctx.sample.chunks), ctx.sample.population); err != nil {
+		ctx.Context.Log.Warnf("tracking: record sample of table comparison %d failed: %v", ctx.ComparisonID, err)
+	}
+}
+
+// TrackPrefilter records which --prefilter pass decided the table without a chunk check.
+func (ctx *ChecksumContext) TrackPrefilter() {
+	if ctx.JobTracker == nil || ctx.prefilterMethod == "" {
+		return
+	}
+	if err := ctx.JobTracker.RecordTablePrefilter(ctx.ComparisonID, string(ctx.prefilterMethod)); err != nil {
+		ctx.Context.Log.Warnf("tracking: record prefilter of table comparison %d failed: %v", ctx.ComparisonID, err)
+	}
+}
+
+// TrackPartitionDone records the result of the partition the context checked.
+func (ctx *ChecksumContext) TrackPartitionDone(isEqual bool, err error, d time.Duration) {
+	if ctx.JobTracker == nil || ctx.partition == "" {
+		return
+	}
+	if trackErr := ctx.JobTracker.RecordPartitionComparison(ctx.ComparisonID, ctx.partition, tracking.TableStatus(isEqual, err),
+		ctx.SourceRowCount, ctx.TargetRowCount, ctx.chunksEqual, ctx.chunksDifferent, d); trackErr != nil {
+		ctx.Context.Log.Warnf("tracking: record partition %s of table comparison %d failed: %v", ctx.partition, ctx.ComparisonID, trackErr)
+	}
+}
+
 // TrackTableDone finalizes the table_comparisons row.
 func (ctx *ChecksumContext) TrackTableDone(isEqual bool, err error) {
 	if ctx.JobTracker == nil {
@@ -136,7 +368,15 @@ func (ctx *ChecksumContext) TrackTableDone(isEqual bool, err error) {
 	}
 	chunksProcessed := ctx.chunksEqual + ctx.chunksDifferent + ctx.chunksError
 	if trackErr := ctx.JobTracker.UpdateTableComparison(ctx.ComparisonID, tracking.TableStatus(isEqual, err),
-		ctx.SourceRowCount, ctx.TargetRowCount, chunksProcessed, ctx.chunksEqual, ctx.chunksDifferent, errMsg); trackErr != nil {
+		ctx.SourceRowCount, ctx.TargetRowCount, chunksProcessed, ctx.chunksEqual, ctx.chunksDifferent, ctx.throttledTime, errMsg); trackErr != nil {
 		ctx.Context.Log.Warnf("tracking: finalize table comparison %d failed: %v", ctx.ComparisonID, trackErr)
 	}
+	if trackErr := ctx.JobTracker.RecordTableFullTable(ctx.ComparisonID, ctx.coversFullTable()); trackErr != nil {
+		ctx.Context.Log.Warnf("tracking: record coverage of table comparison %d failed: %v", ctx.ComparisonID, trackErr)
+	}
+	if len(ctx.PerTableContext.DifferentColumns) > 0 {
+		if trackErr := ctx.JobTracker.RecordTableDifferentColumns(ctx.ComparisonID, ctx.PerTableContext.DifferentColumns); trackErr != nil {
+			ctx.Context.Log.Warnf("tracking: record different columns of table comparison %d failed: %v", ctx.ComparisonID, trackErr)
+		}
+	}
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ChaosHour/go-data-checksum/pkg/tracking"
 	"github.com/ChaosHour/go-data-checksum/pkg/types"
 )
 
@@ -43,18 +44,19 @@ func TestNewChecksumContextRowCountsUnknown(t *testing.T) {
 }
 
 func TestChecksumSummary(t *testing.T) {
-	if got := checksumSummary([]string{"deadbeef"}); got != "deadbeef" {
-		t.Errorf("single-element summary should pass through, got %q", got)
+	want := tracking.ChunkChecksum{RowCount: 12, Checksum: "deadbeef", HashSum: "1f00"}
+	if got := checksumSummary([]string{"12", "deadbeef", "1f00"}, 1); got != want {
+		t.Errorf("aggregate summary should split into its parts, got %+v", got)
 	}
-	if got := checksumSummary(nil); got != "" {
-		t.Errorf("empty summary should be empty, got %q", got)
+	if got := checksumSummary(nil, 2); got != (tracking.ChunkChecksum{}) {
+		t.Errorf("empty summary should be empty, got %+v", got)
 	}
-	if got := checksumSummary([]string{"aa", "bb"}); got != "aa,bb" {
-		t.Errorf("multi-element summary should join, got %q", got)
+	if got := checksumSummary([]string{"aa", "bb"}, 2); got.Checksum != "aa,bb" || got.RowCount != 2 || got.HashSum != "" {
+		t.Errorf("row-level summary should join and count, got %+v", got)
 	}
-	long := checksumSummary([]string{strings.Repeat("a", 50), strings.Repeat("b", 50)})
-	if len(long) != 64 {
-		t.Errorf("long summary should truncate to 64 chars, got %d", len(long))
+	long := checksumSummary([]string{strings.Repeat("a", 50), strings.Repeat("b", 50)}, 2)
+	if len(long.Checksum) != 64 {
+		t.Errorf("long summary should truncate to 64 chars, got %d", len(long.Checksum))
 	}
 }
 
@@ -67,6 +69,23 @@ func TestColumnValuesToStrings(t *testing.T) {
 	if len(got) != 2 || got[0] != "abc" || got[1] != "42" {
 		t.Errorf("[]uint8 values must render as text, got %#v", got)
 	}
+	ts := types.ToColumnValues([]interface{}{time.Date(2024, 3, 1, 10, 20, 30, 0, time.UTC)})
+	if got := columnValuesToStrings(ts); got[0] != "2024-03-01 10:20:30" {
+		t.Errorf("time values must render as MySQL literals, got %q", got[0])
+	}
+}
+
+// Without a tracker, or for a table that was not resumed, there is nothing to
+// resume from and the context must stay on its first chunk.
+func TestResumeFromCheckpointNoTracker(t *testing.T) {
+	ctx := newTrackingTestContext()
+	ctx.ComparisonID = 7
+	if ctx.ResumeFromCheckpoint() {
+		t.Errorf("ResumeFromCheckpoint should report false without a tracker")
+	}
+	if ctx.GetIteration() != 0 || ctx.ChecksumIterationRangeMinValues != nil {
+		t.Errorf("context moved without a checkpoint: iteration %d", ctx.GetIteration())
+	}
 }
 
 func TestDifferenceDetailType(t *testing.T) {
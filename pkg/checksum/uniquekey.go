@@ -0,0 +1,196 @@
+package checksum
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+// User-selected keys: --chunk-index and --match-columns replace the unique key GetUniqueKeys
+// would pick for a table, e.g. to match rows of a migrated table on a natural key shared by
+// source and target rather than on a surrogate primary key. The chunk index is the source
+// index given to FORCE INDEX; the match columns are the key chunks are ranged and rows are
+// matched on. Both must be unique and NOT NULL on the source and on the target.
+
+// indexInfo is an index of a table and its columns in index order
+type indexInfo struct {
+	name    string
+	unique  bool
+	columns []string
+}
+
+// readIndexes lists the indexes of a table, PRIMARY first, then unique ones, then by name
+func readIndexes(db *gosql.DB, databaseName, tableName string) (indexes []indexInfo, err error) {
+	query := `
+    select INDEX_NAME, MIN(NON_UNIQUE) = 0, GROUP_CONCAT(COLUMN_NAME ORDER BY SEQ_IN_INDEX ASC)
+      from information_schema.STATISTICS
+     where TABLE_SCHEMA = ? and TABLE_NAME = ?
+     group by INDEX_NAME
+     order by INDEX_NAME = 'PRIMARY' desc, MIN(NON_UNIQUE), INDEX_NAME
+  `
+	rows, err := db.Query(query, databaseName, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("critical: table %s.%s get indexes failed: %v", databaseName, tableName, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var index indexInfo
+		var columnNames string
+		if err := rows.Scan(&index.name, &index.unique, &columnNames); err != nil {
+			return nil, err
+		}
+		index.columns = strings.Split(columnNames, ",")
+		indexes = append(indexes, index)
+	}
+	return indexes, rows.Err()
+}
+
+// readNotNullColumns returns the given columns as named by the table, failing when one is
+// missing or nullable
+func readNotNullColumns(db *gosql.DB, databaseName, tableName string, columnNames []string) (names []string, err error) {
+	query := `
+    select COLUMN_NAME, IS_NULLABLE = 'YES'
+      from information_schema.columns
+     where table_schema = ? and table_name = ?
+  `
+	rows, err := db.Query(query, databaseName, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("critical: table %s.%s get columns failed: %v", databaseName, tableName, err)
+	}
+	defer rows.Close()
+	tableColumns := map[string]string{}
+	nullable := map[string]bool{}
+	for rows.Next() {
+		var columnName string
+		var isNullable bool
+		if err := rows.Scan(&columnName, &isNullable); err != nil {
+			return nil, err
+		}
+		tableColumns[strings.ToLower(columnName)] = columnName
+		nullable[strings.ToLower(columnName)] = isNullable
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, columnName := range columnNames {
+		name, found := tableColumns[strings.ToLower(columnName)]
+		if !found {
+			return nil, fmt.Errorf("critical: table %s.%s has no column %s", databaseName, tableName, columnName)
+		}
+		if nullable[strings.ToLower(columnName)] {
+			return nil, fmt.Errorf("critical: column %s of table %s.%s is nullable and cannot match rows", name, databaseName, tableName)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// splitMatchColumns parses a --match-columns value, a comma-separated column list
+func splitMatchColumns(matchColumns string) (columnNames []string) {
+	for _, columnName := range strings.Split(matchColumns, ",") {
+		if columnName = strings.Trim(strings.TrimSpace(columnName), "`"); columnName != "" {
+			columnNames = append(columnNames, columnName)
+		}
+	}
+	return columnNames
+}
+
+// hasColumnPrefix reports whether the index starts with the given columns, in that order
+func (index indexInfo) hasColumnPrefix(columnNames []string) bool {
+	if len(columnNames) > len(index.columns) {
+		return false
+	}
+	for i, columnName := range columnNames {
+		if !strings.EqualFold(index.columns[i], columnName) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveUniqueKey returns the chunk index and key columns for the requested chunk index and
+// match columns, either of which may be empty. Without match columns the key is the chunk
+// index's columns; without a chunk index it is the first index starting with the match columns.
+func resolveUniqueKey(indexes []indexInfo, chunkIndex string, matchColumns []string) (indexName string, keyColumns []string, err error) {
+	if chunkIndex != "" {
+		for _, index := range indexes {
+			if !strings.EqualFold(index.name, chunkIndex) {
+				continue
+			}
+			if len(matchColumns) == 0 {
+				return index.name, index.columns, nil
+			}
+			if !index.hasColumnPrefix(matchColumns) {
+				return "", nil, fmt.Errorf("index %s (%s) does not start with the match columns (%s)", index.name, strings.Join(index.columns, ","), strings.Join(matchColumns, ","))
+			}
+			return index.name, matchColumns, nil
+		}
+		return "", nil, fmt.Errorf("has no index %s", chunkIndex)
+	}
+	for _, index := range indexes {
+		if index.hasColumnPrefix(matchColumns) {
+			return index.name, matchColumns, nil
+		}
+	}
+	return "", nil, fmt.Errorf("has no index starting with the match columns (%s)", strings.Join(matchColumns, ","))
+}
+
+// isUniqueKey reports whether a unique index covers only columns of the key, which makes the key unique
+func isUniqueKey(indexes []indexInfo, keyColumns []string) bool {
+	isKeyColumn := map[string]bool{}
+	for _, columnName := range keyColumns {
+		isKeyColumn[strings.ToLower(columnName)] = true
+	}
+	for _, index := range indexes {
+		if !index.unique {
+			continue
+		}
+		covered := true
+		for _, columnName := range index.columns {
+			covered = covered && isKeyColumn[strings.ToLower(columnName)]
+		}
+		if covered {
+			return true
+		}
+	}
+	return false
+}
+
+// selectUniqueKey sets the unique key from the requested chunk index and match columns,
+// once the key is found unique and NOT NULL on both the source and the target table
+func (ctx *ChecksumContext) selectUniqueKey(chunkIndex, matchColumns string) error {
+	sourceDatabaseName, sourceTableName := ctx.PerTableContext.SourceDatabaseName, ctx.PerTableContext.SourceTableName
+	sourceIndexes, err := readIndexes(ctx.Context.SourceDB, sourceDatabaseName, sourceTableName)
+	if err != nil {
+		return err
+	}
+	indexName, keyColumns, err := resolveUniqueKey(sourceIndexes, chunkIndex, splitMatchColumns(matchColumns))
+	if err != nil {
+		return fmt.Errorf("critical: table %s.%s %v", sourceDatabaseName, sourceTableName, err)
+	}
+	if keyColumns, err = readNotNullColumns(ctx.Context.SourceDB, sourceDatabaseName, sourceTableName, keyColumns); err != nil {
+		return err
+	}
+	if !isUniqueKey(sourceIndexes, keyColumns) {
+		return fmt.Errorf("critical: columns (%s) are not unique on table %s.%s", strings.Join(keyColumns, ","), sourceDatabaseName, sourceTableName)
+	}
+
+	targetDatabaseName, targetTableName := ctx.PerTableContext.TargetDatabaseName, ctx.PerTableContext.TargetTableName
+	targetIndexes, err := readIndexes(ctx.Context.TargetDB, targetDatabaseName, targetTableName)
+	if err != nil {
+		return err
+	}
+	if _, err := readNotNullColumns(ctx.Context.TargetDB, targetDatabaseName, targetTableName, keyColumns); err != nil {
+		return err
+	}
+	if !isUniqueKey(targetIndexes, keyColumns) {
+		return fmt.Errorf("critical: columns (%s) are not unique on table %s.%s", strings.Join(keyColumns, ","), targetDatabaseName, targetTableName)
+	}
+
+	ctx.Context.Log.Infof("Table %s.%s is chunked by index %s and matched on (%s).", sourceDatabaseName, sourceTableName, indexName, strings.Join(keyColumns, ","))
+	ctx.UniqueKey = types.NewColumnList(keyColumns)
+	ctx.UniqueIndexName = indexName
+	return ctx.readColumnTypes(ctx.UniqueKey)
+}
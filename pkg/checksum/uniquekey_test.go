@@ -0,0 +1,71 @@
+package checksum
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testIndexes = []indexInfo{
+	{name: "PRIMARY", unique: true, columns: []string{"id"}},
+	{name: "uk_order_no", unique: true, columns: []string{"order_no", "line_no"}},
+	{name: "idx_customer", unique: false, columns: []string{"customer_id", "created_at"}},
+}
+
+func TestResolveUniqueKey(t *testing.T) {
+	cases := []struct {
+		name         string
+		chunkIndex   string
+		matchColumns []string
+		wantIndex    string
+		wantColumns  []string
+	}{
+		{"chunk index only", "UK_ORDER_NO", nil, "uk_order_no", []string{"order_no", "line_no"}},
+		{"match columns only", "", []string{"order_no", "line_no"}, "uk_order_no", []string{"order_no", "line_no"}},
+		{"match columns on an index prefix", "", []string{"customer_id"}, "idx_customer", []string{"customer_id"}},
+		{"both", "idx_customer", []string{"customer_id", "created_at"}, "idx_customer", []string{"customer_id", "created_at"}},
+	}
+	for _, c := range cases {
+		indexName, keyColumns, err := resolveUniqueKey(testIndexes, c.chunkIndex, c.matchColumns)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if indexName != c.wantIndex || !reflect.DeepEqual(keyColumns, c.wantColumns) {
+			t.Errorf("%s: got %s (%v), want %s (%v)", c.name, indexName, keyColumns, c.wantIndex, c.wantColumns)
+		}
+	}
+
+	for _, invalid := range []struct {
+		chunkIndex   string
+		matchColumns []string
+	}{
+		{"uk_missing", nil},
+		{"", []string{"line_no"}},
+		{"uk_order_no", []string{"line_no", "order_no"}},
+	} {
+		if _, _, err := resolveUniqueKey(testIndexes, invalid.chunkIndex, invalid.matchColumns); err == nil {
+			t.Errorf("%q %v should be rejected", invalid.chunkIndex, invalid.matchColumns)
+		}
+	}
+}
+
+func TestIsUniqueKey(t *testing.T) {
+	if !isUniqueKey(testIndexes, []string{"Order_No", "line_no"}) {
+		t.Error("columns of a unique index should be unique")
+	}
+	if !isUniqueKey(testIndexes, []string{"customer_id", "id"}) {
+		t.Error("a superset of a unique index should be unique")
+	}
+	if isUniqueKey(testIndexes, []string{"order_no"}) {
+		t.Error("part of a unique index should not be unique")
+	}
+	if isUniqueKey(testIndexes, []string{"customer_id", "created_at"}) {
+		t.Error("columns of a non-unique index should not be unique")
+	}
+}
+
+func TestSplitMatchColumns(t *testing.T) {
+	if got, want := splitMatchColumns(" order_no, `line_no`,"), []string{"order_no", "line_no"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("splitMatchColumns = %v, want %v", got, want)
+	}
+}
@@ -42,9 +42,9 @@ func EscapeName(name string) string {
 
 // Add builder functions for compatibility
 func BuildUniqueKeyMinValuesPreparedQuery(databaseName, tableName string, uniqueKeyColumns *ColumnList) (string, error) {
-	return builder.BuildUniqueKeyMinValuesPreparedQuery(databaseName, tableName, uniqueKeyColumns)
+	return builder.BuildUniqueKeyMinValuesPreparedQuery(databaseName, tableName, "", uniqueKeyColumns)
 }
 
 func BuildUniqueKeyMaxValuesPreparedQuery(databaseName, tableName string, uniqueKeyColumns *ColumnList) (string, error) {
-	return builder.BuildUniqueKeyMaxValuesPreparedQuery(databaseName, tableName, uniqueKeyColumns)
+	return builder.BuildUniqueKeyMaxValuesPreparedQuery(databaseName, tableName, "", uniqueKeyColumns)
 }
@@ -1,14 +1,27 @@
 // Package resume loads the pending tables of a previously tracked job so the
-// normal checksum flow can re-check them. Resume re-checks each pending table
-// from the beginning; chunk-level mid-table resume is deliberately out of scope.
+// normal checksum flow can re-check them, and decodes the chunk ranges recorded
+// in chunk_comparisons so a table can continue after its last recorded chunk.
 package resume
 
 import (
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/ChaosHour/go-data-checksum/pkg/tracking"
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
 )
 
+// timeLayouts are the renderings chunk ranges have been recorded with: the
+// MySQL literal used for key values and time chunks, a bare date, and Go's
+// default time.Time format written by older versions for temporal keys.
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999",
+	"2006-01-02",
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+}
+
 // LoadPendingTables returns the pending/running tables of the tracker's job as
 // a source→target pair map plus a source-full-name→comparison_id map. The
 // caller reuses each comparison_id so results land on the existing
@@ -28,3 +41,58 @@ func LoadPendingTables(tracker *tracking.JobTracker) (pairs map[string]string, c
 	}
 	return pairs, comparisonIDs, nil
 }
+
+// DecodeUniqueKeyValues turns a recorded key-mode range bound (a JSON array of
+// strings) back into values for the given unique key. Integer columns become
+// int64/uint64 and temporal columns time.Time; everything else stays text,
+// which MySQL compares correctly against the column.
+func DecodeUniqueKeyValues(rangeJSON string, uniqueKey *types.ColumnList) (*types.ColumnValues, error) {
+	var rendered []string
+	if err := json.Unmarshal([]byte(rangeJSON), &rendered); err != nil {
+		return nil, fmt.Errorf("range %s is not a unique-key range: %v", rangeJSON, err)
+	}
+	if len(rendered) != uniqueKey.Len() {
+		return nil, fmt.Errorf("range %s has %d values, unique key (%s) has %d columns", rangeJSON, len(rendered), uniqueKey, uniqueKey.Len())
+	}
+	values := make([]interface{}, len(rendered))
+	for i, column := range uniqueKey.Columns() {
+		value, err := decodeColumnValue(rendered[i], &column)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %v", column.Name, err)
+		}
+		values[i] = value
+	}
+	return types.ToColumnValues(values), nil
+}
+
+func decodeColumnValue(rendered string, column *types.Column) (interface{}, error) {
+	switch {
+	case column.Type == types.IntegerColumnType || column.Type == types.MediumIntColumnType:
+		if column.IsUnsigned {
+			return strconv.ParseUint(rendered, 10, 64)
+		}
+		return strconv.ParseInt(rendered, 10, 64)
+	case column.IsTemporal():
+		return parseTime(rendered)
+	}
+	return rendered, nil
+}
+
+// DecodeTimeValue turns a recorded time-column range bound (a JSON string)
+// back into a time in the local zone, matching the loc=Local connections.
+func DecodeTimeValue(rangeJSON string) (time.Time, error) {
+	var rendered string
+	if err := json.Unmarshal([]byte(rangeJSON), &rendered); err != nil {
+		return time.Time{}, fmt.Errorf("range %s is not a time range: %v", rangeJSON, err)
+	}
+	return parseTime(rendered)
+}
+
+func parseTime(rendered string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, rendered, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q as a time value", rendered)
+}
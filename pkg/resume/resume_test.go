@@ -0,0 +1,57 @@
+package resume
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+func TestDecodeUniqueKeyValues(t *testing.T) {
+	uniqueKey := types.NewColumnList([]string{"tenant_id", "created_at", "code"})
+	uniqueKey.SetColumnType("tenant_id", types.IntegerColumnType)
+	uniqueKey.SetUnsigned("tenant_id")
+	uniqueKey.SetColumnType("created_at", types.DateTimeColumnType)
+
+	values, err := DecodeUniqueKeyValues(`["18446744073709551615", "2024-03-01 10:20:30.5", "abc"]`, uniqueKey)
+	if err != nil {
+		t.Fatalf("DecodeUniqueKeyValues: %v", err)
+	}
+	got := values.AbstractValues()
+	if got[0] != uint64(18446744073709551615) {
+		t.Errorf("unsigned integer column decoded as %#v", got[0])
+	}
+	want := time.Date(2024, 3, 1, 10, 20, 30, 500000000, time.Local)
+	if tm, ok := got[1].(time.Time); !ok || !tm.Equal(want) {
+		t.Errorf("datetime column decoded as %#v, want %v", got[1], want)
+	}
+	if got[2] != "abc" {
+		t.Errorf("text column decoded as %#v", got[2])
+	}
+	if len(values.ValuesPointers) != 3 {
+		t.Errorf("decoded values should be usable as query args, got %d pointers", len(values.ValuesPointers))
+	}
+}
+
+func TestDecodeUniqueKeyValuesRejectsMismatch(t *testing.T) {
+	uniqueKey := types.NewColumnList([]string{"id"})
+	uniqueKey.SetColumnType("id", types.IntegerColumnType)
+	for _, rangeJSON := range []string{`["1", "2"]`, `"2024-01-01 00:00:00"`, `["abc"]`} {
+		if _, err := DecodeUniqueKeyValues(rangeJSON, uniqueKey); err == nil {
+			t.Errorf("DecodeUniqueKeyValues(%s) should fail", rangeJSON)
+		}
+	}
+}
+
+func TestDecodeTimeValue(t *testing.T) {
+	got, err := DecodeTimeValue(`"2024-03-01 10:25:00"`)
+	if err != nil {
+		t.Fatalf("DecodeTimeValue: %v", err)
+	}
+	if want := time.Date(2024, 3, 1, 10, 25, 0, 0, time.Local); !got.Equal(want) {
+		t.Errorf("DecodeTimeValue = %v, want %v", got, want)
+	}
+	if _, err := DecodeTimeValue(`["1"]`); err == nil {
+		t.Errorf("a key range must not decode as a time")
+	}
+}
@@ -0,0 +1,292 @@
+// Package throttle holds the chunk loops back while the replicas fall behind or
+// the servers are busy. A background collector polls the replication lag of the
+// target and of any control replicas, and the --max-load / --critical-load status
+// variables of the source and target, and records its verdict on the BaseContext;
+// the loops call Throttle between chunks and wait there until the verdict clears.
+package throttle
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+const (
+	collectInterval = time.Second
+	pollInterval    = 250 * time.Millisecond
+	// reasonInterval is how often a long pause logs why it still waits
+	reasonInterval = time.Minute
+)
+
+type server struct {
+	name  string
+	db    *gosql.DB
+	owned bool // opened by the throttler, closed by Stop
+}
+
+// Throttler collects the throttle verdict in the background; a nil *Throttler never throttles.
+type Throttler struct {
+	context  *types.BaseContext
+	replicas []server // checked against --max-lag
+	servers  []server // the source and target, checked against --max-load and --critical-load
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// ParseReplicaList splits a comma-separated host[:port] list; the port defaults to 3306
+func ParseReplicaList(list string) (addresses []string, err error) {
+	for _, address := range strings.Split(list, ",") {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			continue
+		}
+		host, port := address, "3306"
+		if i := strings.LastIndex(address, ":"); i >= 0 {
+			host, port = address[:i], address[i+1:]
+		}
+		if host == "" {
+			return nil, fmt.Errorf("critical: invalid replica address %q", address)
+		}
+		if _, err := strconv.Atoi(port); err != nil {
+			return nil, fmt.Errorf("critical: invalid port in replica address %q", address)
+		}
+		addresses = append(addresses, host+":"+port)
+	}
+	return addresses, nil
+}
+
+// NewThrottler watches the source and target plus the --throttle-control-replicas,
+// which are reached with the target's credentials
+func NewThrottler(context *types.BaseContext) (*Throttler, error) {
+	t := &Throttler{context: context, done: make(chan struct{})}
+	source := server{name: fmt.Sprintf("%s:%d", context.SourceDBHost, context.SourceDBPort), db: context.SourceDB}
+	target := server{name: fmt.Sprintf("%s:%d", context.TargetDBHost, context.TargetDBPort), db: context.TargetDB}
+	t.servers = []server{source, target}
+	t.replicas = []server{target}
+
+	addresses, err := ParseReplicaList(context.ThrottleControlReplicas)
+	if err != nil {
+		return nil, err
+	}
+	for _, address := range addresses {
+		i := strings.LastIndex(address, ":")
+		port, _ := strconv.Atoi(address[i+1:])
+		db, err := gosql.Open("mysql", types.BuildDBUri(context.TargetDBUser, context.TargetDBPass, address[:i], port, "information_schema", context.Timeout))
+		if err == nil {
+			err = db.Ping()
+		}
+		if err != nil {
+			t.closeReplicas()
+			return nil, fmt.Errorf("critical: connect to throttle control replica %s failed: %v", address, err)
+		}
+		t.replicas = append(t.replicas, server{name: address, db: db, owned: true})
+	}
+	return t, nil
+}
+
+// Start collects a first verdict, so the first chunk already obeys it, then keeps collecting in the background
+func (t *Throttler) Start() {
+	t.collect()
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(collectInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-t.done:
+				return
+			case <-ticker.C:
+				t.collect()
+			}
+		}
+	}()
+}
+
+// Stop ends the collector and closes the control replica connections
+func (t *Throttler) Stop() {
+	if t == nil {
+		return
+	}
+	close(t.done)
+	t.wg.Wait()
+	t.closeReplicas()
+}
+
+func (t *Throttler) closeReplicas() {
+	for _, r := range t.replicas {
+		if r.owned {
+			r.db.Close()
+		}
+	}
+}
+
+// collect checks every server and records the first reason to throttle, if any.
+// Reaching --critical-load aborts the run; the tables being checked stay 'running'
+// in tracking, so the job can be resumed later.
+func (t *Throttler) collect() {
+	if reason := t.collectReason(); reason != "" {
+		t.context.SetThrottled(true, reason)
+		return
+	}
+	t.context.SetThrottled(false, "")
+}
+
+func (t *Throttler) collectReason() string {
+	if criticalLoad := t.context.GetCriticalLoad(); len(criticalLoad) > 0 {
+		for _, s := range t.servers {
+			variable, value, err := exceededLoad(criticalLoad, s.readStatus)
+			if err != nil {
+				return fmt.Sprintf("cannot read status of %s: %v", s.name, err)
+			}
+			if variable != "" {
+				t.context.PanicAbort <- fmt.Errorf("critical: critical-load met on %s: %s=%d, threshold %d", s.name, variable, value, criticalLoad[variable])
+				return "critical-load met"
+			}
+		}
+	}
+	if maxLoad := t.context.GetMaxLoad(); len(maxLoad) > 0 {
+		for _, s := range t.servers {
+			variable, value, err := exceededLoad(maxLoad, s.readStatus)
+			if err != nil {
+				return fmt.Sprintf("cannot read status of %s: %v", s.name, err)
+			}
+			if variable != "" {
+				return fmt.Sprintf("max-load met on %s: %s=%d, threshold %d", s.name, variable, value, maxLoad[variable])
+			}
+		}
+	}
+	if t.context.MaxLag > 0 {
+		for _, r := range t.replicas {
+			lag, err := t.readLag(r.db)
+			if err != nil {
+				return fmt.Sprintf("cannot read replication lag of %s: %v", r.name, err)
+			}
+			if lag > t.context.MaxLag {
+				return fmt.Sprintf("replica %s lags %s, max-lag is %s", r.name, lag, t.context.MaxLag)
+			}
+		}
+	}
+	return ""
+}
+
+// exceededLoad returns the first status variable (in name order) whose value reaches its threshold
+func exceededLoad(load types.LoadMap, readStatus func(variable string) (int64, error)) (variable string, value int64, err error) {
+	for _, variable := range load.Variables() {
+		if value, err = readStatus(variable); err != nil {
+			return "", 0, err
+		}
+		if value >= load[variable] {
+			return variable, value, nil
+		}
+	}
+	return "", 0, nil
+}
+
+// readStatus reads a global status variable of the server. The variable name was
+// validated by types.ParseLoadMap.
+func (s server) readStatus(variable string) (value int64, err error) {
+	var name string
+	err = s.db.QueryRow(fmt.Sprintf("SHOW GLOBAL STATUS LIKE '%s'", variable)).Scan(&name, &value)
+	if err == gosql.ErrNoRows {
+		return 0, fmt.Errorf("unknown status variable %s", variable)
+	}
+	return value, err
+}
+
+// readLag returns the replication lag of a server: the result of --replication-lag-query
+// (seconds) when given, otherwise Seconds_Behind_Source. A server that is not a replica has no lag.
+func (t *Throttler) readLag(db *gosql.DB) (time.Duration, error) {
+	if t.context.ReplicationLagQuery != "" {
+		var seconds gosql.NullFloat64
+		if err := db.QueryRow(t.context.ReplicationLagQuery).Scan(&seconds); err != nil {
+			return 0, err
+		}
+		if !seconds.Valid {
+			return 0, fmt.Errorf("replication lag query returned NULL")
+		}
+		return time.Duration(seconds.Float64 * float64(time.Second)), nil
+	}
+
+	rows, err := db.Query("SHOW REPLICA STATUS")
+	if err != nil {
+		// Before MySQL 8.0.22
+		if rows, err = db.Query("SHOW SLAVE STATUS"); err != nil {
+			return 0, err
+		}
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if !rows.Next() {
+		return 0, rows.Err()
+	}
+	values := make([]gosql.NullString, len(columns))
+	valuePointers := make([]interface{}, len(columns))
+	for i := range values {
+		valuePointers[i] = &values[i]
+	}
+	if err = rows.Scan(valuePointers...); err != nil {
+		return 0, err
+	}
+	return secondsBehindSource(columns, values)
+}
+
+// secondsBehindSource picks the lag out of a replica status row; NULL means the replication threads are not running
+func secondsBehindSource(columns []string, values []gosql.NullString) (time.Duration, error) {
+	for i, column := range columns {
+		if column != "Seconds_Behind_Source" && column != "Seconds_Behind_Master" {
+			continue
+		}
+		if !values[i].Valid {
+			return 0, fmt.Errorf("replication is not running")
+		}
+		seconds, err := strconv.ParseInt(values[i].String, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return 0, fmt.Errorf("replica status has no Seconds_Behind_Source column")
+}
+
+// Throttle blocks while the chunk loops are throttled and returns how long it waited. A pause
+// logs its reason again every reasonInterval, and fails once it lasts --max-throttle-time: a lag
+// that cannot be read or replication that stays stopped would otherwise hold the table forever.
+func (t *Throttler) Throttle() (time.Duration, error) {
+	if t == nil {
+		return 0, nil
+	}
+	throttled, reason := t.context.IsThrottled()
+	if !throttled {
+		return 0, nil
+	}
+	startTime := time.Now()
+	lastLogged := startTime
+	t.context.Log.Infof("Throttled: %s", reason)
+	for throttled {
+		time.Sleep(pollInterval)
+		throttled, reason = t.context.IsThrottled()
+		if !throttled {
+			break
+		}
+		paused := time.Since(startTime)
+		if maxThrottleTime := t.context.MaxThrottleTime; maxThrottleTime > 0 && paused >= maxThrottleTime {
+			return paused, fmt.Errorf("critical: throttled for %s, over --max-throttle-time %s: %s", paused.Round(time.Second), maxThrottleTime, reason)
+		}
+		if time.Since(lastLogged) >= reasonInterval {
+			t.context.Log.Infof("Still throttled after %s: %s", paused.Round(time.Second), reason)
+			lastLogged = time.Now()
+		}
+	}
+	paused := time.Since(startTime)
+	t.context.Log.Infof("Throttle released after %s.", paused)
+	return paused, nil
+}
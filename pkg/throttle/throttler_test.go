@@ -0,0 +1,92 @@
+package throttle
+
+import (
+	gosql "database/sql"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ChaosHour/go-data-checksum/pkg/types"
+)
+
+func TestParseReplicaList(t *testing.T) {
+	addresses, err := ParseReplicaList(" replica1:3307, replica2 ,,")
+	if err != nil {
+		t.Fatalf("ParseReplicaList: %v", err)
+	}
+	if want := []string{"replica1:3307", "replica2:3306"}; !reflect.DeepEqual(addresses, want) {
+		t.Errorf("ParseReplicaList = %v, want %v", addresses, want)
+	}
+	if addresses, err := ParseReplicaList(""); err != nil || len(addresses) != 0 {
+		t.Errorf("empty list: got %v, %v", addresses, err)
+	}
+	for _, invalid := range []string{":3306", "replica1:port"} {
+		if _, err := ParseReplicaList(invalid); err == nil {
+			t.Errorf("%q should be rejected", invalid)
+		}
+	}
+}
+
+func TestSecondsBehindSource(t *testing.T) {
+	columns := []string{"Replica_IO_State", "Seconds_Behind_Source"}
+	lag, err := secondsBehindSource(columns, []gosql.NullString{{String: "Waiting", Valid: true}, {String: "12", Valid: true}})
+	if err != nil || lag != 12*time.Second {
+		t.Errorf("lag = %s, %v, want 12s", lag, err)
+	}
+	// Servers before MySQL 8.0.22
+	lag, err = secondsBehindSource([]string{"Seconds_Behind_Master"}, []gosql.NullString{{String: "3", Valid: true}})
+	if err != nil || lag != 3*time.Second {
+		t.Errorf("lag = %s, %v, want 3s", lag, err)
+	}
+	if _, err := secondsBehindSource(columns, []gosql.NullString{{String: "", Valid: true}, {}}); err == nil {
+		t.Error("NULL lag (replication stopped) should be an error")
+	}
+}
+
+func TestNilThrottlerDoesNotThrottle(t *testing.T) {
+	var throttler *Throttler
+	if paused, err := throttler.Throttle(); paused != 0 || err != nil {
+		t.Errorf("nil throttler paused for %s, %v", paused, err)
+	}
+	throttler.Stop()
+}
+
+// A pause that never clears, e.g. while replication is stopped, fails after --max-throttle-time
+func TestThrottleGivesUpAfterMaxThrottleTime(t *testing.T) {
+	context := types.NewBaseContext()
+	context.MaxThrottleTime = time.Millisecond
+	context.SetThrottled(true, "cannot read replication lag of replica1:3306: replication is not running")
+	throttler := &Throttler{context: context}
+	paused, err := throttler.Throttle()
+	if err == nil {
+		t.Fatal("a pause past --max-throttle-time should fail")
+	}
+	if paused < time.Millisecond {
+		t.Errorf("paused for %s, want at least the max throttle time", paused)
+	}
+}
+
+func TestExceededLoad(t *testing.T) {
+	load := types.LoadMap{"Threads_running": 50, "Innodb_row_lock_current_waits": 10}
+	status := map[string]int64{"Threads_running": 50, "Innodb_row_lock_current_waits": 3}
+	readStatus := func(variable string) (int64, error) {
+		value, ok := status[variable]
+		if !ok {
+			return 0, fmt.Errorf("unknown status variable %s", variable)
+		}
+		return value, nil
+	}
+	variable, value, err := exceededLoad(load, readStatus)
+	if err != nil || variable != "Threads_running" || value != 50 {
+		t.Errorf("exceededLoad = %s, %d, %v; want Threads_running, 50", variable, value, err)
+	}
+	status["Threads_running"] = 49
+	if variable, _, err := exceededLoad(load, readStatus); err != nil || variable != "" {
+		t.Errorf("exceededLoad = %s, %v; want no variable", variable, err)
+	}
+	delete(status, "Threads_running")
+	if _, _, err := exceededLoad(load, readStatus); err == nil {
+		t.Error("a status read error should be returned")
+	}
+}
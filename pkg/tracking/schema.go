@@ -3,13 +3,44 @@ package tracking
 import (
 	"database/sql"
 	_ "embed"
+	"errors"
 	"fmt"
 	"strings"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 //go:embed schema.sql
 var schemaSQL string
 
+// schemaMigrations bring tracking databases created by older versions up to
+// schema.sql, one added column per statement. On an up-to-date database each
+// fails with ER_DUP_FIELDNAME, which EnsureSchema ignores. Enum extensions are
+// MODIFY COLUMN statements, which simply succeed again.
+var schemaMigrations = []string{
+	"ALTER TABLE checksum_jobs ADD COLUMN hash_function VARCHAR(32) NULL",
+	// The default labels jobs recorded before row_encoding existed with the encoding they used
+	"ALTER TABLE checksum_jobs ADD COLUMN row_encoding VARCHAR(32) NOT NULL DEFAULT 'concat-ws-hex'",
+	"ALTER TABLE table_comparisons ADD COLUMN snapshot_binlog_position VARCHAR(255) NULL",
+	"ALTER TABLE table_comparisons ADD COLUMN snapshot_gtid_set TEXT NULL",
+	"ALTER TABLE table_comparisons ADD COLUMN throttle_time_ms BIGINT NULL",
+	"ALTER TABLE table_comparisons ADD COLUMN key_range_splits INT NULL",
+	"ALTER TABLE table_comparisons ADD COLUMN sample_chunks INT NULL",
+	"ALTER TABLE table_comparisons ADD COLUMN sample_population BIGINT NULL",
+	"ALTER TABLE table_comparisons ADD COLUMN prefilter VARCHAR(16) NULL",
+	"ALTER TABLE table_comparisons ADD COLUMN different_columns JSON NULL",
+	"ALTER TABLE table_comparisons ADD COLUMN full_table BOOLEAN NULL",
+	"ALTER TABLE chunk_comparisons ADD COLUMN source_row_count BIGINT NULL",
+	"ALTER TABLE chunk_comparisons ADD COLUMN target_row_count BIGINT NULL",
+	"ALTER TABLE chunk_comparisons ADD COLUMN source_hash_sum VARCHAR(32) NULL",
+	"ALTER TABLE chunk_comparisons ADD COLUMN target_hash_sum VARCHAR(32) NULL",
+	"ALTER TABLE chunk_comparisons ADD COLUMN oversized_action VARCHAR(16) NULL",
+	"ALTER TABLE chunk_comparisons MODIFY COLUMN status ENUM('equal', 'different', 'error', 'skipped') NOT NULL",
+}
+
+// erDupFieldName is the MySQL error number of "Duplicate column name"
+const erDupFieldName = 1060
+
 // SplitSQLStatements splits a DDL script into individual statements on ';',
 // stripping '--' comments and blank lines. The mysql driver executes one
 // statement per Exec (multiStatements is not enabled in our DSNs).
@@ -33,12 +64,22 @@ func SplitSQLStatements(script string) []string {
 }
 
 // EnsureSchema creates the tracking tables (IF NOT EXISTS) on db, which must
-// already be connected to the tracking database.
+// already be connected to the tracking database, then applies schemaMigrations.
 func EnsureSchema(db *sql.DB) error {
 	for _, stmt := range SplitSQLStatements(schemaSQL) {
 		if _, err := db.Exec(stmt); err != nil {
 			return fmt.Errorf("tracking schema statement failed: %w", err)
 		}
 	}
+	for _, stmt := range schemaMigrations {
+		if _, err := db.Exec(stmt); err != nil && !isDuplicateColumn(err) {
+			return fmt.Errorf("tracking schema migration failed: %w", err)
+		}
+	}
 	return nil
 }
+
+func isDuplicateColumn(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == erDupFieldName
+}
@@ -13,6 +13,8 @@ CREATE TABLE IF NOT EXISTS checksum_jobs (
     tables_processed INT DEFAULT 0,
     tables_equal INT DEFAULT 0,
     tables_different INT DEFAULT 0,
+    hash_function VARCHAR(32) NULL,
+    row_encoding VARCHAR(32) NOT NULL DEFAULT 'concat-ws-hex',
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
 );
@@ -35,6 +37,15 @@ CREATE TABLE IF NOT EXISTS table_comparisons (
     chunks_different INT DEFAULT 0,
     error_message TEXT NULL,
     processing_speed_rows_per_sec INT NULL,
+    snapshot_binlog_position VARCHAR(255) NULL,
+    snapshot_gtid_set TEXT NULL,
+    throttle_time_ms BIGINT NULL,
+    key_range_splits INT NULL,
+    sample_chunks INT NULL,
+    sample_population BIGINT NULL,
+    prefilter VARCHAR(16) NULL,
+    different_columns JSON NULL,
+    full_table BOOLEAN NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     FOREIGN KEY (job_id) REFERENCES checksum_jobs(job_id),
@@ -49,9 +60,14 @@ CREATE TABLE IF NOT EXISTS chunk_comparisons (
     chunk_number INT NOT NULL,
     range_start JSON NOT NULL,
     range_end JSON NOT NULL,
-    status ENUM('equal', 'different', 'error') NOT NULL,
+    status ENUM('equal', 'different', 'error', 'skipped') NOT NULL,
     source_checksum VARCHAR(64) NULL,
     target_checksum VARCHAR(64) NULL,
+    source_row_count BIGINT NULL,
+    target_row_count BIGINT NULL,
+    source_hash_sum VARCHAR(32) NULL,
+    target_hash_sum VARCHAR(32) NULL,
+    oversized_action VARCHAR(16) NULL,
     row_count_estimate INT NULL,
     processing_time_ms INT NULL,
     error_message TEXT NULL,
@@ -61,6 +77,31 @@ CREATE TABLE IF NOT EXISTS chunk_comparisons (
     INDEX idx_status (status)
 );
 
+-- Chunk boundaries precomputed by --chunk-plan=scan, in key order
+CREATE TABLE IF NOT EXISTS chunk_plans (
+    comparison_id BIGINT NOT NULL,
+    plan_position INT NOT NULL,
+    range_start JSON NOT NULL,
+    range_end JSON NOT NULL,
+    PRIMARY KEY (comparison_id, plan_position),
+    FOREIGN KEY (comparison_id) REFERENCES table_comparisons(comparison_id)
+);
+
+-- Results of the partitions of a table checked partition by partition
+CREATE TABLE IF NOT EXISTS partition_comparisons (
+    comparison_id BIGINT NOT NULL,
+    partition_name VARCHAR(64) NOT NULL,
+    status ENUM('equal', 'different', 'error') NOT NULL,
+    source_row_count BIGINT NULL,
+    target_row_count BIGINT NULL,
+    chunks_equal INT DEFAULT 0,
+    chunks_different INT DEFAULT 0,
+    processing_time_ms INT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (comparison_id, partition_name),
+    FOREIGN KEY (comparison_id) REFERENCES table_comparisons(comparison_id)
+);
+
 -- Detailed differences for investigation
 CREATE TABLE IF NOT EXISTS difference_details (
     detail_id BIGINT AUTO_INCREMENT PRIMARY KEY,
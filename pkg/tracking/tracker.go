@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -14,11 +15,19 @@ const (
 	StatusEqual     = "equal"
 	StatusDifferent = "different"
 	StatusError     = "error"
+	// StatusSkipped marks a chunk left unchecked by --oversized-chunk-action=skip (chunk_comparisons only)
+	StatusSkipped = "skipped"
 )
 
 type JobTracker struct {
 	TrackingDB *sql.DB
 	JobID      string
+	// HashFunction is the --hash-function the job's checksums were computed with;
+	// empty for jobs recorded before it was tracked.
+	HashFunction string
+	// RowEncoding is how the job's rows were encoded before hashing (see
+	// builder.RowEncoding).
+	RowEncoding string
 }
 
 type TableComparison struct {
@@ -45,12 +54,54 @@ type ChunkComparison struct {
 	ProcessingTimeMs int
 }
 
+// PartitionComparison is the recorded result of one partition of a table comparison
+type PartitionComparison struct {
+	PartitionName   string
+	Status          string
+	ChunksEqual     int
+	ChunksDifferent int
+}
+
+// ChunkChecksum is one side's checksum of a chunk. The aggregate check fills all
+// parts; the row-by-row check of --is-superset-as-equal leaves HashSum empty.
+type ChunkChecksum struct {
+	RowCount int64 // -1 when unknown
+	Checksum string
+	HashSum  string
+}
+
+// ChunkCheckpoint is where the chunk loop of a table comparison last got to:
+// the most recently recorded chunk plus the tallies of every chunk up to it.
+type ChunkCheckpoint struct {
+	ChunkNumber     int
+	RangeStart      string // raw JSON as recorded by RecordChunkComparison
+	RangeEnd        string
+	Status          string
+	ChunksEqual     int
+	ChunksDifferent int
+	ChunksError     int
+	ChunksSkipped   int
+	// KeyRangeSplits > 1 means the chunks were checked by parallel key-range
+	// workers, so the last chunk recorded is no checkpoint for the whole table.
+	KeyRangeSplits int
+}
+
 // DifferenceDetail is one differing record destined for difference_details.
 type DifferenceDetail struct {
 	Type             string // 'missing_in_target' | 'extra_in_target' | 'data_mismatch'
 	PrimaryKeyValues map[string]string
 	SourceChecksum   string
 	TargetChecksum   string
+	// ColumnDifferences is stored as the sample_data JSON of data mismatches
+	ColumnDifferences []ColumnDifference
+}
+
+// ColumnDifference is a column whose value differs in a data mismatch; values are
+// nil for NULL, otherwise their (truncated) text.
+type ColumnDifference struct {
+	Column string      `json:"column"`
+	Source interface{} `json:"source"`
+	Target interface{} `json:"target"`
 }
 
 // TableStatus maps a finished table run onto the table_comparisons enum.
@@ -105,35 +156,39 @@ func nullableString(s string) sql.NullString {
 	return sql.NullString{String: s, Valid: true}
 }
 
-func NewJobTracker(trackingDB *sql.DB, sourceHost, targetHost string) (*JobTracker, error) {
+func NewJobTracker(trackingDB *sql.DB, sourceHost, targetHost, hashFunction, rowEncoding string) (*JobTracker, error) {
 	jobID := generateJobID(sourceHost, targetHost, time.Now())
 
 	_, err := trackingDB.Exec(`
-        INSERT INTO checksum_jobs (job_id, source_host, target_host)
-        VALUES (?, ?, ?)
-    `, jobID, sourceHost, targetHost)
+        INSERT INTO checksum_jobs (job_id, source_host, target_host, hash_function, row_encoding)
+        VALUES (?, ?, ?, ?, ?)
+    `, jobID, sourceHost, targetHost, hashFunction, rowEncoding)
 
 	if err != nil {
 		return nil, err
 	}
 
 	return &JobTracker{
-		TrackingDB: trackingDB,
-		JobID:      jobID,
+		TrackingDB:   trackingDB,
+		JobID:        jobID,
+		HashFunction: hashFunction,
+		RowEncoding:  rowEncoding,
 	}, nil
 }
 
 // AttachJobTracker returns a tracker for an existing job, verifying it exists.
 func AttachJobTracker(trackingDB *sql.DB, jobID string) (*JobTracker, error) {
 	var status string
-	err := trackingDB.QueryRow(`SELECT status FROM checksum_jobs WHERE job_id = ?`, jobID).Scan(&status)
+	var hashFunction sql.NullString
+	var rowEncoding string
+	err := trackingDB.QueryRow(`SELECT status, hash_function, row_encoding FROM checksum_jobs WHERE job_id = ?`, jobID).Scan(&status, &hashFunction, &rowEncoding)
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("job %q not found in tracking database", jobID)
 	}
 	if err != nil {
 		return nil, err
 	}
-	return &JobTracker{TrackingDB: trackingDB, JobID: jobID}, nil
+	return &JobTracker{TrackingDB: trackingDB, JobID: jobID, HashFunction: hashFunction.String, RowEncoding: rowEncoding}, nil
 }
 
 func (jt *JobTracker) StartTableComparison(sourceDB, sourceTable, targetDB, targetTable string) (int64, error) {
@@ -166,7 +221,11 @@ func (jt *JobTracker) ReopenTableComparison(comparisonID int64) error {
 	return err
 }
 
-func (jt *JobTracker) RecordChunkComparison(comparisonID int64, chunkNumber int, rangeStart, rangeEnd interface{}, sourceChecksum, targetChecksum, status string, processingTime time.Duration) error {
+// RecordChunkComparison inserts one chunk_comparisons row. rowCountEstimate is
+// the chunk size the chunk was built with, or -1 (NULL) when it is not known;
+// oversizedAction is what --chunk-size-limit did about the chunk, empty (NULL)
+// when it was not oversized.
+func (jt *JobTracker) RecordChunkComparison(comparisonID int64, chunkNumber int, rangeStart, rangeEnd interface{}, source, target ChunkChecksum, status, oversizedAction string, rowCountEstimate int64, processingTime time.Duration) error {
 	if jt == nil || jt.TrackingDB == nil {
 		return nil
 	}
@@ -177,23 +236,190 @@ func (jt *JobTracker) RecordChunkComparison(comparisonID int64, chunkNumber int,
 
 	_, err := jt.TrackingDB.Exec(`
         INSERT INTO chunk_comparisons
-        (comparison_id, chunk_number, range_start, range_end, status, source_checksum, target_checksum, processing_time_ms)
-        VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-    `, comparisonID, chunkNumber, rangeStartStr, rangeEndStr, status, nullableString(sourceChecksum), nullableString(targetChecksum), int(processingTime.Milliseconds()))
+        (comparison_id, chunk_number, range_start, range_end, status, source_checksum, target_checksum,
+         source_row_count, target_row_count, source_hash_sum, target_hash_sum, oversized_action, row_count_estimate, processing_time_ms)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `, comparisonID, chunkNumber, rangeStartStr, rangeEndStr, status, nullableString(source.Checksum), nullableString(target.Checksum),
+		nullableInt64(source.RowCount), nullableInt64(target.RowCount), nullableString(source.HashSum), nullableString(target.HashSum),
+		nullableString(oversizedAction), nullableInt64(rowCountEstimate), int(processingTime.Milliseconds()))
+
+	return err
+}
+
+// RecordTableSnapshot stores the source position a --consistent-snapshot table comparison was read at.
+func (jt *JobTracker) RecordTableSnapshot(comparisonID int64, binlogPosition, gtidSet string) error {
+	if jt == nil || jt.TrackingDB == nil {
+		return nil
+	}
+	_, err := jt.TrackingDB.Exec(`
+        UPDATE table_comparisons SET snapshot_binlog_position = ?, snapshot_gtid_set = ?
+        WHERE comparison_id = ?
+    `, nullableString(binlogPosition), nullableString(gtidSet), comparisonID)
+	return err
+}
+
+// RecordTableKeyRangeSplits stores how many parallel key ranges (--table-threads) a table comparison is checked in.
+func (jt *JobTracker) RecordTableKeyRangeSplits(comparisonID int64, splits int) error {
+	if jt == nil || jt.TrackingDB == nil {
+		return nil
+	}
+	_, err := jt.TrackingDB.Exec(`
+        UPDATE table_comparisons SET key_range_splits = ? WHERE comparison_id = ?
+    `, splits, comparisonID)
+	return err
+}
+
+// RecordTableSample stores how many chunks of a grid of population chunks a sampled table comparison checks.
+func (jt *JobTracker) RecordTableSample(comparisonID int64, sampleChunks int, population int64) error {
+	if jt == nil || jt.TrackingDB == nil {
+		return nil
+	}
+	_, err := jt.TrackingDB.Exec(`
+        UPDATE table_comparisons SET sample_chunks = ?, sample_population = ? WHERE comparison_id = ?
+    `, sampleChunks, population, comparisonID)
+	return err
+}
+
+// GetSampledChunkNumbers returns the chunk numbers that sampled comparisons of the same table pair
+// recorded in jobs between the same hosts as this one, over a grid of the same population.
+func (jt *JobTracker) GetSampledChunkNumbers(sourceDB, sourceTable, targetDB, targetTable string, population int64) ([]int64, error) {
+	if jt == nil || jt.TrackingDB == nil {
+		return nil, nil
+	}
+	rows, err := jt.TrackingDB.Query(`
+        SELECT DISTINCT c.chunk_number
+        FROM chunk_comparisons c
+        JOIN table_comparisons t ON t.comparison_id = c.comparison_id
+        JOIN checksum_jobs j ON j.job_id = t.job_id
+        JOIN checksum_jobs cur ON cur.job_id = ? AND cur.source_host = j.source_host AND cur.target_host = j.target_host
+        WHERE t.source_database = ? AND t.source_table = ? AND t.target_database = ? AND t.target_table = ?
+          AND t.sample_population = ? AND c.chunk_number >= 0
+    `, jt.JobID, sourceDB, sourceTable, targetDB, targetTable, population)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var chunkNumbers []int64
+	for rows.Next() {
+		var chunkNumber int64
+		if err := rows.Scan(&chunkNumber); err != nil {
+			return nil, err
+		}
+		chunkNumbers = append(chunkNumbers, chunkNumber)
+	}
+	return chunkNumbers, rows.Err()
+}
+
+// RecordTablePrefilter stores which --prefilter pass decided a table comparison without a chunk check.
+func (jt *JobTracker) RecordTablePrefilter(comparisonID int64, prefilter string) error {
+	if jt == nil || jt.TrackingDB == nil {
+		return nil
+	}
+	_, err := jt.TrackingDB.Exec(`
+        UPDATE table_comparisons SET prefilter = ? WHERE comparison_id = ?
+    `, prefilter, comparisonID)
+	return err
+}
 
+// RecordTableFullTable stores whether a table comparison covered every row and column of the table, the
+// only comparisons the metadata prefilter trusts.
+func (jt *JobTracker) RecordTableFullTable(comparisonID int64, fullTable bool) error {
+	if jt == nil || jt.TrackingDB == nil {
+		return nil
+	}
+	_, err := jt.TrackingDB.Exec(`
+        UPDATE table_comparisons SET full_table = ? WHERE comparison_id = ?
+    `, fullTable, comparisonID)
 	return err
 }
 
-func (jt *JobTracker) UpdateTableComparison(comparisonID int64, status string, sourceRowCount, targetRowCount int64, chunksProcessed, chunksEqual, chunksDifferent int, errorMessage string) error {
+// RecordTableDifferentColumns stores, per check column, how many chunks of a table comparison had
+// a different column fingerprint (--column-fingerprints).
+func (jt *JobTracker) RecordTableDifferentColumns(comparisonID int64, differentColumns map[string]int) error {
+	if jt == nil || jt.TrackingDB == nil {
+		return nil
+	}
+	columnsJSON, err := json.Marshal(differentColumns)
+	if err != nil {
+		return err
+	}
+	_, err = jt.TrackingDB.Exec(`
+        UPDATE table_comparisons SET different_columns = ? WHERE comparison_id = ?
+    `, string(columnsJSON), comparisonID)
+	return err
+}
+
+// GetLastEqualStartTime returns the Unix start time of the latest comparison of the table pair, in
+// jobs between the same hosts as this one, that compared the full table and found every row equal;
+// found is false without one.
+func (jt *JobTracker) GetLastEqualStartTime(sourceDB, sourceTable, targetDB, targetTable string) (startTime int64, found bool, err error) {
+	if jt == nil || jt.TrackingDB == nil {
+		return 0, false, nil
+	}
+	var lastStart sql.NullInt64
+	err = jt.TrackingDB.QueryRow(`
+        SELECT UNIX_TIMESTAMP(MAX(t.start_time))
+        FROM table_comparisons t
+        JOIN checksum_jobs j ON j.job_id = t.job_id
+        JOIN checksum_jobs cur ON cur.job_id = ? AND cur.source_host = j.source_host AND cur.target_host = j.target_host
+        WHERE t.source_database = ? AND t.source_table = ? AND t.target_database = ? AND t.target_table = ?
+          AND t.status = 'equal' AND t.full_table = 1
+    `, jt.JobID, sourceDB, sourceTable, targetDB, targetTable).Scan(&lastStart)
+	if err != nil {
+		return 0, false, err
+	}
+	return lastStart.Int64, lastStart.Valid, nil
+}
+
+// RecordPartitionComparison stores the result of one partition of a table comparison, replacing
+// the result of an earlier attempt at the partition.
+func (jt *JobTracker) RecordPartitionComparison(comparisonID int64, partitionName, status string, sourceRowCount, targetRowCount int64, chunksEqual, chunksDifferent int, processingTime time.Duration) error {
+	if jt == nil || jt.TrackingDB == nil {
+		return nil
+	}
+	_, err := jt.TrackingDB.Exec(`
+        REPLACE INTO partition_comparisons
+            (comparison_id, partition_name, status, source_row_count, target_row_count, chunks_equal, chunks_different, processing_time_ms)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+    `, comparisonID, partitionName, status, nullableInt64(sourceRowCount), nullableInt64(targetRowCount), chunksEqual, chunksDifferent, processingTime.Milliseconds())
+	return err
+}
+
+// GetPartitionComparisons returns the recorded partition results of a table comparison.
+func (jt *JobTracker) GetPartitionComparisons(comparisonID int64) ([]PartitionComparison, error) {
+	if jt == nil || jt.TrackingDB == nil {
+		return nil, nil
+	}
+	rows, err := jt.TrackingDB.Query(`
+        SELECT partition_name, status, chunks_equal, chunks_different
+        FROM partition_comparisons
+        WHERE comparison_id = ?
+    `, comparisonID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var partitions []PartitionComparison
+	for rows.Next() {
+		var p PartitionComparison
+		if err := rows.Scan(&p.PartitionName, &p.Status, &p.ChunksEqual, &p.ChunksDifferent); err != nil {
+			return nil, err
+		}
+		partitions = append(partitions, p)
+	}
+	return partitions, rows.Err()
+}
+
+func (jt *JobTracker) UpdateTableComparison(comparisonID int64, status string, sourceRowCount, targetRowCount int64, chunksProcessed, chunksEqual, chunksDifferent int, throttleTime time.Duration, errorMessage string) error {
 	if jt == nil || jt.TrackingDB == nil {
 		return nil
 	}
 	_, err := jt.TrackingDB.Exec(`
         UPDATE table_comparisons
         SET status = ?, end_time = NOW(), source_row_count = ?, target_row_count = ?,
-            chunks_processed = ?, chunks_equal = ?, chunks_different = ?, error_message = ?
+            chunks_processed = ?, chunks_equal = ?, chunks_different = ?, throttle_time_ms = ?, error_message = ?
         WHERE comparison_id = ?
-    `, status, nullableInt64(sourceRowCount), nullableInt64(targetRowCount), chunksProcessed, chunksEqual, chunksDifferent, nullableString(errorMessage), comparisonID)
+    `, status, nullableInt64(sourceRowCount), nullableInt64(targetRowCount), chunksProcessed, chunksEqual, chunksDifferent, throttleTime.Milliseconds(), nullableString(errorMessage), comparisonID)
 
 	return err
 }
@@ -258,17 +484,214 @@ func (jt *JobTracker) RecordDifferenceDetails(comparisonID int64, details []Diff
 	}
 	for _, d := range details {
 		pkJSON, _ := json.Marshal(d.PrimaryKeyValues)
+		var sampleData string
+		if len(d.ColumnDifferences) > 0 {
+			sampleJSON, _ := json.Marshal(d.ColumnDifferences)
+			sampleData = string(sampleJSON)
+		}
 		if _, err := jt.TrackingDB.Exec(`
             INSERT INTO difference_details
-            (chunk_id, difference_type, primary_key_values, source_checksum, target_checksum)
-            VALUES (?, ?, ?, ?, ?)
-        `, chunkID, d.Type, string(pkJSON), nullableString(d.SourceChecksum), nullableString(d.TargetChecksum)); err != nil {
+            (chunk_id, difference_type, primary_key_values, source_checksum, target_checksum, sample_data)
+            VALUES (?, ?, ?, ?, ?, ?)
+        `, chunkID, d.Type, string(pkJSON), nullableString(d.SourceChecksum), nullableString(d.TargetChecksum), nullableString(sampleData)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetChunkCheckpoint returns the last chunk recorded for a table comparison,
+// or nil when none was. "Last" is the most recently written row rather than the
+// highest chunk_number, so a table that was restarted from scratch resumes from
+// where the latest attempt stopped. Tallies count the latest row per chunk_number.
+func (jt *JobTracker) GetChunkCheckpoint(comparisonID int64) (*ChunkCheckpoint, error) {
+	if jt == nil || jt.TrackingDB == nil {
+		return nil, nil
+	}
+	var cp ChunkCheckpoint
+	err := jt.TrackingDB.QueryRow(`
+        SELECT chunk_number, CAST(range_start AS CHAR), CAST(range_end AS CHAR), status
+        FROM chunk_comparisons
+        WHERE comparison_id = ? AND chunk_number >= 0
+        ORDER BY chunk_id DESC
+        LIMIT 1
+    `, comparisonID).Scan(&cp.ChunkNumber, &cp.RangeStart, &cp.RangeEnd, &cp.Status)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := jt.TrackingDB.Query(`
+        SELECT c.status, COUNT(*)
+        FROM chunk_comparisons c
+        JOIN (
+            SELECT MAX(chunk_id) AS chunk_id
+            FROM chunk_comparisons
+            WHERE comparison_id = ? AND chunk_number BETWEEN 0 AND ?
+            GROUP BY chunk_number
+        ) latest ON latest.chunk_id = c.chunk_id
+        GROUP BY c.status
+    `, comparisonID, cp.ChunkNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		switch status {
+		case StatusEqual:
+			cp.ChunksEqual = count
+		case StatusDifferent:
+			cp.ChunksDifferent = count
+		case StatusError:
+			cp.ChunksError = count
+		case StatusSkipped:
+			cp.ChunksSkipped = count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var splits sql.NullInt64
+	if err := jt.TrackingDB.QueryRow(`
+        SELECT key_range_splits FROM table_comparisons WHERE comparison_id = ?
+    `, comparisonID).Scan(&splits); err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	cp.KeyRangeSplits = int(splits.Int64)
+	return &cp, nil
+}
+
+// ChunkRange is the recorded range of one chunk, as raw JSON.
+type ChunkRange struct {
+	ChunkNumber int
+	RangeStart  string
+	RangeEnd    string
+}
+
+// GetChunkRanges returns the ranges of the chunks numbered up to lastChunk whose
+// latest recorded row has the given status, in chunk order.
+func (jt *JobTracker) GetChunkRanges(comparisonID int64, lastChunk int, status string) ([]ChunkRange, error) {
+	if jt == nil || jt.TrackingDB == nil {
+		return nil, nil
+	}
+	rows, err := jt.TrackingDB.Query(`
+        SELECT c.chunk_number, CAST(c.range_start AS CHAR), CAST(c.range_end AS CHAR)
+        FROM chunk_comparisons c
+        JOIN (
+            SELECT MAX(chunk_id) AS chunk_id
+            FROM chunk_comparisons
+            WHERE comparison_id = ? AND chunk_number BETWEEN 0 AND ?
+            GROUP BY chunk_number
+        ) latest ON latest.chunk_id = c.chunk_id
+        WHERE c.status = ?
+        ORDER BY c.chunk_number
+    `, comparisonID, lastChunk, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ranges []ChunkRange
+	for rows.Next() {
+		var r ChunkRange
+		if err := rows.Scan(&r.ChunkNumber, &r.RangeStart, &r.RangeEnd); err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, rows.Err()
+}
+
+// RecordChunkPlan replaces the stored chunk plan of a table comparison with the given
+// chunk ranges, in key order. rangeStarts and rangeEnds are paired by position.
+func (jt *JobTracker) RecordChunkPlan(comparisonID int64, rangeStarts, rangeEnds []interface{}) error {
+	if jt == nil || jt.TrackingDB == nil {
+		return nil
+	}
+	if _, err := jt.TrackingDB.Exec(`DELETE FROM chunk_plans WHERE comparison_id = ?`, comparisonID); err != nil {
+		return err
+	}
+	// Insert in batches to keep the statements well below max_allowed_packet
+	const batchSize = 500
+	for batchStart := 0; batchStart < len(rangeStarts); batchStart += batchSize {
+		batchEnd := batchStart + batchSize
+		if batchEnd > len(rangeStarts) {
+			batchEnd = len(rangeStarts)
+		}
+		placeholders := make([]string, 0, batchEnd-batchStart)
+		args := make([]interface{}, 0, 4*(batchEnd-batchStart))
+		for i := batchStart; i < batchEnd; i++ {
+			rangeStartJSON, _ := json.Marshal(rangeStarts[i])
+			rangeEndJSON, _ := json.Marshal(rangeEnds[i])
+			placeholders = append(placeholders, "(?, ?, ?, ?)")
+			args = append(args, comparisonID, i, string(rangeStartJSON), string(rangeEndJSON))
+		}
+		if _, err := jt.TrackingDB.Exec(`
+            INSERT INTO chunk_plans (comparison_id, plan_position, range_start, range_end)
+            VALUES `+strings.Join(placeholders, ", "), args...); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// GetChunkPlan returns the stored chunk plan of a table comparison in key order, with the
+// plan position as ChunkNumber; it is empty when none was stored.
+func (jt *JobTracker) GetChunkPlan(comparisonID int64) ([]ChunkRange, error) {
+	if jt == nil || jt.TrackingDB == nil {
+		return nil, nil
+	}
+	rows, err := jt.TrackingDB.Query(`
+        SELECT plan_position, CAST(range_start AS CHAR), CAST(range_end AS CHAR)
+        FROM chunk_plans
+        WHERE comparison_id = ?
+        ORDER BY plan_position
+    `, comparisonID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var plan []ChunkRange
+	for rows.Next() {
+		var r ChunkRange
+		if err := rows.Scan(&r.ChunkNumber, &r.RangeStart, &r.RangeEnd); err != nil {
+			return nil, err
+		}
+		plan = append(plan, r)
+	}
+	return plan, rows.Err()
+}
+
+// GetTableChunkPlan returns the chunk plan stored by the latest comparison of the same table pair,
+// in a job between the same hosts as this one, that planned its chunks; partitioned comparisons,
+// which plan each partition on its own, are left out.
+func (jt *JobTracker) GetTableChunkPlan(sourceDB, sourceTable, targetDB, targetTable string) ([]ChunkRange, error) {
+	if jt == nil || jt.TrackingDB == nil {
+		return nil, nil
+	}
+	var comparisonID sql.NullInt64
+	err := jt.TrackingDB.QueryRow(`
+        SELECT MAX(t.comparison_id)
+        FROM table_comparisons t
+        JOIN checksum_jobs j ON j.job_id = t.job_id
+        JOIN checksum_jobs cur ON cur.job_id = ? AND cur.source_host = j.source_host AND cur.target_host = j.target_host
+        WHERE t.source_database = ? AND t.source_table = ? AND t.target_database = ? AND t.target_table = ?
+          AND EXISTS (SELECT 1 FROM chunk_plans p WHERE p.comparison_id = t.comparison_id)
+          AND NOT EXISTS (SELECT 1 FROM partition_comparisons pc WHERE pc.comparison_id = t.comparison_id)
+    `, jt.JobID, sourceDB, sourceTable, targetDB, targetTable).Scan(&comparisonID)
+	if err != nil || !comparisonID.Valid {
+		return nil, err
+	}
+	return jt.GetChunkPlan(comparisonID.Int64)
+}
+
 // Resume functionality for large jobs
 func (jt *JobTracker) GetPendingTables() ([]TableComparison, error) {
 	if jt == nil || jt.TrackingDB == nil {
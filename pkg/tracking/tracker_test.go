@@ -1,9 +1,12 @@
 package tracking
 
 import (
+	"errors"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 func TestGenerateJobID(t *testing.T) {
@@ -27,8 +30,8 @@ func TestGenerateJobID(t *testing.T) {
 
 func TestSplitSQLStatements(t *testing.T) {
 	statements := SplitSQLStatements(schemaSQL)
-	if len(statements) != 4 {
-		t.Fatalf("embedded schema should split into 4 statements, got %d", len(statements))
+	if len(statements) != 6 {
+		t.Fatalf("embedded schema should split into 6 statements, got %d", len(statements))
 	}
 	for i, stmt := range statements {
 		if !strings.HasPrefix(stmt, "CREATE TABLE IF NOT EXISTS") {
@@ -104,10 +107,37 @@ func TestJobTrackerNilSafe(t *testing.T) {
 		if err := jt.ReopenTableComparison(1); err != nil {
 			t.Errorf("ReopenTableComparison: %v", err)
 		}
-		if err := jt.RecordChunkComparison(1, 0, nil, nil, "", "", StatusEqual, time.Second); err != nil {
+		if err := jt.RecordChunkComparison(1, 0, nil, nil, ChunkChecksum{}, ChunkChecksum{}, StatusEqual, "", 1000, time.Second); err != nil {
 			t.Errorf("RecordChunkComparison: %v", err)
 		}
-		if err := jt.UpdateTableComparison(1, StatusEqual, -1, -1, 0, 0, 0, ""); err != nil {
+		if err := jt.RecordTableSnapshot(1, "binlog.000001:4", ""); err != nil {
+			t.Errorf("RecordTableSnapshot: %v", err)
+		}
+		if err := jt.RecordTableKeyRangeSplits(1, 4); err != nil {
+			t.Errorf("RecordTableKeyRangeSplits: %v", err)
+		}
+		if err := jt.RecordTableSample(1, 400, 40000); err != nil {
+			t.Errorf("RecordTableSample: %v", err)
+		}
+		if chunkNumbers, err := jt.GetSampledChunkNumbers("db", "t", "db", "t", 40000); chunkNumbers != nil || err != nil {
+			t.Errorf("GetSampledChunkNumbers: got (%v, %v)", chunkNumbers, err)
+		}
+		if plan, err := jt.GetTableChunkPlan("db", "t", "db", "t"); plan != nil || err != nil {
+			t.Errorf("GetTableChunkPlan: got (%v, %v)", plan, err)
+		}
+		if err := jt.RecordPartitionComparison(1, "p202401", StatusEqual, 10, 10, 1, 0, time.Second); err != nil {
+			t.Errorf("RecordPartitionComparison: %v", err)
+		}
+		if partitions, err := jt.GetPartitionComparisons(1); partitions != nil || err != nil {
+			t.Errorf("GetPartitionComparisons: got (%v, %v)", partitions, err)
+		}
+		if err := jt.RecordTablePrefilter(1, "metadata"); err != nil {
+			t.Errorf("RecordTablePrefilter: %v", err)
+		}
+		if startTime, found, err := jt.GetLastEqualStartTime("db", "t", "db", "t"); startTime != 0 || found || err != nil {
+			t.Errorf("GetLastEqualStartTime: got (%d, %v, %v)", startTime, found, err)
+		}
+		if err := jt.UpdateTableComparison(1, StatusEqual, -1, -1, 0, 0, 0, 0, ""); err != nil {
 			t.Errorf("UpdateTableComparison: %v", err)
 		}
 		if err := jt.CompleteJob(1, 1, 0); err != nil {
@@ -119,8 +149,39 @@ func TestJobTrackerNilSafe(t *testing.T) {
 		if err := jt.RecordDifferenceDetails(1, []DifferenceDetail{{Type: "data_mismatch"}}); err != nil {
 			t.Errorf("RecordDifferenceDetails: %v", err)
 		}
+		if ranges, err := jt.GetChunkRanges(1, 10, StatusDifferent); ranges != nil || err != nil {
+			t.Errorf("GetChunkRanges: got (%v, %v)", ranges, err)
+		}
+		if err := jt.RecordChunkPlan(1, []interface{}{[]string{"1"}}, []interface{}{[]string{"1000"}}); err != nil {
+			t.Errorf("RecordChunkPlan: %v", err)
+		}
+		if plan, err := jt.GetChunkPlan(1); plan != nil || err != nil {
+			t.Errorf("GetChunkPlan: got (%v, %v)", plan, err)
+		}
 		if tables, err := jt.GetPendingTables(); tables != nil || err != nil {
 			t.Errorf("GetPendingTables: got (%v, %v)", tables, err)
 		}
 	}
 }
+
+func TestSchemaMigrationsMatchSchema(t *testing.T) {
+	// Every migrated column must also be in schema.sql, so fresh and migrated databases agree
+	for _, stmt := range schemaMigrations {
+		fields := strings.Fields(stmt)
+		if len(fields) < 6 || (fields[3] != "ADD" && fields[3] != "MODIFY") || fields[4] != "COLUMN" {
+			t.Errorf("unexpected migration shape: %q", stmt)
+			continue
+		}
+		definition := strings.Join(fields[5:], " ")
+		if !strings.Contains(schemaSQL, definition) {
+			t.Errorf("schema.sql is missing %q", definition)
+		}
+	}
+
+	if !isDuplicateColumn(&mysql.MySQLError{Number: 1060, Message: "Duplicate column name 'x'"}) {
+		t.Error("error 1060 should be treated as an applied migration")
+	}
+	if isDuplicateColumn(&mysql.MySQLError{Number: 1146}) || isDuplicateColumn(errors.New("boom")) {
+		t.Error("other errors must not be ignored")
+	}
+}
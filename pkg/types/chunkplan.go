@@ -0,0 +1,28 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChunkPlanner is how the chunk boundaries of a unique-key table are found (--chunk-plan)
+type ChunkPlanner string
+
+const (
+	// ProbeChunkPlanner probes the end of each chunk with a LIMIT/OFFSET query as the chunk
+	// loop reaches it. The default.
+	ProbeChunkPlanner ChunkPlanner = "probe"
+	// ScanChunkPlanner reads the key range once through the chunk index before the chunk loop
+	// and keeps every chunk-size-th key as a chunk boundary.
+	ScanChunkPlanner ChunkPlanner = "scan"
+)
+
+// ParseChunkPlanner returns the chunk planner of the given name
+func ParseChunkPlanner(name string) (ChunkPlanner, error) {
+	for _, planner := range []ChunkPlanner{ProbeChunkPlanner, ScanChunkPlanner} {
+		if strings.EqualFold(name, string(planner)) {
+			return planner, nil
+		}
+	}
+	return "", fmt.Errorf("critical: unknown chunk plan %q, expected %s or %s", name, ProbeChunkPlanner, ScanChunkPlanner)
+}
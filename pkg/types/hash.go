@@ -0,0 +1,39 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HashFunction is the row hash the checksum queries are built on (--hash-function)
+type HashFunction string
+
+const (
+	// CRC32Hash hashes each row to 32 bits; chunks XOR them. The default, and the fastest.
+	CRC32Hash HashFunction = "crc32"
+	// MD5Hash, SHA1Hash and SHA256Hash compare rows by their full digest; chunks XOR
+	// the leading 64 bits of the row digests.
+	MD5Hash    HashFunction = "md5"
+	SHA1Hash   HashFunction = "sha1"
+	SHA256Hash HashFunction = "sha2-256"
+	// SplitMD5Hash compares rows by MD5 like MD5Hash, but chunks keep the full 128 bits:
+	// the digest is split into two 64-bit halves XORed separately, as pt-table-checksum does.
+	SplitMD5Hash HashFunction = "split-md5"
+)
+
+// HashFunctions lists the supported hash functions, default first
+var HashFunctions = []HashFunction{CRC32Hash, MD5Hash, SHA1Hash, SHA256Hash, SplitMD5Hash}
+
+// ParseHashFunction returns the hash function of the given name
+func ParseHashFunction(name string) (HashFunction, error) {
+	for _, hashFunction := range HashFunctions {
+		if strings.EqualFold(name, string(hashFunction)) {
+			return hashFunction, nil
+		}
+	}
+	names := make([]string, len(HashFunctions))
+	for i, hashFunction := range HashFunctions {
+		names[i] = string(hashFunction)
+	}
+	return "", fmt.Errorf("critical: unknown hash function %q, expected one of %s", name, strings.Join(names, ", "))
+}
@@ -0,0 +1,68 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var statusVariableRegexp = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// LoadMap maps global status variables to the threshold at which they count as load,
+// e.g. Threads_running=50,Innodb_row_lock_current_waits=10
+type LoadMap map[string]int64
+
+// ParseLoadMap parses a comma-separated list of status=threshold conditions
+func ParseLoadMap(loadList string) (LoadMap, error) {
+	result := LoadMap{}
+	for _, loadCondition := range strings.Split(loadList, ",") {
+		loadCondition = strings.TrimSpace(loadCondition)
+		if loadCondition == "" {
+			continue
+		}
+		loadTokens := strings.Split(loadCondition, "=")
+		if len(loadTokens) != 2 {
+			return nil, fmt.Errorf("critical: invalid load condition %q, expected status=threshold", loadCondition)
+		}
+		variable := strings.TrimSpace(loadTokens[0])
+		if !statusVariableRegexp.MatchString(variable) {
+			return nil, fmt.Errorf("critical: invalid status variable in load condition %q", loadCondition)
+		}
+		threshold, err := strconv.ParseInt(strings.TrimSpace(loadTokens[1]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("critical: invalid threshold in load condition %q", loadCondition)
+		}
+		result[variable] = threshold
+	}
+	return result, nil
+}
+
+// Variables returns the status variables of the map in sorted order
+func (load LoadMap) Variables() []string {
+	variables := make([]string, 0, len(load))
+	for variable := range load {
+		variables = append(variables, variable)
+	}
+	sort.Strings(variables)
+	return variables
+}
+
+// Duplicate returns a copy of the map
+func (load LoadMap) Duplicate() LoadMap {
+	dup := make(LoadMap, len(load))
+	for variable, threshold := range load {
+		dup[variable] = threshold
+	}
+	return dup
+}
+
+// String formats the map the way ParseLoadMap reads it
+func (load LoadMap) String() string {
+	conditions := make([]string, 0, len(load))
+	for _, variable := range load.Variables() {
+		conditions = append(conditions, fmt.Sprintf("%s=%d", variable, load[variable]))
+	}
+	return strings.Join(conditions, ",")
+}
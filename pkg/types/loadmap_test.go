@@ -0,0 +1,42 @@
+package types
+
+import "testing"
+
+func TestParseLoadMap(t *testing.T) {
+	load, err := ParseLoadMap("Threads_running=50, Innodb_row_lock_current_waits=10")
+	if err != nil {
+		t.Fatalf("ParseLoadMap: %v", err)
+	}
+	if load["Threads_running"] != 50 || load["Innodb_row_lock_current_waits"] != 10 || len(load) != 2 {
+		t.Errorf("ParseLoadMap = %v", load)
+	}
+	if got, want := load.String(), "Innodb_row_lock_current_waits=10,Threads_running=50"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if load, err := ParseLoadMap(""); err != nil || len(load) != 0 {
+		t.Errorf("empty list: got %v, %v", load, err)
+	}
+	for _, invalid := range []string{"Threads_running", "Threads_running=many", "Threads_running=1=2", "Threads' OR 1=1"} {
+		if _, err := ParseLoadMap(invalid); err == nil {
+			t.Errorf("%q should be rejected", invalid)
+		}
+	}
+}
+
+func TestReadMaxLoadReturnsCopy(t *testing.T) {
+	ctx := NewBaseContext()
+	if err := ctx.ReadMaxLoad("Threads_running=50"); err != nil {
+		t.Fatalf("ReadMaxLoad: %v", err)
+	}
+	load := ctx.GetMaxLoad()
+	load["Threads_running"] = 1
+	if ctx.GetMaxLoad()["Threads_running"] != 50 {
+		t.Error("GetMaxLoad should return a copy")
+	}
+	if err := ctx.ReadCriticalLoad("Threads_running"); err == nil {
+		t.Error("invalid critical load should be rejected")
+	}
+	if len(ctx.GetCriticalLoad()) != 0 {
+		t.Error("a rejected critical load must not be set")
+	}
+}
@@ -0,0 +1,29 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OversizedChunkAction is what happens to a chunk holding more than --chunk-size-limit
+// times the chunk size rows on the target (--oversized-chunk-action)
+type OversizedChunkAction string
+
+const (
+	// SplitOversizedChunk re-chunks the range by target rows and checks the pieces. The default.
+	SplitOversizedChunk OversizedChunkAction = "split"
+	// SkipOversizedChunk leaves the chunk unchecked, records it as skipped and fails the table.
+	SkipOversizedChunk OversizedChunkAction = "skip"
+	// AbortOversizedChunk fails the table at the chunk.
+	AbortOversizedChunk OversizedChunkAction = "abort"
+)
+
+// ParseOversizedChunkAction returns the oversized-chunk action of the given name
+func ParseOversizedChunkAction(name string) (OversizedChunkAction, error) {
+	for _, action := range []OversizedChunkAction{SplitOversizedChunk, SkipOversizedChunk, AbortOversizedChunk} {
+		if strings.EqualFold(name, string(action)) {
+			return action, nil
+		}
+	}
+	return "", fmt.Errorf("critical: unknown oversized chunk action %q, expected %s, %s or %s", name, SplitOversizedChunk, SkipOversizedChunk, AbortOversizedChunk)
+}
@@ -0,0 +1,29 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Prefilter is the cheap whole-table pass that decides tables before the chunk check (--prefilter)
+type Prefilter string
+
+const (
+	// NoPrefilter sends every table to the chunk check. The default.
+	NoPrefilter Prefilter = "off"
+	// ChecksumTablePrefilter compares CHECKSUM TABLE where engines, row formats and column
+	// definitions match, and the information_schema metadata of the other tables.
+	ChecksumTablePrefilter Prefilter = "checksum-table"
+	// MetadataPrefilter only compares information_schema metadata, never reading table rows.
+	MetadataPrefilter Prefilter = "metadata"
+)
+
+// ParsePrefilter returns the prefilter of the given name
+func ParsePrefilter(name string) (Prefilter, error) {
+	for _, prefilter := range []Prefilter{NoPrefilter, ChecksumTablePrefilter, MetadataPrefilter} {
+		if strings.EqualFold(name, string(prefilter)) {
+			return prefilter, nil
+		}
+	}
+	return "", fmt.Errorf("critical: unknown prefilter %q, expected %s, %s or %s", name, NoPrefilter, ChecksumTablePrefilter, MetadataPrefilter)
+}
@@ -0,0 +1,65 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// allTables is the TableOptions key of a value given without a table name
+const allTables = "*"
+
+// TableOptions maps source tables (db.table) to a per-table option value,
+// e.g. sales.orders=uk_order_no;sales.items=order_no,line_no
+type TableOptions map[string]string
+
+// ParseTableOptions parses a semicolon-separated list of db.table=value entries.
+// A value without a table name applies to every table without an entry of its own.
+func ParseTableOptions(optionList string) (TableOptions, error) {
+	result := TableOptions{}
+	for _, entry := range strings.Split(optionList, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		table, value := allTables, entry
+		if tokens := strings.SplitN(entry, "=", 2); len(tokens) == 2 {
+			table, value = strings.TrimSpace(tokens[0]), strings.TrimSpace(tokens[1])
+			if tableTokens := strings.Split(table, "."); len(tableTokens) != 2 || tableTokens[0] == "" || tableTokens[1] == "" {
+				return nil, fmt.Errorf("critical: invalid table %q in %q, expected db.table=value", table, entry)
+			}
+		}
+		if value == "" {
+			return nil, fmt.Errorf("critical: missing value in %q", entry)
+		}
+		if _, found := result[table]; found {
+			return nil, fmt.Errorf("critical: %q is given more than once", table)
+		}
+		result[table] = value
+	}
+	return result, nil
+}
+
+// For returns the value of the source table, or "" when it has none
+func (options TableOptions) For(databaseName, tableName string) string {
+	if value, found := options[databaseName+"."+tableName]; found {
+		return value
+	}
+	return options[allTables]
+}
+
+// Has reports whether the source table has an entry of its own
+func (options TableOptions) Has(databaseName, tableName string) bool {
+	_, found := options[databaseName+"."+tableName]
+	return found
+}
+
+// Tables returns the tables with an entry of their own
+func (options TableOptions) Tables() []string {
+	tables := make([]string, 0, len(options))
+	for table := range options {
+		if table != allTables {
+			tables = append(tables, table)
+		}
+	}
+	return tables
+}
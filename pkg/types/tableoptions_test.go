@@ -0,0 +1,40 @@
+package types
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestParseTableOptions(t *testing.T) {
+	options, err := ParseTableOptions("sales.orders=order_no,line_no; sales.items = uk_item ;id")
+	if err != nil {
+		t.Fatalf("ParseTableOptions: %v", err)
+	}
+	if got := options.For("sales", "orders"); got != "order_no,line_no" {
+		t.Errorf("sales.orders = %q", got)
+	}
+	if got := options.For("sales", "items"); got != "uk_item" {
+		t.Errorf("sales.items = %q", got)
+	}
+	if got := options.For("sales", "customers"); got != "id" {
+		t.Errorf("a table without an entry should get the default, got %q", got)
+	}
+	if !options.Has("sales", "orders") || options.Has("sales", "customers") {
+		t.Error("Has should report only the tables with an entry of their own")
+	}
+	tables := options.Tables()
+	sort.Strings(tables)
+	if len(tables) != 2 || tables[0] != "sales.items" || tables[1] != "sales.orders" {
+		t.Errorf("Tables() = %v", tables)
+	}
+
+	var empty TableOptions
+	if got := empty.For("sales", "orders"); got != "" {
+		t.Errorf("empty options = %q", got)
+	}
+	for _, invalid := range []string{"orders=id", "sales.orders=", "a;b", "sales.orders=id;sales.orders=no"} {
+		if _, err := ParseTableOptions(invalid); err == nil {
+			t.Errorf("%q should be rejected", invalid)
+		}
+	}
+}
@@ -26,8 +26,36 @@ const (
 	JSONColumnType
 	FloatColumnType
 	BinaryColumnType
+	IntegerColumnType
+	DateColumnType
 )
 
+// ColumnTypeFromDataType maps an information_schema DATA_TYPE onto the column
+// types the tool tells apart. Enums are left unknown on purpose: typing them
+// would switch the builder to text ordering for enum key columns.
+func ColumnTypeFromDataType(dataType string) ColumnType {
+	switch strings.ToLower(dataType) {
+	case "tinyint", "smallint", "int", "bigint":
+		return IntegerColumnType
+	case "mediumint":
+		return MediumIntColumnType
+	case "timestamp":
+		return TimestampColumnType
+	case "datetime":
+		return DateTimeColumnType
+	case "date":
+		return DateColumnType
+	case "float", "double":
+		return FloatColumnType
+	}
+	return UnknownColumnType
+}
+
+// IsTemporal reports whether values of the column scan into time.Time (parseTime=true).
+func (c *Column) IsTemporal() bool {
+	return c.Type == TimestampColumnType || c.Type == DateTimeColumnType || c.Type == DateColumnType
+}
+
 type TableContext struct {
 	SourceDatabaseName string
 	SourceTableName    string
@@ -38,6 +66,12 @@ type TableContext struct {
 	// ComparisonID is non-zero only on resume, where the table_comparisons row
 	// already exists and must be reused instead of inserted.
 	ComparisonID int64
+	// DifferentChunkRanges lists the ranges of the chunks whose checksums
+	// differ, for the end-of-run summary.
+	DifferentChunkRanges []string
+	// DifferentColumns counts, per check column, the chunks whose column
+	// fingerprints differ (--column-fingerprints), for the end-of-run summary.
+	DifferentColumns map[string]int
 }
 
 func NewTableContext(sourceDatabaseName, sourceTableName, targetDatabaseName, targetTableName string) *TableContext {
@@ -83,6 +117,13 @@ type BaseContext struct {
 	TargetTableAddSuffix    string
 	SourceTableNameRegexp   string
 	TableQueryHint          string
+	// ChunkIndex and MatchColumns override the unique key picked for a source
+	// table: the index chunks are read by, and the columns rows are matched on.
+	ChunkIndex   TableOptions
+	MatchColumns TableOptions
+	// Partitions names the partitions of a partitioned source table to check, comma-separated;
+	// without an entry every partition is checked.
+	Partitions TableOptions
 
 	SourceTableFullNameList     []string
 	TargetTableFullNameList     []string
@@ -97,16 +138,55 @@ type BaseContext struct {
 	SpecifiedDatetimeRangeBegin time.Time
 	SpecifiedDatetimeRangeEnd   time.Time
 	IgnoreRowCountCheck         bool
-
-	ChunkSize                   int64
+	// ConsistentSnapshot checks each table inside a pair of consistent-snapshot
+	// transactions, the target's taken once it has executed the source's GTID set.
+	ConsistentSnapshot  bool
+	SnapshotWaitTimeout time.Duration
+	// MaxLag > 0 pauses the chunk loops while the target or a control replica
+	// lags more (see pkg/throttle).
+	MaxLag                  time.Duration
+	ThrottleControlReplicas string
+	ReplicationLagQuery     string
+	MaxThrottleTime         time.Duration // fail a table once a single pause lasts this long, 0 waits indefinitely
+	// --max-load pauses and --critical-load aborts the run once a status variable
+	// of the source or target reaches its threshold; isThrottled is the verdict
+	// of the throttler's collector.
+	maxLoad        LoadMap
+	criticalLoad   LoadMap
+	isThrottled    bool
+	throttleReason string
+
+	ChunkSize int64
+	// ChunkTime > 0 turns on adaptive chunk sizing: each table's chunk size is
+	// tuned so a chunk query takes about this long, within ChunkSizeMin..ChunkSizeMax.
+	ChunkTime                   time.Duration
+	ChunkSizeMin                int64
+	ChunkSizeMax                int64
+	ChunkLookahead              int // chunk boundaries probed ahead of the chunk loop; 0 turns the lookahead off
+	ChunkPlanner                ChunkPlanner
+	ChunkSizeLimit              float64 // chunks with more target rows than ChunkSizeLimit times the chunk size are oversized; 0 turns the check off
+	OversizedChunkAction        OversizedChunkAction
+	IgnoreQueryPlan             bool    // check tables whose chunk queries would not range over the chunk index
+	SamplePercent               float64 // check a random sample of this percentage of each table's chunks; 0 checks every chunk
+	SampleChunks                int     // check this many random chunks of each table instead; 0 leaves it to SamplePercent
+	Prefilter                   Prefilter
 	DefaultNumRetries           int64
 	IsSuperSetAsEqual           bool
+	HashFunction                HashFunction
 	EnableDifferentialReporting bool
+	ContinueOnMismatch          bool  // keep checking the chunks after a mismatched one
+	EnableChunkBisection        bool  // narrow a mismatched chunk by re-checksumming its halves
+	BisectRowThreshold          int64 // stop bisecting once a piece holds at most this many source rows
+	SkipTargetSweep             bool  // keep the differential analysis to its key ranges, not sweeping the target outside the source key range
+	ColumnFingerprints          bool  // fingerprint the check columns of a mismatched chunk one by one to tell which differ
 	MaxSampleDifferences        int
 	MaxDisplayDifferences       int
 	GenerateSyncSQL             bool
 	SyncSQLFile                 string
 	ParallelThreads             int
+	TableThreads                int // key-range workers per table (unique-key mode)
+	DiffThreads                 int // chunks the differential analysis of a table compares in parallel
+	KeylessBuckets              int // hash buckets of a table without a usable unique key; 0 fails such tables
 	ChecksumResChan             chan bool
 	ChecksumErrChan             chan error
 	PanicAbort                  chan error
@@ -118,6 +198,15 @@ type BaseContext struct {
 func NewBaseContext() *BaseContext {
 	return &BaseContext{
 		ChunkSize:             1000,
+		ChunkSizeMin:          10,
+		ChunkSizeMax:          100000,
+		ChunkLookahead:        2,
+		ChunkPlanner:          ProbeChunkPlanner,
+		OversizedChunkAction:  SplitOversizedChunk,
+		Prefilter:             NoPrefilter,
+		BisectRowThreshold:    100,
+		KeylessBuckets:        64,
+		HashFunction:          CRC32Hash,
 		DefaultNumRetries:     10,
 		MaxSampleDifferences:  100,
 		MaxDisplayDifferences: 10,
@@ -138,6 +227,23 @@ func (ctx *BaseContext) SetChunkSize(chunkSize int64) {
 	atomic.StoreInt64(&ctx.ChunkSize, chunkSize)
 }
 
+// SetChunkSizeBounds stores the adaptive chunk size bounds, clamped to the same 10-100000 range as SetChunkSize
+func (ctx *BaseContext) SetChunkSizeBounds(minChunkSize, maxChunkSize int64) {
+	clamp := func(chunkSize int64) int64 {
+		if chunkSize < 10 {
+			return 10
+		}
+		if chunkSize > 100000 {
+			return 100000
+		}
+		return chunkSize
+	}
+	ctx.ChunkSizeMin, ctx.ChunkSizeMax = clamp(minChunkSize), clamp(maxChunkSize)
+	if ctx.ChunkSizeMin > ctx.ChunkSizeMax {
+		ctx.ChunkSizeMin = ctx.ChunkSizeMax
+	}
+}
+
 // SetDefaultNumRetries sets the maximum number of retries (default 10); non-positive values are ignored
 func (ctx *BaseContext) SetDefaultNumRetries(retries int64) {
 	ctx.throttleMutex.Lock()
@@ -147,6 +253,59 @@ func (ctx *BaseContext) SetDefaultNumRetries(retries int64) {
 	}
 }
 
+// ReadMaxLoad parses and sets the --max-load conditions
+func (ctx *BaseContext) ReadMaxLoad(maxLoadList string) error {
+	loadMap, err := ParseLoadMap(maxLoadList)
+	if err != nil {
+		return err
+	}
+	ctx.throttleMutex.Lock()
+	defer ctx.throttleMutex.Unlock()
+	ctx.maxLoad = loadMap
+	return nil
+}
+
+// GetMaxLoad returns a copy of the --max-load conditions
+func (ctx *BaseContext) GetMaxLoad() LoadMap {
+	ctx.throttleMutex.Lock()
+	defer ctx.throttleMutex.Unlock()
+	return ctx.maxLoad.Duplicate()
+}
+
+// ReadCriticalLoad parses and sets the --critical-load conditions
+func (ctx *BaseContext) ReadCriticalLoad(criticalLoadList string) error {
+	loadMap, err := ParseLoadMap(criticalLoadList)
+	if err != nil {
+		return err
+	}
+	ctx.throttleMutex.Lock()
+	defer ctx.throttleMutex.Unlock()
+	ctx.criticalLoad = loadMap
+	return nil
+}
+
+// GetCriticalLoad returns a copy of the --critical-load conditions
+func (ctx *BaseContext) GetCriticalLoad() LoadMap {
+	ctx.throttleMutex.Lock()
+	defer ctx.throttleMutex.Unlock()
+	return ctx.criticalLoad.Duplicate()
+}
+
+// SetThrottled records the throttle verdict and its reason
+func (ctx *BaseContext) SetThrottled(throttle bool, reason string) {
+	ctx.throttleMutex.Lock()
+	defer ctx.throttleMutex.Unlock()
+	ctx.isThrottled = throttle
+	ctx.throttleReason = reason
+}
+
+// IsThrottled returns whether the chunk loops should pause, and why
+func (ctx *BaseContext) IsThrottled() (bool, string) {
+	ctx.throttleMutex.Lock()
+	defer ctx.throttleMutex.Unlock()
+	return ctx.isThrottled, ctx.throttleReason
+}
+
 // SetLogLevel configures the log level and output destination
 func (ctx *BaseContext) SetLogLevel(debug bool, logFile string) {
 	ctx.Log.SetLevel(log.InfoLevel)
@@ -191,6 +350,11 @@ func (ctx *BaseContext) IsDatetimeColumnSpecified() bool {
 	}
 }
 
+// IsSampling reports whether only a random sample of each table's chunks is checked
+func (ctx *BaseContext) IsSampling() bool {
+	return ctx.SamplePercent > 0 || ctx.SampleChunks > 0
+}
+
 // BuildDBUri builds a mysql driver DSN with the connection options shared by
 // all of this tool's connections.
 func BuildDBUri(user, password, host string, port int, databaseName string, timeoutSeconds int) string {
@@ -54,6 +54,25 @@ func TestColumnListIsSubsetOf(t *testing.T) {
 	}
 }
 
+func TestColumnTypeFromDataType(t *testing.T) {
+	cases := map[string]ColumnType{
+		"bigint":    IntegerColumnType,
+		"INT":       IntegerColumnType,
+		"mediumint": MediumIntColumnType,
+		"datetime":  DateTimeColumnType,
+		"timestamp": TimestampColumnType,
+		"date":      DateColumnType,
+		"double":    FloatColumnType,
+		"varchar":   UnknownColumnType,
+		"enum":      UnknownColumnType,
+	}
+	for dataType, want := range cases {
+		if got := ColumnTypeFromDataType(dataType); got != want {
+			t.Errorf("ColumnTypeFromDataType(%q) = %v, want %v", dataType, got, want)
+		}
+	}
+}
+
 func TestColumnValuesStringColumn(t *testing.T) {
 	cv := ToColumnValues([]interface{}{[]uint8("50003"), int64(7), nil})
 	if got := cv.StringColumn(0); got != "50003" {
@@ -95,6 +114,18 @@ func TestSetChunkSizeClamping(t *testing.T) {
 	}
 }
 
+func TestSetChunkSizeBounds(t *testing.T) {
+	ctx := NewBaseContext()
+	ctx.SetChunkSizeBounds(1, 500000)
+	if ctx.ChunkSizeMin != 10 || ctx.ChunkSizeMax != 100000 {
+		t.Errorf("bounds should clamp to 10-100000, got %d-%d", ctx.ChunkSizeMin, ctx.ChunkSizeMax)
+	}
+	ctx.SetChunkSizeBounds(5000, 200)
+	if ctx.ChunkSizeMin != 200 || ctx.ChunkSizeMax != 200 {
+		t.Errorf("an inverted range should collapse onto the maximum, got %d-%d", ctx.ChunkSizeMin, ctx.ChunkSizeMax)
+	}
+}
+
 func TestSetDefaultNumRetries(t *testing.T) {
 	ctx := NewBaseContext()
 	ctx.SetDefaultNumRetries(3)
@@ -108,6 +139,21 @@ func TestSetDefaultNumRetries(t *testing.T) {
 	}
 }
 
+func TestSetThrottled(t *testing.T) {
+	ctx := NewBaseContext()
+	if throttled, _ := ctx.IsThrottled(); throttled {
+		t.Error("a new context should not be throttled")
+	}
+	ctx.SetThrottled(true, "replica lags")
+	if throttled, reason := ctx.IsThrottled(); !throttled || reason != "replica lags" {
+		t.Errorf("IsThrottled() = %t, %q, want true, \"replica lags\"", throttled, reason)
+	}
+	ctx.SetThrottled(false, "")
+	if throttled, _ := ctx.IsThrottled(); throttled {
+		t.Error("throttle should be released")
+	}
+}
+
 func TestSetSpecifiedDatetimeRange(t *testing.T) {
 	ctx := NewBaseContext()
 	if err := ctx.SetSpecifiedDatetimeRange("2026-01-01 00:00:00", "2026-02-01 00:00:00"); err != nil {
@@ -181,3 +227,20 @@ func TestUniqueKey(t *testing.T) {
 		t.Error("non-PRIMARY key should not report IsPrimary")
 	}
 }
+
+func TestParseHashFunction(t *testing.T) {
+	for _, name := range []string{"crc32", "MD5", "sha1", "sha2-256", "split-md5"} {
+		if _, err := ParseHashFunction(name); err != nil {
+			t.Errorf("%s rejected: %v", name, err)
+		}
+	}
+	if hashFunction, _ := ParseHashFunction("MD5"); hashFunction != MD5Hash {
+		t.Errorf("ParseHashFunction(MD5) = %s, want md5", hashFunction)
+	}
+	if _, err := ParseHashFunction("fnv"); err == nil {
+		t.Error("unknown hash function should be rejected")
+	}
+	if NewBaseContext().HashFunction != CRC32Hash {
+		t.Error("crc32 should be the default hash function")
+	}
+}